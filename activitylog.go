@@ -0,0 +1,342 @@
+package domo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ActivityPageSize the number of entries asked for in each request of Query, the most Domo sends at once
+const ActivityPageSize = 1000
+
+// The defaults of ActivityTailOptions
+const (
+	DefaultTailInterval = time.Minute
+	DefaultTailLag      = 2 * time.Minute
+	DefaultTailWindow   = time.Hour
+)
+
+// ActivityLogService Activity Log API service
+type ActivityLogService service
+
+// At the time of the entry
+func (e ActivityEntry) At() time.Time {
+	return time.Unix(0, e.Time*int64(time.Millisecond)).UTC()
+}
+
+// key identifies an entry among those of the same millisecond, as entries have no id
+func (e ActivityEntry) key() string {
+	bodyBytes, _ := json.Marshal(e)
+	sum := sha256.Sum256(bodyBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// ActivityQuery selects activity log entries. Fields left empty don't filter.
+type ActivityQuery struct {
+	Start      time.Time // The earliest time, inclusive
+	End        time.Time // The latest time, inclusive, now when zero
+	UserID     int       // Only the entries about this user
+	ObjectType string    // Only the entries about this kind of object, such as PAGE
+	Action     string    // Only the entries with this EventText, ignoring case. Domo doesn't filter on it, so it is done here.
+}
+
+// values the query as the parameters of a request
+func (q ActivityQuery) values(limit int, offset int) url.Values {
+	end := q.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	values := url.Values{}
+	values.Set("start", fmt.Sprint(millis(q.Start)))
+	values.Set("end", fmt.Sprint(millis(end)))
+	values.Set("limit", fmt.Sprint(limit))
+	values.Set("offset", fmt.Sprint(offset))
+	if q.UserID != 0 {
+		values.Set("user", fmt.Sprint(q.UserID))
+	}
+	if q.ObjectType != "" {
+		values.Set("objectType", q.ObjectType)
+	}
+	return values
+}
+
+func (q ActivityQuery) matches(entry ActivityEntry) bool {
+	return q.Action == "" || strings.EqualFold(strings.TrimSpace(entry.EventText), strings.TrimSpace(q.Action))
+}
+
+// millis a time in milliseconds since the epoch, as the activity log counts it
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// List Retrieves a page of activity log entries.
+// Definition
+// GET https://api.domo.com/v1/audit?start={START}&end={END}&limit={LIMIT}&offset={OFFSET}&user={USER_ID}&objectType={OBJECT_TYPE}
+// Returns
+// Returns up to limit entries starting at offset, leaving out those not of q.Action, and the number Domo sent
+func (a *ActivityLogService) List(ctx context.Context, q ActivityQuery, limit int, offset int) (entries []ActivityEntry, sent int, err error) {
+	address := fmt.Sprintf("%s/v1/audit?%s", a.client.baseURL, q.values(limit, offset).Encode())
+	bodyBytes, err := a.client.sendJSON(ctx, "audit", "GET", address, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Failed to list activity : %s", err)
+	}
+
+	var page []ActivityEntry
+	if err = json.Unmarshal(bodyBytes, &page); err != nil {
+		return nil, 0, fmt.Errorf("Unable to unmarshal activity %s", err)
+	}
+	for _, entry := range page {
+		if q.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, len(page), nil
+}
+
+// Query Retrieves every activity log entry the query selects, a page at a time.
+// Returns
+// Returns the entries, oldest first
+func (a *ActivityLogService) Query(ctx context.Context, q ActivityQuery) (entries []ActivityEntry, err error) {
+	if !q.End.IsZero() && q.End.Before(q.Start) {
+		return nil, errors.New("The end of an activity query is before its start")
+	}
+	if q.End.IsZero() {
+		q.End = time.Now()
+	}
+	for offset := 0; ; offset += ActivityPageSize {
+		page, sent, err := a.List(ctx, q, ActivityPageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, page...)
+		if sent < ActivityPageSize {
+			break
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time < entries[j].Time })
+	logger(fmt.Sprintf("[ActivityLogService] Query : %d entries found", len(entries)))
+	return entries, nil
+}
+
+// ActivityCursor how far a tail of the activity log has got, kept in a file between runs
+type ActivityCursor struct {
+	Time      int64     `json:"time"`      // The time of the newest entry delivered, in milliseconds since the epoch
+	Seen      []string  `json:"seen"`      // The entries delivered at Time, as several can share a millisecond
+	UpdatedAt time.Time `json:"updatedAt"` // When the cursor was last saved
+}
+
+// LoadActivityCursor reads a cursor file.
+//
+// Returns nil, and no error, when the file does not exist.
+func LoadActivityCursor(path string) (cursor *ActivityCursor, err error) {
+	bodyBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read cursor %s", err)
+	}
+	if err = json.Unmarshal(bodyBytes, &cursor); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal cursor %s : %s", path, err)
+	}
+	return
+}
+
+// Save writes the cursor to path, replacing the old file only once the new one is complete.
+func (c *ActivityCursor) Save(path string) error {
+	c.UpdatedAt = time.Now()
+	bodyBytes, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to marshal cursor %s", err)
+	}
+	if err = ReplaceFile(path, bodyBytes); err != nil {
+		return fmt.Errorf("Unable to save cursor %s", err)
+	}
+	return nil
+}
+
+// delivered whether the entry was delivered before the cursor got to where it is
+func (c *ActivityCursor) delivered(entry ActivityEntry) bool {
+	if entry.Time != c.Time {
+		return entry.Time < c.Time
+	}
+	key := entry.key()
+	for _, seen := range c.Seen {
+		if seen == key {
+			return true
+		}
+	}
+	return false
+}
+
+// advance moves the cursor past the entry
+func (c *ActivityCursor) advance(entry ActivityEntry) {
+	if entry.Time > c.Time {
+		c.Time, c.Seen = entry.Time, nil
+	}
+	c.Seen = append(c.Seen, entry.key())
+}
+
+// ActivityTailOptions controls a Tail
+type ActivityTailOptions struct {
+	ActivityQuery               // The entries to follow. Start is where a tail without a saved cursor begins, now when zero. End is ignored.
+	Interval      time.Duration // Time between polls, DefaultTailInterval when 0
+	Lag           time.Duration // How far behind now to read, so entries Domo records late are not skipped, DefaultTailLag when 0
+	Window        time.Duration // The most time read by one query, so a tail far behind catches up a window at a time, DefaultTailWindow when 0
+	Cursor        string        // Optional file that keeps the cursor, so a restarted tail carries on where the last one stopped
+	Ack           bool          // Only move the cursor past an entry once Ack is called for it, rather than once it is received
+}
+
+// ActivityTail follows the activity log, sending new entries on Events in the order they happened.
+//
+// By default an entry counts as delivered once it has been received from Events, and the cursor is
+// saved straight after, so delivery is at most once: an entry being handled when the program stops
+// is not sent again by a tail restarted with the same Cursor file. With ActivityTailOptions.Ack the
+// cursor only moves when Ack is called, so delivery is at least once: a restarted tail sends again
+// every entry that wasn't acknowledged.
+// Entries identical in every field and in the same millisecond are delivered once.
+type ActivityTail struct {
+	events chan ActivityEntry
+	sent   ActivityCursor // How far the entries sent on events have got
+	path   string
+
+	mu     sync.Mutex
+	cursor ActivityCursor // How far the delivered entries have got, as saved in path
+	err    error
+}
+
+// Events the entries, closed when the tail stops
+func (t *ActivityTail) Events() <-chan ActivityEntry {
+	return t.events
+}
+
+// Err why the tail stopped, nil if its context was cancelled. Only valid once Events is closed.
+func (t *ActivityTail) Err() error {
+	return t.err
+}
+
+// Cursor how far the delivered entries have got
+func (t *ActivityTail) Cursor() ActivityCursor {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cursor
+}
+
+// Ack marks an entry received from Events as handled, moving the cursor past it and saving it.
+// It is only needed with ActivityTailOptions.Ack, and entries must be acknowledged in the order they
+// were received; acknowledging one acknowledges every entry received before it.
+func (t *ActivityTail) Ack(entry ActivityEntry) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cursor.delivered(entry) {
+		return nil
+	}
+	t.cursor.advance(entry)
+	if t.path == "" {
+		return nil
+	}
+	return t.cursor.Save(t.path)
+}
+
+// Tail Starts following the activity log, polling every opts.Interval until ctx is cancelled or a poll fails.
+// A failed poll stops the tail, and restarting it with the same Cursor file carries on without a gap.
+// A tail that starts far behind reads the log a window at a time, so it never holds more than one window.
+func (a *ActivityLogService) Tail(ctx context.Context, opts ActivityTailOptions) (*ActivityTail, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultTailInterval
+	}
+	if opts.Lag <= 0 {
+		opts.Lag = DefaultTailLag
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultTailWindow
+	}
+
+	tail := &ActivityTail{events: make(chan ActivityEntry), path: opts.Cursor}
+	var saved *ActivityCursor
+	if opts.Cursor != "" {
+		var err error
+		if saved, err = LoadActivityCursor(opts.Cursor); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case saved != nil:
+		tail.cursor = *saved
+	case opts.Start.IsZero():
+		tail.cursor.Time = millis(time.Now().Add(-opts.Lag))
+	default:
+		tail.cursor.Time = millis(opts.Start)
+	}
+	tail.sent = tail.cursor
+	tail.sent.Seen = append([]string(nil), tail.cursor.Seen...)
+
+	go func() {
+		defer close(tail.events)
+		tail.err = a.follow(ctx, tail, opts)
+		if ctx.Err() != nil {
+			tail.err = nil // A poll cut short by the cancel fails with an error of its own
+		}
+	}()
+	return tail, nil
+}
+
+// follow polls until ctx is cancelled or something fails, reading at most one window per query and
+// going straight on to the next while it is behind
+func (a *ActivityLogService) follow(ctx context.Context, tail *ActivityTail, opts ActivityTailOptions) error {
+	from := tail.sent.Time
+	window := int64(opts.Window / time.Millisecond)
+	for {
+		to, behind := millis(time.Now().Add(-opts.Lag)), false
+		if to-from > window {
+			to, behind = from+window, true
+		}
+		if to >= from {
+			q := opts.ActivityQuery
+			q.Start, q.End = time.Unix(0, from*int64(time.Millisecond)), time.Unix(0, to*int64(time.Millisecond))
+			entries, err := a.Query(ctx, q)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				if tail.sent.delivered(entry) {
+					continue
+				}
+				select {
+				case tail.events <- entry:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				tail.sent.advance(entry)
+				if !opts.Ack {
+					if err = tail.Ack(entry); err != nil {
+						return err
+					}
+				}
+			}
+			from = to
+		}
+
+		if behind {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+		select {
+		case <-time.After(opts.Interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package domo
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivityLogService_Query(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"GET /v1/audit": {responseCode: 200, response: `[
+			{"userName": "Ann", "userId": 7, "objectName": "Orders", "objectId": "ds-1", "objectType": "DATA_SOURCE", "time": 1700000002000, "eventText": "Deleted"},
+			{"userName": "Ann", "userId": 7, "objectName": "Sales", "objectId": "100", "objectType": "PAGE", "time": 1700000001000, "eventText": "Viewed"},
+			{"userName": "Bob", "userId": 8, "objectName": "Returns", "objectId": "ds-2", "objectType": "DATA_SOURCE", "time": 1700000000000, "eventText": "deleted"}
+		]`},
+	}}
+	client := CreateTestClient(doer)
+
+	entries, err := client.ActivityLog.Query(context.Background(), ActivityQuery{Action: "Deleted"})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 2, len(entries), "Bad action filter")
+	assert.Equal(t, "Bob", entries[0].UserName, "Not oldest first")
+	assert.Equal(t, time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC), entries[0].At(), "Bad time")
+
+	_, err = client.ActivityLog.Query(context.Background(), ActivityQuery{Start: time.Now(), End: time.Now().Add(-time.Hour)})
+	assert.NotEqual(t, nil, err, "End before start accepted")
+
+	doer.routes["GET /v1/audit"] = testDoer{responseCode: 403, response: `{"status":403,"statusReason":"Forbidden","toe":"TEST"}`}
+	_, err = client.ActivityLog.Query(context.Background(), ActivityQuery{})
+	assert.NotEqual(t, nil, err, "Failure not reported")
+}
+
+func TestActivityCursor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "activity")
+	assert.Equal(t, nil, err, "Bad temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cursor.json")
+
+	cursor, err := LoadActivityCursor(path)
+	assert.Equal(t, nil, err, "Missing cursor file is an error")
+	assert.Equal(t, true, cursor == nil, "Cursor from nowhere")
+
+	ann := ActivityEntry{UserID: 7, Time: 1000, EventText: "Viewed"}
+	bob := ActivityEntry{UserID: 8, Time: 1000, EventText: "Viewed"}
+	cursor = &ActivityCursor{}
+	cursor.advance(ann)
+	assert.Equal(t, nil, cursor.Save(path), "Bad save")
+
+	cursor, err = LoadActivityCursor(path)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, true, cursor.delivered(ann), "Delivered twice")
+	assert.Equal(t, false, cursor.delivered(bob), "Same millisecond skipped")
+	assert.Equal(t, true, cursor.delivered(ActivityEntry{Time: 999}), "Older entry delivered")
+	cursor.advance(ActivityEntry{Time: 1001})
+	assert.Equal(t, 1, len(cursor.Seen), "Seen not reset")
+}
+
+// auditTestDoer three entries, whatever the query, for tails
+func auditTestDoer() *routeDoer {
+	return &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"GET /v1/audit": {responseCode: 200, response: `[
+			{"userId": 7, "objectType": "PAGE", "time": 1700000000000, "eventText": "Viewed"},
+			{"userId": 8, "objectType": "PAGE", "time": 1700000001000, "eventText": "Viewed"},
+			{"userId": 9, "objectType": "PAGE", "time": 1700000002000, "eventText": "Viewed"}
+		]`},
+	}}
+}
+
+func TestActivityTail_Ack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "activity")
+	assert.Equal(t, nil, err, "Bad temp dir")
+	defer os.RemoveAll(dir)
+	client := CreateTestClient(auditTestDoer())
+	opts := ActivityTailOptions{Cursor: filepath.Join(dir, "cursor.json"), Ack: true, Interval: time.Hour, Window: 100000 * time.Hour}
+	opts.Start = time.Unix(1700000000, 0)
+
+	// receive two entries, but only get as far as handling the first
+	ctx, cancel := context.WithCancel(context.Background())
+	tail, err := client.ActivityLog.Tail(ctx, opts)
+	assert.Equal(t, nil, err, "Bad error code")
+	first := <-tail.Events()
+	assert.Equal(t, nil, tail.Ack(first), "Bad ack")
+	<-tail.Events()
+	cancel()
+	for range tail.Events() {
+	}
+	assert.Equal(t, nil, tail.Err(), "Bad tail error")
+	assert.Equal(t, first.Time, tail.Cursor().Time, "Cursor moved past an entry not acknowledged")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	tail, err = client.ActivityLog.Tail(ctx, opts)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 8, (<-tail.Events()).UserID, "Entry not acknowledged was not sent again")
+}
+
+// windowDoer answers activity queries with nothing, recording the time each covers
+type windowDoer struct {
+	spans []time.Duration
+}
+
+func (wd *windowDoer) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/oauth/token" {
+		return tokenRoute.Do(req)
+	}
+	start, _ := strconv.ParseInt(req.URL.Query().Get("start"), 10, 64)
+	end, _ := strconv.ParseInt(req.URL.Query().Get("end"), 10, 64)
+	wd.spans = append(wd.spans, time.Duration(end-start)*time.Millisecond)
+	return testDoer{responseCode: 200, response: `[]`}.Do(req)
+}
+
+func TestActivityTail_window(t *testing.T) {
+	doer := &windowDoer{}
+	client := CreateTestClient(doer)
+	opts := ActivityTailOptions{Interval: time.Hour, Lag: time.Minute, Window: time.Hour}
+	opts.Start = time.Now().Add(-150 * time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tail, err := client.ActivityLog.Tail(ctx, opts)
+	assert.Equal(t, nil, err, "Bad error code")
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	for range tail.Events() {
+	}
+	assert.Equal(t, 3, len(doer.spans), "Caught up in the wrong number of queries")
+	for _, span := range doer.spans {
+		assert.Equal(t, true, span <= time.Hour, "Query wider than the window")
+	}
+}
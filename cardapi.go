@@ -0,0 +1,202 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// cardPageSize the number of cards asked for in each request of ListAll
+const cardPageSize = 50
+
+// CardService Card API service
+type CardService service
+
+// DataSourceIDs the IDs of the DataSets a card is built from
+func (c Card) DataSourceIDs() (ids []string) {
+	for _, source := range c.DataSources {
+		ids = append(ids, source.ID)
+	}
+	return
+}
+
+// Retrieve Retrieves the metadata of a card: its title, type, the pages it is on and the DataSets it is built from.
+// Definition
+// GET https://api.domo.com/v1/cards/{CARD_ID}
+// Returns
+// Returns a card object if a valid card ID was provided.
+func (c *CardService) Retrieve(ctx context.Context, cardID int) (card Card, err error) {
+	bodyBytes, err := c.client.sendJSON(ctx, "dashboard", "GET", fmt.Sprintf("%s/v1/cards/%d", c.client.baseURL, cardID), nil)
+	if err != nil {
+		return card, fmt.Errorf("Failed to retrieve card %d : %s", cardID, err)
+	}
+	if err = json.Unmarshal(bodyBytes, &card); err != nil {
+		err = fmt.Errorf("Unable to unmarshal card %d %s", cardID, err)
+	}
+	return
+}
+
+// List Get a list of cards in your Domo instance.
+// Definition
+// GET https://api.domo.com/v1/cards?limit={LIMIT}&offset={OFFSET}
+// Returns
+// Returns up to limit cards starting at offset, with their ids, titles and types.
+func (c *CardService) List(ctx context.Context, limit int, offset int) (cards []Card, err error) {
+	url := fmt.Sprintf("%s/v1/cards?limit=%d&offset=%d", c.client.baseURL, limit, offset)
+	bodyBytes, err := c.client.sendJSON(ctx, "dashboard", "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list cards : %s", err)
+	}
+	if err = json.Unmarshal(bodyBytes, &cards); err != nil {
+		err = fmt.Errorf("Unable to unmarshal list %s", err)
+	}
+	return
+}
+
+// ListAll Get every card in your Domo instance, a page at a time.
+// Returns
+// Returns all cards, as the list sends them.
+func (c *CardService) ListAll(ctx context.Context) (cards []Card, err error) {
+	for offset := 0; ; offset += cardPageSize {
+		page, err := c.List(ctx, cardPageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, page...)
+		if len(page) < cardPageSize {
+			logger(fmt.Sprintf("[CardService] ListAll : %d cards found", len(cards)))
+			return cards, nil
+		}
+	}
+}
+
+// CardIndex every card by the DataSets it is built from, as ByDataSet found them.
+// One index answers any number of ForDataSet and UsingColumn questions without reading the cards again.
+type CardIndex struct {
+	DataSets   map[string][]Card // The cards built from each DataSet, by DataSet ID
+	Unreadable []Card            // The cards that couldn't be retrieved, as the list describes them
+	Errors     map[int]error     // Why each unreadable card couldn't be retrieved, by card ID
+}
+
+// ByDataSet Maps every card back to the DataSets it is built from.
+// The list doesn't say which DataSets a card uses, so every card is retrieved. A card that can't be
+// is skipped and kept in the index's Unreadable, so one card doesn't stop the rest being mapped.
+// Returns
+// Returns the index of the cards, or an error if the cards couldn't be listed
+func (c *CardService) ByDataSet(ctx context.Context) (index *CardIndex, err error) {
+	list, err := c.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	index = &CardIndex{DataSets: make(map[string][]Card), Errors: make(map[int]error)}
+	for _, summary := range list {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		card, err := c.Retrieve(ctx, summary.ID)
+		if err != nil {
+			logger(fmt.Sprintf("[CardService] ByDataSet : skipped card %d %s", summary.ID, err))
+			index.Unreadable = append(index.Unreadable, summary)
+			index.Errors[summary.ID] = err
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, id := range card.DataSourceIDs() {
+			if !seen[id] {
+				seen[id] = true
+				index.DataSets[id] = append(index.DataSets[id], card)
+			}
+		}
+	}
+	return index, nil
+}
+
+// Err an error counting the cards that couldn't be retrieved, nil if there are none
+func (x *CardIndex) Err() error {
+	if len(x.Unreadable) == 0 {
+		return nil
+	}
+	var failed []string
+	for _, card := range x.Unreadable {
+		failed = append(failed, fmt.Sprintf("%d %s", card.ID, x.Errors[card.ID]))
+	}
+	return fmt.Errorf("Unable to retrieve %d cards: %s", len(x.Unreadable), strings.Join(failed, ", "))
+}
+
+// ForDataSet the cards built from a DataSet, in the order Domo lists them.
+// Unreadable cards may be built from it too.
+func (x *CardIndex) ForDataSet(datasetID string) []Card {
+	return x.DataSets[datasetID]
+}
+
+// UsingColumn the cards that use a column of a DataSet, and those that may: the cards built from the
+// DataSet whose columns Domo doesn't list, followed by the unreadable cards.
+// Column names are matched exactly, as Domo's are case sensitive.
+func (x *CardIndex) UsingColumn(datasetID string, column string) (uses []Card, unknown []Card) {
+	for _, card := range x.DataSets[datasetID] {
+		switch usesColumn(card, datasetID, column) {
+		case columnUsed:
+			uses = append(uses, card)
+		case columnUnknown:
+			unknown = append(unknown, card)
+		}
+	}
+	unknown = append(unknown, x.Unreadable...)
+	return
+}
+
+// ForDataSet Finds the cards built from a DataSet. To ask about several DataSets, call ByDataSet once
+// and use its index.
+// Returns
+// Returns the cards with their metadata, in the order Domo lists them, and an error naming any cards
+// that couldn't be retrieved
+func (c *CardService) ForDataSet(ctx context.Context, datasetID string) ([]Card, error) {
+	index, err := c.ByDataSet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return index.ForDataSet(datasetID), index.Err()
+}
+
+// UsingColumn Finds the cards that break if a column of a DataSet is dropped or renamed.
+// Column names are matched exactly, as Domo's are case sensitive. To ask about several columns,
+// call ByDataSet once and use its index.
+// Returns
+// Returns the cards that use the column, and the cards that may use it too: those built from the
+// DataSet whose columns Domo doesn't list, and those that couldn't be retrieved, which the error names.
+func (c *CardService) UsingColumn(ctx context.Context, datasetID string, column string) (uses []Card, unknown []Card, err error) {
+	index, err := c.ByDataSet(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	uses, unknown = index.UsingColumn(datasetID, column)
+	return uses, unknown, index.Err()
+}
+
+// The answers of usesColumn
+const (
+	columnUnused = iota
+	columnUsed
+	columnUnknown
+)
+
+// usesColumn whether a card uses a column of a DataSet, or can't tell because no columns are listed
+func usesColumn(card Card, datasetID string, column string) int {
+	listed := false
+	for _, source := range card.DataSources {
+		if source.ID != datasetID {
+			continue
+		}
+		for _, name := range source.Columns {
+			if name == column {
+				return columnUsed
+			}
+		}
+		listed = listed || len(source.Columns) > 0
+	}
+	if !listed {
+		return columnUnknown
+	}
+	return columnUnused
+}
@@ -0,0 +1,78 @@
+package domo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cardTestDoer two cards on the Orders DataSet, one listing its columns and one not, and one on Returns
+func cardTestDoer() *routeDoer {
+	return &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"GET /v1/cards": {responseCode: 200, response: `[
+			{"id": 1, "title": "Revenue", "type": "kpi"},
+			{"id": 2, "title": "Notes", "type": "doc"},
+			{"id": 3, "title": "Returns", "type": "kpi"}
+		]`},
+		"GET /v1/cards/1": {responseCode: 200, response: `{"id": 1, "title": "Revenue", "type": "kpi", "pageIds": [100],
+			"datasources": [{"dataSourceId": "ds-1", "dataSourceName": "Orders", "columns": ["amount", "region"]}]}`},
+		"GET /v1/cards/2": {responseCode: 200, response: `{"id": 2, "title": "Notes", "type": "doc", "pageIds": [100, 101],
+			"datasources": [{"dataSourceId": "ds-1", "dataSourceName": "Orders"}]}`},
+		"GET /v1/cards/3": {responseCode: 200, response: `{"id": 3, "title": "Returns", "type": "kpi", "pageIds": [],
+			"datasources": [{"dataSourceId": "ds-2", "dataSourceName": "Returns", "columns": ["region"]}, {"dataSourceId": "ds-1", "columns": ["amount"]}]}`},
+	}}
+}
+
+func TestCardService_Retrieve(t *testing.T) {
+	client := CreateTestClient(cardTestDoer())
+
+	card, err := client.Card.Retrieve(context.Background(), 2)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, "doc", card.Type, "Bad type")
+	assert.Equal(t, []int{100, 101}, card.PageIDs, "Bad pages")
+	assert.Equal(t, []string{"ds-1"}, card.DataSourceIDs(), "Bad datasources")
+
+	_, err = client.Card.Retrieve(context.Background(), 4)
+	assert.NotEqual(t, nil, err, "Missing card found")
+}
+
+func TestCardService_ByDataSet(t *testing.T) {
+	client := CreateTestClient(cardTestDoer())
+
+	index, err := client.Card.ByDataSet(context.Background())
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, nil, index.Err(), "Bad index error")
+	assert.Equal(t, 3, len(index.ForDataSet("ds-1")), "Bad cards of Orders")
+	assert.Equal(t, "Returns", index.ForDataSet("ds-2")[0].Title, "Bad cards of Returns")
+	uses, _ := index.UsingColumn("ds-2", "region")
+	assert.Equal(t, 3, uses[0].ID, "Bad card using region of Returns")
+
+	uses, unknown, err := client.Card.UsingColumn(context.Background(), "ds-1", "region")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 1, len(uses), "Bad cards using region")
+	assert.Equal(t, 1, uses[0].ID, "Bad card using region")
+	assert.Equal(t, 2, unknown[0].ID, "Card without columns not reported")
+
+	uses, _, _ = client.Card.UsingColumn(context.Background(), "ds-1", "Amount")
+	assert.Equal(t, 0, len(uses), "Column matched ignoring case")
+}
+
+func TestCardService_ByDataSet_unreadable(t *testing.T) {
+	doer := cardTestDoer()
+	doer.routes["GET /v1/cards"] = testDoer{responseCode: 200, response: `[{"id": 1, "title": "Revenue"}, {"id": 4, "title": "Secret"}, {"id": 3, "title": "Returns"}]`}
+	doer.routes["GET /v1/cards/4"] = testDoer{responseCode: 403, response: `{"status":403,"statusReason":"Forbidden","toe":"TEST"}`}
+	client := CreateTestClient(doer)
+
+	index, err := client.Card.ByDataSet(context.Background())
+	assert.Equal(t, nil, err, "Unreadable card stopped the index")
+	assert.Equal(t, 2, len(index.ForDataSet("ds-1")), "Cards after the unreadable one skipped")
+	assert.Equal(t, "Secret", index.Unreadable[0].Title, "Unreadable card not kept")
+	assert.NotEqual(t, nil, index.Err(), "Unreadable card not reported")
+
+	uses, unknown, err := client.Card.UsingColumn(context.Background(), "ds-1", "region")
+	assert.NotEqual(t, nil, err, "Unreadable card not reported")
+	assert.Equal(t, 1, uses[0].ID, "Bad card using region")
+	assert.Equal(t, 4, unknown[0].ID, "Unreadable card not among those that may use the column")
+}
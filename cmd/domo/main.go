@@ -0,0 +1,231 @@
+// Command domo runs jobs against a Domo instance.
+//
+// Usage:
+//
+//	domo replicate -driver postgres -dsn "..." -query "SELECT ..." -stream "Orders"
+//	domo import-users -file users.csv -report results.csv
+//	domo export-users -file users.csv
+//	domo sync-users -ldap-url ldaps://dc.example.com -base-dn "OU=Staff,DC=example,DC=com" -dry-run
+//	domo apply-layout -file sales.json -var region=EMEA -dry-run
+//
+// The API client id and secret come from -client-id and -secret, or from
+// DOMO_CLIENT_ID and DOMO_CLIENT_SECRET. Database drivers are compiled in with
+// build tags: postgres, mysql and sqlite. The sync-users command needs the ldap tag,
+// and YAML layouts need the yaml tag.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/davecb/domoStreamApi/pagelayout"
+	"github.com/davecb/domoStreamApi/replicate"
+	"github.com/davecb/domoStreamApi/usersync"
+)
+
+// commands the subcommands, by name
+var commands = map[string]func(args []string) error{
+	"replicate":    replicateCommand,
+	"import-users": importUsersCommand,
+	"export-users": exportUsersCommand,
+	"apply-layout": applyLayoutCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := commands[os.Args[1]](os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "domo %s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "usage: domo <command> [flags]\n\ncommands: %s\n", strings.Join(names, ", "))
+}
+
+// clientFlags adds the flags every command needs to reach Domo
+func clientFlags(flags *flag.FlagSet) func() *domo.Client {
+	clientID := flags.String("client-id", os.Getenv("DOMO_CLIENT_ID"), "Domo API client id")
+	secret := flags.String("secret", os.Getenv("DOMO_CLIENT_SECRET"), "Domo API client secret")
+	verbose := flags.Bool("v", false, "log requests")
+	return func() *domo.Client {
+		d := domo.New(*clientID, *secret)
+		d.SetLogging(*verbose)
+		return d
+	}
+}
+
+// replicateCommand copies the rows of a SQL query into a stream
+func replicateCommand(args []string) error {
+	flags := flag.NewFlagSet("replicate", flag.ExitOnError)
+	client := clientFlags(flags)
+	driver := flags.String("driver", "", "database/sql driver, one of "+strings.Join(sql.Drivers(), ", "))
+	dsn := flags.String("dsn", "", "data source name passed to the driver")
+	var opts replicate.Options
+	flags.StringVar(&opts.Query, "query", "", "SQL query, given the last watermark as its only argument when -watermark is set")
+	flags.StringVar(&opts.Stream, "stream", "", "name of the stream's DataSet, created if it doesn't exist")
+	flags.StringVar(&opts.Description, "description", "", "description of a new DataSet")
+	flags.StringVar(&opts.Watermark, "watermark", "", "column for incremental replication")
+	flags.StringVar(&opts.Start, "start", "", "watermark used before one has been saved")
+	flags.StringVar(&opts.StateFile, "state", "", "file that keeps the last watermark")
+	flags.IntVar(&opts.Upload.RowsPerPart, "rows", 10000, "rows in each part")
+	flags.IntVar(&opts.Upload.Parallel, "parallel", 4, "parts uploaded at the same time")
+	flags.IntVar(&opts.Upload.Retries, "retries", 3, "extra attempts for a failed part")
+	flags.Parse(args)
+
+	if *driver == "" || *dsn == "" {
+		flags.Usage()
+		return fmt.Errorf("-driver and -dsn are required")
+	}
+	if opts.Watermark != "" && opts.StateFile == "" {
+		return fmt.Errorf("-watermark needs -state to remember where it got to")
+	}
+
+	db, err := sql.Open(*driver, *dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := replicate.Run(context.Background(), client(), db, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("stream %d: %d rows in %d parts, execution %d, %s\n",
+		result.StreamID, result.Summary.Rows, result.Summary.Parts, result.Summary.ExecutionID, result.Summary.Duration)
+	if opts.Watermark != "" {
+		fmt.Printf("watermark: %s\n", result.Watermark)
+	}
+	return nil
+}
+
+// importUsersCommand creates and updates the users in a CSV file
+func importUsersCommand(args []string) error {
+	flags := flag.NewFlagSet("import-users", flag.ExitOnError)
+	client := clientFlags(flags)
+	file := flags.String("file", "", "CSV of users, with a header row")
+	report := flags.String("report", "", "file for the result of each row, standard output when empty")
+	var opts usersync.ImportOptions
+	flags.IntVar(&opts.Parallel, "parallel", 4, "users created or updated at the same time")
+	flags.BoolVar(&opts.CreateGroups, "create-groups", false, "create groups that don't exist")
+	flags.BoolVar(&opts.SendInvite, "invite", false, "email new users an invite")
+	flags.Parse(args)
+
+	if *file == "" {
+		flags.Usage()
+		return fmt.Errorf("-file is required")
+	}
+
+	in, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	rows, err := usersync.ReadCSV(in)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if *report != "" {
+		if out, err = os.Create(*report); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	results, err := usersync.Import(context.Background(), client(), rows, opts)
+	if werr := usersync.WriteResults(out, results); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// exportUsersCommand writes every user and their groups to a CSV file
+func exportUsersCommand(args []string) error {
+	flags := flag.NewFlagSet("export-users", flag.ExitOnError)
+	client := clientFlags(flags)
+	file := flags.String("file", "", "CSV file to write, standard output when empty")
+	flags.Parse(args)
+
+	out := os.Stdout
+	if *file != "" {
+		var err error
+		if out, err = os.Create(*file); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	return usersync.Export(context.Background(), client(), out)
+}
+
+// applyLayoutCommand brings a hierarchy of pages in line with a layout file
+func applyLayoutCommand(args []string) error {
+	flags := flag.NewFlagSet("apply-layout", flag.ExitOnError)
+	client := clientFlags(flags)
+	file := flags.String("file", "", "layout of the pages, in JSON or, with the yaml tag, YAML")
+	vars := varFlag{}
+	flags.Var(vars, "var", "name=value for ${name} in the layout, repeated for each variable")
+	var opts pagelayout.Options
+	flags.BoolVar(&opts.Prune, "prune", false, "delete pages and collections below managed pages that the layout doesn't name")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "report the changes without making them")
+	check := flags.Bool("check", false, "change nothing, and fail if Domo has drifted from the layout")
+	flags.Parse(args)
+
+	if *file == "" {
+		flags.Usage()
+		return fmt.Errorf("-file is required")
+	}
+	layout, err := pagelayout.Load(*file)
+	if err != nil {
+		return err
+	}
+	if err = layout.Expand(vars); err != nil {
+		return err
+	}
+
+	opts.DryRun = opts.DryRun || *check
+	report, err := pagelayout.Run(context.Background(), client(), layout, opts)
+	report.Print(os.Stdout)
+	if err == nil && *check && !report.InSync() {
+		err = fmt.Errorf("Domo has drifted from the layout: %d changes needed, %d unmanaged", len(report.Changes), len(report.Unmanaged))
+	}
+	return err
+}
+
+// varFlag collects repeated name=value flags
+type varFlag map[string]string
+
+func (v varFlag) String() string {
+	var pairs []string
+	for name, value := range v {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v varFlag) Set(pair string) error {
+	i := strings.Index(pair, "=")
+	if i <= 0 {
+		return fmt.Errorf("%q is not name=value", pair)
+	}
+	v[pair[:i]] = pair[i+1:]
+	return nil
+}
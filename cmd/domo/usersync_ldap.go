@@ -0,0 +1,76 @@
+//go:build ldap
+// +build ldap
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/davecb/domoStreamApi/usersync"
+)
+
+func init() {
+	commands["sync-users"] = syncUsersCommand
+}
+
+// syncUsersCommand brings Domo's users and groups in line with an LDAP directory
+func syncUsersCommand(args []string) error {
+	flags := flag.NewFlagSet("sync-users", flag.ExitOnError)
+	client := clientFlags(flags)
+	var source usersync.LDAP
+	var opts usersync.Options
+	flags.StringVar(&source.URL, "ldap-url", "", "directory address, such as ldaps://dc.example.com:636")
+	flags.StringVar(&source.BindDN, "bind-dn", "", "DN to bind as")
+	flags.StringVar(&source.Password, "bind-password", os.Getenv("DOMO_LDAP_PASSWORD"), "password for -bind-dn, or DOMO_LDAP_PASSWORD")
+	flags.StringVar(&source.BaseDN, "base-dn", "", "where to search for users")
+	flags.StringVar(&source.Filter, "filter", "", "search filter for users")
+	groups := flags.String("groups", "", "comma separated groups to manage, every group a user is in when empty")
+	protected := flags.String("protect", "", "comma separated emails never changed")
+	removal := flags.String("removal", "deactivate", "what happens to users missing from the directory: deactivate, delete or keep")
+	flags.IntVar(&opts.DeactivatedRoleID, "deactivated-role", 0, "role id given to deactivated users, needed with -removal deactivate")
+	flags.Float64Var(&opts.MaxRemoved, "max-removed", usersync.DefaultMaxRemoved, "largest share of users that may be removed")
+	role := flags.String("default-role", string(domo.RoleParticipant), "role of new users")
+	flags.BoolVar(&opts.SendInvite, "invite", false, "email new users an invite")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "report the changes without making them")
+	flags.Parse(args)
+
+	if source.URL == "" || source.BaseDN == "" {
+		flags.Usage()
+		return fmt.Errorf("-ldap-url and -base-dn are required")
+	}
+	switch *removal {
+	case "deactivate":
+		if opts.DeactivatedRoleID == 0 {
+			return fmt.Errorf("-removal deactivate needs -deactivated-role, or departed users keep their access")
+		}
+		opts.Removal = usersync.Deactivate
+	case "delete":
+		opts.Removal = usersync.Delete
+	case "keep":
+		opts.Removal = usersync.Keep
+	default:
+		return fmt.Errorf("-removal must be deactivate, delete or keep")
+	}
+	opts.Groups = splitList(*groups)
+	opts.Protected = splitList(*protected)
+	opts.DefaultRole = domo.Role(*role)
+
+	report, err := usersync.Run(context.Background(), client(), &source, opts)
+	report.Print(os.Stdout)
+	return err
+}
+
+// splitList the non-empty items of a comma separated list
+func splitList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return
+}
@@ -37,7 +37,7 @@ func (d *DataSetService) Get(name string) (list DatasetSummary, err error) {
 // Returns all DataSet objects that meet argument criteria from original request.
 func (d *DataSetService) List() (list Datasets, err error) {
 	d.client.getAccessToken("data")
-	url := fmt.Sprintf("%s/v1/datasets", baseURL)
+	url := fmt.Sprintf("%s/v1/datasets", d.client.baseURL)
 	bodyBytes, _, err := d.client.genericGET(url, nil)
 
 	if err != nil {
@@ -64,7 +64,7 @@ func (d *DataSetService) Retrieve(id string) (list Dataset, err error) {
 	err =  errors.New("domo.Retrieve has never worked for me")
 	return
 	d.client.getAccessToken("data")
-	url := fmt.Sprintf("%s/v1/datasets/%s", baseURL, id)
+	url := fmt.Sprintf("%s/v1/datasets/%s", d.client.baseURL, id)
 	bodyBytes, _, err := d.client.genericGET(url, nil)
 
 	if err != nil {
@@ -88,7 +88,7 @@ func (d *DataSetService) Retrieve(id string) (list Dataset, err error) {
 //{"sql": "SELECT * FROM table"}
 func (d *DataSetService) Query(datasetID, query string) (data string, err error) {
 	d.client.getAccessToken("data")
-	url := fmt.Sprintf("%s/v1/datasets", baseURL, datasetID)
+	url := fmt.Sprintf("%s/v1/datasets", d.client.baseURL, datasetID)
 	body := strings.NewReader(query)
 	
 	header := map[string]string{
@@ -116,7 +116,7 @@ func (d *DataSetService) Query(datasetID, query string) (data string, err error)
 func (d *DataSetService) Export(datasetID string) (data string, err error) {
 
 	d.client.getAccessToken("data")
-	url := fmt.Sprintf("%s/v1/datasets/%s/data?includeHeader=true&fileName=data.csv", baseURL, datasetID)
+	url := fmt.Sprintf("%s/v1/datasets/%s/data?includeHeader=true&fileName=data.csv", d.client.baseURL, datasetID)
 	header := map[string]string{
 		"Accept": "text/csv",
 	}
@@ -140,7 +140,7 @@ func (d *DataSetService) Export(datasetID string) (data string, err error) {
 // The returned object will have DataSet attributes based on the information that was provided when DataSet was created.
 func (d *DataSetService) Create(schema string) (data *Dataset, err error) {
 	d.client.getAccessToken("data")
-	url := fmt.Sprintf("%s/v1/datasets", baseURL)
+	url := fmt.Sprintf("%s/v1/datasets", d.client.baseURL)
 	body := strings.NewReader(schema)
 
 	header := map[string]string{
@@ -175,7 +175,7 @@ func (d *DataSetService) Create(schema string) (data *Dataset, err error) {
 // Returns a response of success or error for the outcome of data being imported into DataSet.
 func (d *DataSetService) Import(datasetID string, payload string) (err error) {
 	d.client.getAccessToken("data")
-	url := fmt.Sprintf("%s/v1/datasets/%s/data", baseURL, datasetID)
+	url := fmt.Sprintf("%s/v1/datasets/%s/data", d.client.baseURL, datasetID)
 	body := strings.NewReader(payload)
 
 	header := map[string]string{
@@ -201,7 +201,7 @@ func (d *DataSetService) Import(datasetID string, payload string) (err error) {
 // Returns an empty response. HTTP/1.1 204 No Content
 func (d *DataSetService) Delete(datasetID string) (err error) {
 	d.client.getAccessToken("data")
-	url := fmt.Sprintf("%s/v1/datasets/%s", baseURL, datasetID)
+	url := fmt.Sprintf("%s/v1/datasets/%s", d.client.baseURL, datasetID)
 	statusCode, err := d.client.genericDELETE(url, nil)
 
 	if statusCode != 204 {
@@ -220,7 +220,7 @@ func (d *DataSetService) Delete(datasetID string) (err error) {
 func (d *DataSetService) Update(datasetID string, schema string) (data *Dataset, err error) {
 
 	d.client.getAccessToken("data")
-	url := fmt.Sprintf("%s/v1/datasets/%s", baseURL, datasetID)
+	url := fmt.Sprintf("%s/v1/datasets/%s", d.client.baseURL, datasetID)
 	body := strings.NewReader(schema)
 
 	header := map[string]string{"Content-Type": "application/json"}
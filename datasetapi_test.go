@@ -0,0 +1,56 @@
+package domo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataSetService_List(t *testing.T) {
+	d := CreateTestClient(fixtureDoer(t, "datasets.json"))
+
+	list, err := d.DataSet.List()
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 2, len(list), "Bad number of datasets")
+	assert.Equal(t, "aa4d2db5-8504-49d8-9816-93b4861ce8af", list[0].ID, "Bad id")
+	assert.Equal(t, "kafka_jl_purchase", list[0].Name, "Bad name")
+	assert.Equal(t, 99267, list[0].Rows, "Bad rows")
+	assert.Equal(t, 7, list[0].Columns, "Bad columns")
+	assert.Equal(t, Owner{ID: 915083994, Name: "Chris Joyce"}, list[0].Owner, "Bad owner")
+	assert.Equal(t, time.Date(2018, 2, 6, 9, 37, 12, 0, time.UTC), list[0].UpdatedAt, "Bad updatedAt")
+	assert.Equal(t, "kafka_jl_customer", list[1].Name, "Bad name")
+}
+
+func TestDataSetService_Get(t *testing.T) {
+	tests := []struct {
+		name    string
+		dataset string
+		want    DatasetSummary
+	}{
+		{
+			name:    "found",
+			dataset: "kafka_jl_customer",
+			want: DatasetSummary{
+				ID:          "ed8d30cb-1ad5-4eb1-9c5c-b13324f6c5db",
+				Name:        "kafka_jl_customer",
+				Rows:        18877,
+				Columns:     7,
+				Description: "Customer events from Kafka, there is no checking for loss or duplication",
+			},
+		},
+		{
+			name:    "missing",
+			dataset: "kafka_jl_refund",
+			want:    DatasetSummary{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := CreateTestClient(fixtureDoer(t, "datasets.json"))
+			got, err := d.DataSet.Get(tt.dataset)
+			assert.Equal(t, nil, err, "Bad error code")
+			assert.Equal(t, tt.want, got, "Bad dataset")
+		})
+	}
+}
@@ -33,11 +33,16 @@
 package domo
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -49,7 +54,29 @@ type Client struct {
 	secret      string
 	accessToken string
 	expiresIn   time.Time
+	scopes      []string // The scopes accessToken was granted, which only ever grow
 	myDoer      Doer
+	baseURL     string // The address of the Domo API, without a trailing slash
+
+	// interceptors wrap myDoer, outermost first
+	interceptors []Interceptor
+
+	// tracer starts a span for every request, when it is set
+	tracer Tracer
+
+	// metrics counts requests, token refreshes and uploads, when it is set
+	metrics Metrics
+
+	// directory caches the users for lookups by email and employee number
+	directory directoryCache
+
+	// groupBulk remembers whether the instance has bulk group membership endpoints
+	groupBulk bulkSupport
+
+	// tokenLock guards accessToken, expiresIn and scopes, refreshLock makes sure
+	// only one goroutine at a time asks Domo for a new token
+	tokenLock   sync.RWMutex
+	refreshLock sync.Mutex
 
 	service
 
@@ -59,6 +86,10 @@ type Client struct {
 	Page    *PageService
 	User    *UserService
 	Group   *GroupService
+	Role    *RoleService
+	Card    *CardService
+
+	ActivityLog *ActivityLogService
 }
 
 type service struct {
@@ -67,15 +98,15 @@ type service struct {
 
 var logging bool
 var debuglog bool
-var baseURL string
 
 //New Domo Client
 func New(clientID string, secret string) *Client {
 	d := Client{}
 	d.clientID = clientID
 	d.secret = secret
-	baseURL = "https://api.domo.com"
+	d.baseURL = "https://api.domo.com"
 	d.myDoer = http.DefaultClient
+	d.directory.ttl = DefaultDirectoryTTL
 	d.service.client = &d
 
 	d.DataSet = (*DataSetService)(&d.service)
@@ -83,6 +114,9 @@ func New(clientID string, secret string) *Client {
 	d.Page = (*PageService)(&d.service)
 	d.User = (*UserService)(&d.service)
 	d.Group = (*GroupService)(&d.service)
+	d.Role = (*RoleService)(&d.service)
+	d.Card = (*CardService)(&d.service)
+	d.ActivityLog = (*ActivityLogService)(&d.service)
 
 	return &d
 }
@@ -111,6 +145,20 @@ func (d *Client) SetDebugLogging(setlogging bool) {
 	}
 }
 
+// SetBaseURL set the address of the Domo API
+//
+// Defaults to https://api.domo.com, and only applies to this Client
+func (d *Client) SetBaseURL(url string) {
+	d.baseURL = strings.TrimSuffix(url, "/")
+}
+
+// SetDoer set what sends the Client's requests
+//
+// Defaults to http.DefaultClient
+func (d *Client) SetDoer(doer Doer) {
+	d.myDoer = doer
+}
+
 // debuglogger method to handle debug logging
 //
 // All log records with be prefixed with [DomoClient]
@@ -135,9 +183,18 @@ func logger(logthis string) {
 // Once a user has been authenticated, they can then create an access token to authorize what the scope
 // of functionality will be available for each API for that specific access token.
 //
+// The token also carries every scope asked for before, so it can be used with all of them.
+//
 // Returns a oAuth token
 func (d *Client) GetToken(scope string) string {
 	d.getAccessToken(scope)
+	return d.token()
+}
+
+// token returns the current access token, which is empty until one has been fetched
+func (d *Client) token() string {
+	d.tokenLock.RLock()
+	defer d.tokenLock.RUnlock()
 	return d.accessToken
 }
 
@@ -146,6 +203,8 @@ func (d *Client) GetToken(scope string) string {
 // a user must first be authenticated (prove that they are whom they say they are) through a client ID and client secret.
 // Once a user has been authenticated, they can then create an access token to authorize what the scope
 // of functionality will be available for each API for that specific access token.
+// The client holds a single token, so one asked for a scope it doesn't have yet is
+// granted every scope the client has used, and requests of any scope can share it.
 // Definition
 // GET https://api.domo.com/oauth/token
 // Returns
@@ -153,22 +212,32 @@ func (d *Client) GetToken(scope string) string {
 func (d *Client) getAccessToken(scope string) error {
 	var err error
 
+	d.refreshLock.Lock()
+	defer d.refreshLock.Unlock()
+
+	d.tokenLock.RLock()
 	now := time.Now()
 	diff := d.expiresIn.Sub(now).Seconds()
 	remaining := int64(diff)
+	scopes, covered := withScope(d.scopes, scope)
+	d.tokenLock.RUnlock()
 
-	if remaining < 60 {
+	if remaining < 60 || !covered {
+		defer func() { d.observe(func(m Metrics) { m.ObserveTokenRefresh(scope, err) }) }()
 
-		d.accessToken = ""
-		url := fmt.Sprintf("%s/oauth/token?grant_type=client_credentials&scope=%s", baseURL, scope)
-		bodyBytes, statusCode, genericErr := d.genericGET(url, nil)
+		// The current token stays in use by other requests until its replacement arrives
+		url := fmt.Sprintf("%s/oauth/token?grant_type=client_credentials&scope=%s", d.baseURL, strings.Join(scopes, "%20"))
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.clientID + ":" + d.secret))
+		bodyBytes, statusCode, genericErr := d.genericGET(url, map[string]string{"Authorization": "Basic " + credentials})
 		if genericErr != nil {
-			return genericErr
+			err = genericErr
+			return err
 		}
 
 		if statusCode != 200 {
 
-			return fmt.Errorf("Unauthorized")
+			err = fmt.Errorf("Unauthorized")
+			return err
 		}
 		data := new(Access)
 		err = json.Unmarshal(bodyBytes, &data)
@@ -177,8 +246,11 @@ func (d *Client) getAccessToken(scope string) error {
 		}
 
 		now := time.Now()
+		d.tokenLock.Lock()
 		d.accessToken = data.AccessToken
 		d.expiresIn = now.Add(time.Second * time.Duration(data.ExpiresIn))
+		d.scopes = scopes
+		d.tokenLock.Unlock()
 
 	} else {
 		logdebug("Token is valid for > 60 seconds")
@@ -186,17 +258,35 @@ func (d *Client) getAccessToken(scope string) error {
 	return err
 }
 
+// withScope the scopes a token needs to also be used for scope, and whether scopes already has it
+func withScope(scopes []string, scope string) ([]string, bool) {
+	for _, have := range scopes {
+		if have == scope {
+			return scopes, true
+		}
+	}
+	return append(append([]string(nil), scopes...), scope), false
+}
+
 // Doer to make testing easer !
 type Doer interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
 func (d *Client) genericRequest(url string, method string, body io.Reader, headers map[string]string) (bodyBytes []byte, statusCode int, err error) {
+	return d.genericRequestContext(context.Background(), url, method, body, headers)
+}
+
+// genericRequestContext sends a request as part of the work in ctx, tracing it when there is a tracer
+func (d *Client) genericRequestContext(ctx context.Context, url string, method string, body io.Reader, headers map[string]string) (bodyBytes []byte, statusCode int, err error) {
 
 	logdebug(fmt.Sprintf("URL : %s", url))
 	logdebug(fmt.Sprintf("Reduest Body : %v", body))
 
-	req, err := http.NewRequest(method, url, body)
+	ctx, span := d.startSpan(ctx, "domo "+method, func() []Attribute { return requestAttributes(method, url) })
+	defer func() { endRequestSpan(span, statusCode, bodyBytes, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return
 	}
@@ -217,15 +307,24 @@ func (d *Client) genericRequest(url string, method string, body io.Reader, heade
 		logdebug(fmt.Sprintf("head : %s %s", "Accept", "application/json"))
 	}
 
-	if d.accessToken == "" {
+	switch token := d.token(); {
+	case req.Header.Get("Authorization") != "":
+		// The caller authenticates the request itself, as the token request does
+	case token == "":
 		req.SetBasicAuth(d.clientID, d.secret)
-	} else {
-		bearer := fmt.Sprintf("bearer %s", d.accessToken)
+	default:
+		bearer := fmt.Sprintf("bearer %s", token)
 		req.Header.Set("Authorization", bearer)
 		logdebug(fmt.Sprintf("head : %s %s", "Authorization", bearer))
 	}
 
-	resp, err := d.myDoer.Do(req)
+	started := time.Now()
+	resp, err := d.doer().Do(req)
+	if d.metrics != nil {
+		defer func() {
+			d.metrics.ObserveRequest(requestService(url), method, statusCode, time.Since(started))
+		}()
+	}
 
 	if err != nil {
 		fmt.Println(err)
@@ -258,11 +357,52 @@ func (d *Client) genericPUT(url string, body io.Reader, headers map[string]strin
 	return d.genericRequest(url, "PUT", body, headers)
 }
 
+func (d *Client) genericPATCH(url string, body io.Reader, headers map[string]string) (bodyBytes []byte, statusCode int, err error) {
+	return d.genericRequest(url, "PATCH", body, headers)
+}
+
 func (d *Client) genericDELETE(url string, headers map[string]string) (statusCode int, err error) {
 	_, statusCode, err = d.genericRequest(url, "DELETE", nil, headers)
 	return
 }
 
+// sendJSON makes a request with the access token for scope and v, if it isn't nil, as its JSON body.
+// An answer other than success is a statusError.
+func (d *Client) sendJSON(ctx context.Context, scope string, method string, url string, v interface{}) (bodyBytes []byte, err error) {
+	var body io.Reader
+	if v != nil {
+		payload, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to marshal %s", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	d.getAccessToken(scope)
+	header := map[string]string{"Content-Type": "application/json"}
+	bodyBytes, statusCode, err := d.genericRequestContext(ctx, url, method, body, header)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to reach Domo API %s", err)
+	}
+
+	logger(fmt.Sprintf("%s %s Status Code : %d", method, url, statusCode))
+	if statusCode >= 300 {
+		message, _ := bytesToErrorMessage(bodyBytes)
+		return bodyBytes, &statusError{statusCode: statusCode, reason: message.StatusReason}
+	}
+	return bodyBytes, nil
+}
+
+// statusError Domo answered, but not with success
+type statusError struct {
+	statusCode int
+	reason     string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%d %s", e.statusCode, e.reason)
+}
+
 func bytesToErrorMessage(bodyBytes []byte) (errorMessage ErrorMessage, err error) {
 	err = json.Unmarshal(bodyBytes, &errorMessage)
 	return errorMessage, err
@@ -2,10 +2,14 @@ package domo
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -32,6 +36,101 @@ func (nd testDoer) Do(*http.Request) (*http.Response, error) {
 	}, nil
 }
 
+// routeDoer answers each request with the testDoer registered for its method and path,
+// for example "PUT /v1/streams/1/executions/4/part/1". A route ending in * matches any path
+// with that prefix. Unknown routes get a 404. A route listed in failures answers with
+// a 500 that many times before it starts to succeed. Every request seen is recorded.
+type routeDoer struct {
+	sync.Mutex
+	routes   map[string]testDoer
+	failures map[string]int
+	requests []string
+	bodies   map[string]string
+}
+
+func (rd *routeDoer) Do(req *http.Request) (*http.Response, error) {
+	rd.Lock()
+	defer rd.Unlock()
+
+	key := req.Method + " " + req.URL.Path
+	rd.requests = append(rd.requests, key)
+	if req.Body != nil {
+		body, _ := ioutil.ReadAll(req.Body)
+		if rd.bodies == nil {
+			rd.bodies = make(map[string]string)
+		}
+		rd.bodies[key] = string(body)
+	}
+
+	if rd.failures[key] > 0 {
+		rd.failures[key]--
+		return testDoer{responseCode: 500, response: `{"status":500,"statusReason":"Internal Server Error","toe":"TEST"}`}.Do(req)
+	}
+
+	if doer, ok := rd.routes[key]; ok {
+		return doer.Do(req)
+	}
+	match := ""
+	for route := range rd.routes {
+		prefix := strings.TrimSuffix(route, "*")
+		if prefix != route && strings.HasPrefix(key, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match != "" {
+		return rd.routes[match+"*"].Do(req)
+	}
+	return testDoer{responseCode: 404, response: `{"status":404,"statusReason":"Not Found","toe":"TEST"}`}.Do(req)
+}
+
+// count how many requests were made to a route
+func (rd *routeDoer) count(key string) (n int) {
+	rd.Lock()
+	defer rd.Unlock()
+	for _, r := range rd.requests {
+		if r == key || (strings.HasSuffix(key, "*") && strings.HasPrefix(r, strings.TrimSuffix(key, "*"))) {
+			n++
+		}
+	}
+	return
+}
+
+// fixtureDoer answers requests from a fixture in domotest/testdata, recorded by domotest.Recorder,
+// matching on method and path. The tests of package domo can't import domotest, so the recorded
+// exchanges are loaded into a routeDoer, which hands out its own access token.
+func fixtureDoer(t *testing.T, name string) *routeDoer {
+	data, err := ioutil.ReadFile(filepath.Join("domotest", "testdata", name))
+	if err != nil {
+		t.Fatalf("Unable to read fixture %s", err)
+	}
+	var interactions []struct {
+		Method   string          `json:"method"`
+		Path     string          `json:"path"`
+		Status   int             `json:"status"`
+		Response json.RawMessage `json:"response"`
+	}
+	if err = json.Unmarshal(data, &interactions); err != nil {
+		t.Fatalf("Unable to unmarshal fixture %s : %s", name, err)
+	}
+
+	doer := &routeDoer{routes: map[string]testDoer{"GET /oauth/token": tokenRoute}}
+	for _, interaction := range interactions {
+		if interaction.Path == "/oauth/token" {
+			continue
+		}
+		response := string(interaction.Response)
+		var text string
+		if strings.HasPrefix(response, `"`) && json.Unmarshal(interaction.Response, &text) == nil {
+			response = text
+		}
+		doer.routes[interaction.Method+" "+interaction.Path] = testDoer{responseCode: interaction.Status, response: response}
+	}
+	return doer
+}
+
+// tokenRoute the access token reply every routeDoer needs
+var tokenRoute = testDoer{responseCode: 200, response: `{"access_token": "token","token_type": "bearer","expires_in": 3599}`}
+
 func ExampleNew() {
 	d := New("ClientID", "secret")
 
@@ -101,6 +200,15 @@ func TestSetLogging(t *testing.T) {
 	}
 }
 
+func TestClient_SetBaseURL(t *testing.T) {
+	one := New("<clientID>", "<secret>")
+	one.SetBaseURL("http://one.invalid/")
+	two := New("<clientID>", "<secret>")
+
+	assert.Equal(t, "http://one.invalid", one.baseURL, "Bad base URL")
+	assert.Equal(t, "https://api.domo.com", two.baseURL, "Base URL shared between clients")
+}
+
 func TestClient_genericRequest(t *testing.T) {
 	type fields struct {
 		clientID    string
@@ -530,6 +638,32 @@ func TestClient_GetToken(t *testing.T) {
 	}
 }
 
+// scopeDoer hands out tokens, recording the scopes each was asked for and how it was authorized
+type scopeDoer struct {
+	scopes []string
+	auth   []string
+}
+
+func (sd *scopeDoer) Do(req *http.Request) (*http.Response, error) {
+	sd.scopes = append(sd.scopes, req.URL.Query().Get("scope"))
+	sd.auth = append(sd.auth, strings.Fields(req.Header.Get("Authorization"))[0])
+	return tokenRoute.Do(req)
+}
+
+func TestClient_getAccessToken_scopes(t *testing.T) {
+	doer := &scopeDoer{}
+	d := &Client{clientID: "id", secret: "secret", myDoer: doer}
+
+	assert.Equal(t, nil, d.getAccessToken("data"), "Bad error code")
+	assert.Equal(t, nil, d.getAccessToken("data"), "Bad error code")
+	assert.Equal(t, []string{"data"}, doer.scopes, "Token not reused for the same scope")
+
+	assert.Equal(t, nil, d.getAccessToken("user"), "Bad error code")
+	assert.Equal(t, nil, d.getAccessToken("data"), "Bad error code")
+	assert.Equal(t, []string{"data", "data user"}, doer.scopes, "Token not widened to a new scope")
+	assert.Equal(t, []string{"Basic", "Basic"}, doer.auth, "Token not asked for with the credentials")
+}
+
 func ExampleClient_GetToken() {
 
 	// Normal use would be
@@ -0,0 +1,44 @@
+package domotest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+var activityRoutes = []route{
+	{"GET", "/v1/audit", (*Server).listActivity},
+}
+
+// AddActivity records entries in the activity log
+func (s *Server) AddActivity(entries ...domo.ActivityEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activity = append(s.activity, entries...)
+	sort.SliceStable(s.activity, func(i, j int) bool { return s.activity[i].Time < s.activity[j].Time })
+}
+
+// GET /v1/audit the entries between start and end, in milliseconds, of a user and a kind of object
+func (s *Server) listActivity(w http.ResponseWriter, r *http.Request, params []string) {
+	query := r.URL.Query()
+	start, serr := strconv.ParseInt(query.Get("start"), 10, 64)
+	end, eerr := strconv.ParseInt(query.Get("end"), 10, 64)
+	if serr != nil || eerr != nil || end < start {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	user, _ := strconv.Atoi(query.Get("user"))
+	objectType := query.Get("objectType")
+
+	var found []domo.ActivityEntry
+	for _, entry := range s.activity {
+		if entry.Time < start || entry.Time > end || (user != 0 && entry.UserID != user) || (objectType != "" && entry.ObjectType != objectType) {
+			continue
+		}
+		found = append(found, entry)
+	}
+	from, to := window(r, len(found))
+	s.reply(w, http.StatusOK, append([]domo.ActivityEntry{}, found[from:to]...))
+}
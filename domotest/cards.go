@@ -0,0 +1,84 @@
+package domotest
+
+import (
+	"net/http"
+	"sort"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+var cardRoutes = []route{
+	{"GET", "/v1/cards", (*Server).listCards},
+	{"GET", "/v1/cards/{}", (*Server).retrieveCard},
+}
+
+// AddCard stores a card, giving it an id if it has none.
+// The pages it is on are the pages whose CardIDs list it.
+func (s *Server) AddCard(card domo.Card) domo.Card {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if card.ID == 0 {
+		card.ID = s.id()
+	}
+	if card.OwnerID == 0 {
+		card.OwnerID = s.Owner.ID
+	}
+	s.cards[card.ID] = &card
+	return s.cardView(&card)
+}
+
+func (s *Server) cardIDs() (ids []int) {
+	for id := range s.cards {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return
+}
+
+// cardView the card with the pages it is on, never sending null for a list
+func (s *Server) cardView(card *domo.Card) domo.Card {
+	view := *card
+	view.PageIDs = []int{}
+	for _, id := range s.pageIDs() {
+		for _, cardID := range s.pages[id].CardIDs {
+			if cardID == card.ID {
+				view.PageIDs = append(view.PageIDs, id)
+				break
+			}
+		}
+	}
+	if view.DataSources == nil {
+		view.DataSources = []domo.CardDataSource{}
+	}
+	return view
+}
+
+// GET /v1/cards lists the cards without their pages and DataSets
+func (s *Server) listCards(w http.ResponseWriter, r *http.Request, params []string) {
+	type summary struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+		Type  string `json:"type"`
+	}
+	ids := s.cardIDs()
+	from, to := window(r, len(ids))
+	list := []summary{}
+	for _, id := range ids[from:to] {
+		list = append(list, summary{id, s.cards[id].Title, s.cards[id].Type})
+	}
+	s.reply(w, http.StatusOK, list)
+}
+
+// GET /v1/cards/{id}
+func (s *Server) retrieveCard(w http.ResponseWriter, r *http.Request, params []string) {
+	id, ok := s.intParam(w, params[0])
+	if !ok {
+		return
+	}
+	card := s.cards[id]
+	if card == nil {
+		s.fail(w, http.StatusNotFound)
+		return
+	}
+	s.reply(w, http.StatusOK, s.cardView(card))
+}
@@ -0,0 +1,413 @@
+package domotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// group a Group and the ids of its members
+type group struct {
+	domo.Group
+	members []int
+}
+
+var userRoutes = []route{
+	{"GET", "/v1/users", (*Server).listUsers},
+	{"POST", "/v1/users", (*Server).createUser},
+	{"GET", "/v1/users/{}", (*Server).retrieveUser},
+	{"PUT", "/v1/users/{}", (*Server).updateUser},
+	{"DELETE", "/v1/users/{}", (*Server).deleteUser},
+}
+
+var groupRoutes = []route{
+	{"GET", "/v1/groups", (*Server).listGroups},
+	{"POST", "/v1/groups", (*Server).createGroup},
+	{"GET", "/v1/groups/{}", (*Server).retrieveGroup},
+	{"PUT", "/v1/groups/{}", (*Server).updateGroup},
+	{"DELETE", "/v1/groups/{}", (*Server).deleteGroup},
+	{"GET", "/v1/groups/{}/users", (*Server).listGroupUsers},
+	{"PUT", "/v1/groups/{}/users/{}", (*Server).addGroupUser},
+	{"DELETE", "/v1/groups/{}/users/{}", (*Server).removeGroupUser},
+}
+
+var roleRoutes = []route{
+	{"GET", "/v1/roles", (*Server).listRoles},
+}
+
+// AddUser stores a user, giving it an id if it has none
+func (s *Server) AddUser(user domo.User) domo.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.addUser(user)
+}
+
+func (s *Server) addUser(user domo.User) *domo.User {
+	if user.ID == 0 {
+		user.ID = s.id()
+	}
+	s.resolveRole(&user)
+	now := time.Now().UTC()
+	user.CreatedAt, user.UpdatedAt = now, now
+	s.users[user.ID] = &user
+	return &user
+}
+
+// Users every user, by id
+func (s *Server) Users() []domo.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := []domo.User{}
+	for _, id := range s.userIDs() {
+		users = append(users, s.userView(s.users[id]))
+	}
+	return users
+}
+
+// AddGroup stores a group with the given members, giving it an id if it has none
+func (s *Server) AddGroup(g domo.Group, members ...int) domo.Group {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g.ID == 0 {
+		g.ID = s.id()
+	}
+	s.groups[g.ID] = &group{Group: g, members: members}
+	return s.groupView(s.groups[g.ID])
+}
+
+// Members the ids of the members of a group, nil if there is no such group
+func (s *Server) Members(groupID int) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g := s.groups[groupID]; g != nil {
+		return append([]int{}, g.members...)
+	}
+	return nil
+}
+
+func (s *Server) userIDs() (ids []int) {
+	for id := range s.users {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return
+}
+
+// userView the user and the groups it is in
+func (s *Server) userView(user *domo.User) domo.User {
+	view := *user
+	view.Groups = nil
+	var ids []int
+	for id, g := range s.groups {
+		for _, member := range g.members {
+			if member == user.ID {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		view.Groups = append(view.Groups, struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		}{id, s.groups[id].Name})
+	}
+	return view
+}
+
+// validUser whether a user has what Domo insists on, and a role it knows
+func (s *Server) validUser(user *domo.User) bool {
+	if user.Name == "" || user.Email == "" {
+		return false
+	}
+	if !s.resolveRole(user) {
+		return false
+	}
+	for id, other := range s.users {
+		if id != user.ID && strings.EqualFold(other.Email, user.Email) {
+			return false
+		}
+	}
+	return true
+}
+
+// AddRole stores a custom role, giving it an id if it has none
+func (s *Server) AddRole(role domo.RoleDetail) domo.RoleDetail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if role.ID == 0 {
+		role.ID = s.id()
+	}
+	s.roles[role.ID] = &role
+	return role
+}
+
+// resolveRole fills in the role name from its id, or the id from its name, whichever the user was given.
+// An id wins over a name, as it does in Domo.
+func (s *Server) resolveRole(user *domo.User) bool {
+	if role := s.roles[user.RoleID]; role != nil {
+		user.Role = role.Name
+		return true
+	}
+	if user.RoleID != 0 {
+		return false
+	}
+	for _, role := range s.roles {
+		if role.Name == user.Role {
+			user.RoleID = role.ID
+			return true
+		}
+	}
+	return user.Role == ""
+}
+
+// GET /v1/roles
+func (s *Server) listRoles(w http.ResponseWriter, r *http.Request, params []string) {
+	ids := make([]int, 0, len(s.roles))
+	for id := range s.roles {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	list := []domo.RoleDetail{}
+	for _, id := range ids {
+		role := *s.roles[id]
+		role.UserCount = 0
+		for _, user := range s.users {
+			if user.RoleID == id {
+				role.UserCount++
+			}
+		}
+		list = append(list, role)
+	}
+	s.reply(w, http.StatusOK, list)
+}
+
+// lookupUser the user named by the path segment, failing the request with 404 if there is none
+func (s *Server) lookupUser(w http.ResponseWriter, param string) *domo.User {
+	id, ok := s.intParam(w, param)
+	if !ok {
+		return nil
+	}
+	user := s.users[id]
+	if user == nil {
+		s.fail(w, http.StatusNotFound)
+	}
+	return user
+}
+
+// GET /v1/users
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request, params []string) {
+	ids := s.userIDs()
+	from, to := window(r, len(ids))
+	list := []domo.User{}
+	for _, id := range ids[from:to] {
+		list = append(list, s.userView(s.users[id]))
+	}
+	s.reply(w, http.StatusOK, list)
+}
+
+// POST /v1/users
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request, params []string) {
+	var user domo.User
+	if !s.decode(w, r, &user) {
+		return
+	}
+	user.ID = 0
+	if !s.validUser(&user) {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	s.reply(w, http.StatusCreated, s.userView(s.addUser(user)))
+}
+
+// GET /v1/users/{id}
+func (s *Server) retrieveUser(w http.ResponseWriter, r *http.Request, params []string) {
+	if user := s.lookupUser(w, params[0]); user != nil {
+		s.reply(w, http.StatusOK, s.userView(user))
+	}
+}
+
+// PUT /v1/users/{id} needs the whole user, as Domo does: a body without a name, email and role fails with 400
+func (s *Server) updateUser(w http.ResponseWriter, r *http.Request, params []string) {
+	user := s.lookupUser(w, params[0])
+	if user == nil {
+		return
+	}
+	var changes map[string]json.RawMessage
+	if !s.decode(w, r, &changes) {
+		return
+	}
+	if changes["name"] == nil || changes["email"] == nil || (changes["role"] == nil && changes["roleId"] == nil) {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	changed := *user
+	if !s.merge(w, changes, &changed) {
+		return
+	}
+	changed.ID, changed.CreatedAt, changed.UpdatedAt = user.ID, user.CreatedAt, time.Now().UTC()
+	if changed.Role != user.Role && changed.RoleID == user.RoleID {
+		changed.RoleID = 0
+	}
+	if !s.validUser(&changed) {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	*user = changed
+	s.reply(w, http.StatusOK, s.userView(user))
+}
+
+// DELETE /v1/users/{id} also takes the user out of every group
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request, params []string) {
+	user := s.lookupUser(w, params[0])
+	if user == nil {
+		return
+	}
+	delete(s.users, user.ID)
+	for _, g := range s.groups {
+		g.remove(user.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// groupView the group with its member count
+func (s *Server) groupView(g *group) domo.Group {
+	view := g.Group
+	view.MemberCount = len(g.members)
+	return view
+}
+
+// remove takes a user out of the group, if they are in it
+func (g *group) remove(userID int) bool {
+	for i, member := range g.members {
+		if member == userID {
+			g.members = append(g.members[:i:i], g.members[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// lookupGroup the group named by the path segment, failing the request with 404 if there is none
+func (s *Server) lookupGroup(w http.ResponseWriter, param string) *group {
+	id, ok := s.intParam(w, param)
+	if !ok {
+		return nil
+	}
+	g := s.groups[id]
+	if g == nil {
+		s.fail(w, http.StatusNotFound)
+	}
+	return g
+}
+
+// GET /v1/groups
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request, params []string) {
+	var ids []int
+	for id := range s.groups {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	from, to := window(r, len(ids))
+	list := []domo.Group{}
+	for _, id := range ids[from:to] {
+		list = append(list, s.groupView(s.groups[id]))
+	}
+	s.reply(w, http.StatusOK, list)
+}
+
+// POST /v1/groups
+func (s *Server) createGroup(w http.ResponseWriter, r *http.Request, params []string) {
+	var g domo.Group
+	if !s.decode(w, r, &g) {
+		return
+	}
+	if g.Name == "" {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	g.ID, g.Active, g.CreatorID = s.id(), true, "1"
+	s.groups[g.ID] = &group{Group: g}
+	s.reply(w, http.StatusCreated, s.groupView(s.groups[g.ID]))
+}
+
+// GET /v1/groups/{id}
+func (s *Server) retrieveGroup(w http.ResponseWriter, r *http.Request, params []string) {
+	if g := s.lookupGroup(w, params[0]); g != nil {
+		s.reply(w, http.StatusOK, s.groupView(g))
+	}
+}
+
+// PUT /v1/groups/{id}
+func (s *Server) updateGroup(w http.ResponseWriter, r *http.Request, params []string) {
+	g := s.lookupGroup(w, params[0])
+	if g == nil {
+		return
+	}
+	changed := g.Group
+	if !s.patch(w, r, &changed) {
+		return
+	}
+	changed.ID = g.ID
+	g.Group = changed
+	s.reply(w, http.StatusOK, s.groupView(g))
+}
+
+// DELETE /v1/groups/{id}
+func (s *Server) deleteGroup(w http.ResponseWriter, r *http.Request, params []string) {
+	if g := s.lookupGroup(w, params[0]); g != nil {
+		delete(s.groups, g.ID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GET /v1/groups/{id}/users
+func (s *Server) listGroupUsers(w http.ResponseWriter, r *http.Request, params []string) {
+	g := s.lookupGroup(w, params[0])
+	if g == nil {
+		return
+	}
+	from, to := window(r, len(g.members))
+	s.reply(w, http.StatusOK, append([]int{}, g.members[from:to]...))
+}
+
+// PUT /v1/groups/{id}/users/{user}
+func (s *Server) addGroupUser(w http.ResponseWriter, r *http.Request, params []string) {
+	g := s.lookupGroup(w, params[0])
+	if g == nil {
+		return
+	}
+	user := s.lookupUser(w, params[1])
+	if user == nil {
+		return
+	}
+	for _, member := range g.members {
+		if member == user.ID {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	g.members = append(g.members, user.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /v1/groups/{id}/users/{user}
+func (s *Server) removeGroupUser(w http.ResponseWriter, r *http.Request, params []string) {
+	g := s.lookupGroup(w, params[0])
+	if g == nil {
+		return
+	}
+	id, ok := s.intParam(w, params[1])
+	if !ok {
+		return
+	}
+	if !g.remove(id) {
+		s.fail(w, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,330 @@
+package domotest
+
+import (
+	"net/http"
+	"sort"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// Page a page as the API sends it
+type Page struct {
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	ParentID      int        `json:"parentId,omitempty"`
+	OwnerID       int        `json:"ownerId"`
+	Locked        bool       `json:"locked"`
+	CollectionIDs []int      `json:"collectionIds"`
+	CardIDs       []int      `json:"cardIds"`
+	Visibility    Visibility `json:"visibility"`
+}
+
+// Visibility the users and groups that can see a page
+type Visibility struct {
+	UserIDs  []int `json:"userIds"`
+	GroupIDs []int `json:"groupIds"`
+}
+
+// Collection a collection of cards on a page
+type Collection struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	CardIDs     []int  `json:"cardIds"`
+}
+
+// page a Page and its collections
+type page struct {
+	Page
+	collections []*Collection
+}
+
+var pageRoutes = []route{
+	{"GET", "/v1/pages", (*Server).listPages},
+	{"POST", "/v1/pages", (*Server).createPage},
+	{"GET", "/v1/pages/{}", (*Server).retrievePage},
+	{"PUT", "/v1/pages/{}", (*Server).updatePage},
+	{"DELETE", "/v1/pages/{}", (*Server).deletePage},
+	{"GET", "/v1/pages/{}/collections", (*Server).listCollections},
+	{"POST", "/v1/pages/{}/collections", (*Server).createCollection},
+	{"PUT", "/v1/pages/{}/collections/{}", (*Server).updateCollection},
+	{"DELETE", "/v1/pages/{}/collections/{}", (*Server).deleteCollection},
+}
+
+// AddPage stores a page, giving it an id if it has none
+func (s *Server) AddPage(p Page) Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pageView(s.addPage(p))
+}
+
+func (s *Server) addPage(p Page) *page {
+	if p.ID == 0 {
+		p.ID = s.id()
+	}
+	if p.OwnerID == 0 {
+		p.OwnerID = s.Owner.ID
+	}
+	s.pages[p.ID] = &page{Page: p}
+	return s.pages[p.ID]
+}
+
+// Pages every page, by id
+func (s *Server) Pages() []Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pages := []Page{}
+	for _, id := range s.pageIDs() {
+		pages = append(pages, s.pageView(s.pages[id]))
+	}
+	return pages
+}
+
+func (s *Server) pageIDs() (ids []int) {
+	for id := range s.pages {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return
+}
+
+// pageView the page with its collection ids, never sending null for a list
+func (s *Server) pageView(p *page) Page {
+	view := p.Page
+	view.CollectionIDs = []int{}
+	for _, c := range p.collections {
+		view.CollectionIDs = append(view.CollectionIDs, c.ID)
+	}
+	if view.CardIDs == nil {
+		view.CardIDs = []int{}
+	}
+	if view.Visibility.UserIDs == nil {
+		view.Visibility.UserIDs = []int{}
+	}
+	if view.Visibility.GroupIDs == nil {
+		view.Visibility.GroupIDs = []int{}
+	}
+	return view
+}
+
+// children the pages directly below a page, by id
+func (s *Server) children(parentID int) (children []domo.ChildrenPage) {
+	children = []domo.ChildrenPage{}
+	for _, id := range s.pageIDs() {
+		if s.pages[id].ParentID == parentID {
+			children = append(children, domo.ChildrenPage{ID: id, Name: s.pages[id].Name})
+		}
+	}
+	return
+}
+
+// grantParent gives the users and groups that can see a page access to its parents, as Domo does
+func (s *Server) grantParent(p *page) {
+	for parent := s.pages[p.ParentID]; parent != nil && parent != p; parent = s.pages[parent.ParentID] {
+		parent.Visibility.UserIDs = union(parent.Visibility.UserIDs, p.Visibility.UserIDs)
+		parent.Visibility.GroupIDs = union(parent.Visibility.GroupIDs, p.Visibility.GroupIDs)
+	}
+}
+
+// union the ids in either list, in the order they were first seen
+func union(a []int, b []int) []int {
+	seen := make(map[int]bool)
+	var result []int
+	for _, id := range append(append([]int(nil), a...), b...) {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// lookupPage the page named by the path segment, failing the request with 404 if there is none
+func (s *Server) lookupPage(w http.ResponseWriter, param string) *page {
+	id, ok := s.intParam(w, param)
+	if !ok {
+		return nil
+	}
+	p := s.pages[id]
+	if p == nil {
+		s.fail(w, http.StatusNotFound)
+	}
+	return p
+}
+
+// GET /v1/pages lists the top level pages and their children
+func (s *Server) listPages(w http.ResponseWriter, r *http.Request, params []string) {
+	type summary struct {
+		ID       int                 `json:"id"`
+		Name     string              `json:"name"`
+		Children []domo.ChildrenPage `json:"children"`
+	}
+	var ids []int
+	for _, id := range s.pageIDs() {
+		if s.pages[s.pages[id].ParentID] == nil {
+			ids = append(ids, id)
+		}
+	}
+	from, to := window(r, len(ids))
+	list := []summary{}
+	for _, id := range ids[from:to] {
+		list = append(list, summary{id, s.pages[id].Name, s.children(id)})
+	}
+	s.reply(w, http.StatusOK, list)
+}
+
+// POST /v1/pages
+func (s *Server) createPage(w http.ResponseWriter, r *http.Request, params []string) {
+	var p Page
+	if !s.decode(w, r, &p) {
+		return
+	}
+	if p.Name == "" || (p.ParentID != 0 && s.pages[p.ParentID] == nil) {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	p.ID, p.CollectionIDs = 0, nil
+	created := s.addPage(p)
+	s.grantParent(created)
+	s.reply(w, http.StatusCreated, s.pageView(created))
+}
+
+// GET /v1/pages/{id} with the pages directly below it
+func (s *Server) retrievePage(w http.ResponseWriter, r *http.Request, params []string) {
+	if p := s.lookupPage(w, params[0]); p != nil {
+		s.reply(w, http.StatusOK, struct {
+			Page
+			Children []domo.ChildrenPage `json:"children"`
+		}{s.pageView(p), s.children(p.ID)})
+	}
+}
+
+// PUT /v1/pages/{id} where collections can only be reordered
+func (s *Server) updatePage(w http.ResponseWriter, r *http.Request, params []string) {
+	p := s.lookupPage(w, params[0])
+	if p == nil {
+		return
+	}
+	changed := s.pageView(p)
+	if !s.patch(w, r, &changed) {
+		return
+	}
+	if changed.Name == "" || changed.ParentID == p.ID || (changed.ParentID != 0 && s.pages[changed.ParentID] == nil) {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+
+	order := make(map[int]int)
+	for i, id := range changed.CollectionIDs {
+		order[id] = i + 1
+	}
+	if len(order) > 0 {
+		if len(order) != len(p.collections) {
+			s.fail(w, http.StatusBadRequest)
+			return
+		}
+		for _, c := range p.collections {
+			if order[c.ID] == 0 {
+				s.fail(w, http.StatusBadRequest)
+				return
+			}
+		}
+		sort.Slice(p.collections, func(i, j int) bool { return order[p.collections[i].ID] < order[p.collections[j].ID] })
+	}
+
+	changed.ID, changed.CollectionIDs = p.ID, nil
+	p.Page = changed
+	s.grantParent(p)
+	s.reply(w, http.StatusOK, s.pageView(p))
+}
+
+// DELETE /v1/pages/{id}
+func (s *Server) deletePage(w http.ResponseWriter, r *http.Request, params []string) {
+	p := s.lookupPage(w, params[0])
+	if p == nil {
+		return
+	}
+	delete(s.pages, p.ID)
+	for _, child := range s.pages {
+		if child.ParentID == p.ID {
+			child.ParentID = 0
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /v1/pages/{id}/collections
+func (s *Server) listCollections(w http.ResponseWriter, r *http.Request, params []string) {
+	p := s.lookupPage(w, params[0])
+	if p == nil {
+		return
+	}
+	list := []Collection{}
+	for _, c := range p.collections {
+		list = append(list, *c)
+	}
+	s.reply(w, http.StatusOK, list)
+}
+
+// POST /v1/pages/{id}/collections
+func (s *Server) createCollection(w http.ResponseWriter, r *http.Request, params []string) {
+	p := s.lookupPage(w, params[0])
+	if p == nil {
+		return
+	}
+	var c Collection
+	if !s.decode(w, r, &c) {
+		return
+	}
+	if c.Title == "" {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	c.ID = s.id()
+	p.collections = append(p.collections, &c)
+	s.reply(w, http.StatusCreated, c)
+}
+
+// lookupCollection the collection of the page named by the second path segment
+func (s *Server) lookupCollection(w http.ResponseWriter, params []string) (*page, int) {
+	p := s.lookupPage(w, params[0])
+	if p == nil {
+		return nil, -1
+	}
+	id, ok := s.intParam(w, params[1])
+	if !ok {
+		return nil, -1
+	}
+	for i, c := range p.collections {
+		if c.ID == id {
+			return p, i
+		}
+	}
+	s.fail(w, http.StatusNotFound)
+	return nil, -1
+}
+
+// PUT /v1/pages/{id}/collections/{collection}
+func (s *Server) updateCollection(w http.ResponseWriter, r *http.Request, params []string) {
+	p, i := s.lookupCollection(w, params)
+	if p == nil {
+		return
+	}
+	changed := *p.collections[i]
+	if !s.patch(w, r, &changed) {
+		return
+	}
+	changed.ID = p.collections[i].ID
+	*p.collections[i] = changed
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /v1/pages/{id}/collections/{collection}
+func (s *Server) deleteCollection(w http.ResponseWriter, r *http.Request, params []string) {
+	p, i := s.lookupCollection(w, params)
+	if p == nil {
+		return
+	}
+	p.collections = append(p.collections[:i:i], p.collections[i+1:]...)
+	w.WriteHeader(http.StatusNoContent)
+}
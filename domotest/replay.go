@@ -0,0 +1,293 @@
+package domotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// Redacted replaces secrets in recorded fixtures
+const Redacted = "REDACTED"
+
+// Interaction one request and the response it got, as kept in a fixture file.
+// JSON bodies are kept as JSON so the file reads easily, anything else as a string.
+type Interaction struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Query    string            `json:"query,omitempty"`
+	Body     json.RawMessage   `json:"body,omitempty"`
+	Status   int               `json:"status"`
+	Header   map[string]string `json:"header,omitempty"`
+	Response json.RawMessage   `json:"response,omitempty"`
+}
+
+// secretKeys JSON fields and query parameters whose values are never recorded
+var secretKeys = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"client_secret": true,
+	"secret":        true,
+	"password":      true,
+	"jti":           true,
+}
+
+// keptHeaders the response headers worth recording, the rest change from run to run
+var keptHeaders = []string{"Content-Type", "Retry-After", "Location"}
+
+// bearer finds tokens that have leaked into text
+var bearer = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+
+// Recorder a Doer that sends requests on to another Doer and keeps every exchange,
+// with secrets scrubbed, so they can be saved as a fixture file.
+type Recorder struct {
+	next         domo.Doer
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder records the requests sent through next
+func NewRecorder(next domo.Doer) *Recorder {
+	return &Recorder{next: next}
+}
+
+// Do sends the request and records it with its response
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.next.Do(req)
+	if err != nil {
+		return resp, err
+	}
+
+	response, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(response))
+
+	interaction := request(req, body)
+	interaction.Status = resp.StatusCode
+	interaction.Response = fixtureBody(string(response))
+	for _, key := range keptHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			if interaction.Header == nil {
+				interaction.Header = make(map[string]string)
+			}
+			interaction.Header[key] = value
+		}
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Interactions every exchange recorded so far
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Save writes the recorded exchanges to a fixture file
+func (r *Recorder) Save(path string) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r.Interactions()); err != nil {
+		return fmt.Errorf("Unable to marshal fixture %s", err)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("Unable to save fixture %s", err)
+	}
+	return nil
+}
+
+// Replayer a Doer that answers requests from a fixture file instead of sending them.
+// A request gets the response of the first unused exchange with the same method, path,
+// query and body, where JSON bodies are compared without regard to key order or spacing.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer replays the given exchanges
+func NewReplayer(interactions []Interaction) *Replayer {
+	return &Replayer{interactions: interactions, used: make([]bool, len(interactions))}
+}
+
+// LoadReplayer replays the exchanges in a fixture file written by Recorder.Save
+func LoadReplayer(path string) (*Replayer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read fixture %s", err)
+	}
+	var interactions []Interaction
+	if err = json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal fixture %s %s", path, err)
+	}
+	return NewReplayer(interactions), nil
+}
+
+// Do answers the request with the recorded response, or fails if nothing recorded matches
+func (p *Replayer) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	want := request(req, body)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, got := range p.interactions {
+		if p.used[i] || got.Method != want.Method || got.Path != want.Path ||
+			normalizeQuery(got.Query) != want.Query || normalizeBody(bodyText(got.Body)) != normalizeBody(bodyText(want.Body)) {
+			continue
+		}
+		p.used[i] = true
+
+		response := bodyText(got.Response)
+		resp := &http.Response{
+			Status:        fmt.Sprintf("%d %s", got.Status, http.StatusText(got.Status)),
+			StatusCode:    got.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        make(http.Header),
+			Body:          ioutil.NopCloser(strings.NewReader(response)),
+			ContentLength: int64(len(response)),
+			Request:       req,
+		}
+		for key, value := range got.Header {
+			resp.Header.Set(key, value)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("No recorded response for %s %s?%s", want.Method, want.Path, want.Query)
+}
+
+// Unused the recorded exchanges that no request has asked for yet
+func (p *Replayer) Unused() (unused []Interaction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, interaction := range p.interactions {
+		if !p.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return
+}
+
+// readBody reads the request body and puts it back for whoever sends the request
+func readBody(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("Unable to read request body %s", err)
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return string(body), nil
+}
+
+// request the scrubbed, normalized request half of an exchange
+func request(req *http.Request, body string) Interaction {
+	return Interaction{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  normalizeQuery(req.URL.RawQuery),
+		Body:   fixtureBody(body),
+	}
+}
+
+// fixtureBody the normalized body as it is kept in a fixture, JSON as it is and anything else as a JSON string
+func fixtureBody(body string) json.RawMessage {
+	if body == "" {
+		return nil
+	}
+	body = normalizeBody(body)
+	if json.Valid([]byte(body)) {
+		return json.RawMessage(body)
+	}
+	data, _ := json.Marshal(body)
+	return data
+}
+
+// bodyText the text of a body kept by fixtureBody
+func bodyText(raw json.RawMessage) string {
+	var text string
+	if len(raw) > 0 && raw[0] == '"' && json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	return string(raw)
+}
+
+// normalizeQuery sorts the query parameters and scrubs secret ones
+func normalizeQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	for key := range values {
+		if secretKeys[strings.ToLower(key)] {
+			values[key] = []string{Redacted}
+		}
+	}
+	return values.Encode()
+}
+
+// normalizeBody removes secrets from a body, compacts JSON with its keys sorted, and evens out line endings in anything else
+func normalizeBody(body string) string {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	if strings.TrimSpace(body) != "" && decoder.Decode(&value) == nil && !decoder.More() {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if encoder.Encode(scrubJSON(value)) == nil {
+			return strings.TrimSuffix(buf.String(), "\n")
+		}
+	}
+	body = strings.Replace(body, "\r\n", "\n", -1)
+	return bearer.ReplaceAllString(body, "${1}"+Redacted)
+}
+
+// scrubJSON replaces the values of secret keys, wherever they are
+func scrubJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if secretKeys[strings.ToLower(key)] {
+				v[key] = Redacted
+			} else {
+				v[key] = scrubJSON(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = scrubJSON(child)
+		}
+	case string:
+		return bearer.ReplaceAllString(v, "${1}"+Redacted)
+	}
+	return value
+}
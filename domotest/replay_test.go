@@ -0,0 +1,95 @@
+package domotest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayer_fixture(t *testing.T) {
+	replayer, err := LoadReplayer("testdata/upload.json")
+	assert.Equal(t, nil, err, "Bad fixture")
+
+	client := domo.New(ClientID, Secret)
+	client.SetBaseURL("http://domo.invalid")
+	client.SetDoer(replayer)
+
+	summary, err := client.Stream.UploadParts(102, []string{"1,a\n", "2,\"b, c\"\n"}, domo.UploadOptions{})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 2, summary.Parts, "Bad part count")
+	assert.Equal(t, 0, len(replayer.Unused()), "Bad unused exchanges")
+
+	_, err = client.Stream.UploadParts(102, []string{"1,a\n"}, domo.UploadOptions{})
+	assert.Equal(t, true, err != nil, "Bad replay of a used exchange")
+}
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "domotest")
+	assert.Equal(t, nil, err, "Bad temp dir")
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "groups.json")
+
+	flow := func(client *domo.Client) (members domo.GroupUsers, err error) {
+		group, err := client.Group.Create(context.Background(), domo.GroupCreate{Name: "Sales & Marketing"})
+		if err != nil {
+			return
+		}
+		if err = client.Group.AddUser(group.ID, 101); err != nil {
+			return
+		}
+		return client.Group.ListUsers(group.ID)
+	}
+
+	server := NewServer()
+	server.AddUser(domo.User{Name: "Ann", Email: "ann@example.com"})
+	recorder := NewRecorder(http.DefaultClient)
+	client := server.Client()
+	client.SetDoer(recorder)
+	recorded, err := flow(client)
+	server.Close()
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, domo.GroupUsers{101}, recorded, "Bad members")
+	assert.Equal(t, nil, recorder.Save(fixture), "Bad save")
+
+	data, _ := ioutil.ReadFile(fixture)
+	assert.Equal(t, false, strings.Contains(string(data), client.GetToken("user")), "Bad token left in fixture")
+	assert.Equal(t, true, strings.Contains(string(data), `"Sales & Marketing"`), "Bad body in fixture")
+
+	replayer, err := LoadReplayer(fixture)
+	assert.Equal(t, nil, err, "Bad fixture")
+	client = domo.New("someone", "else")
+	client.SetBaseURL("http://domo.invalid")
+	client.SetDoer(replayer)
+	replayed, err := flow(client)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, recorded, replayed, "Bad replayed members")
+}
+
+func Test_normalize(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		same bool
+	}{
+		{name: "json key order and spacing", a: `{"name": "x", "default": false}`, b: `{"default":false,"name":"x"}`, same: true},
+		{name: "json values differ", a: `{"name": "x"}`, b: `{"name": "y"}`, same: false},
+		{name: "json secrets", a: `{"access_token": "abc", "nested": [{"password": "p"}]}`, b: `{"access_token": "xyz", "nested": [{"password": "q"}]}`, same: true},
+		{name: "csv line endings", a: "1,a\r\n2,b\r\n", b: "1,a\n2,b\n", same: true},
+		{name: "bearer tokens in text", a: "Authorization: Bearer abc.def", b: "Authorization: Bearer 123", same: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.same, normalizeBody(tt.a) == normalizeBody(tt.b), "Bad body match")
+		})
+	}
+
+	assert.Equal(t, "a=1&b=2&client_secret=REDACTED", normalizeQuery("client_secret=s&b=2&a=1"), "Bad query")
+}
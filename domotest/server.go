@@ -0,0 +1,398 @@
+// Package domotest runs an in-memory Domo API for tests.
+//
+// A Server answers OAuth, DataSet, Stream, User, Group, Role, Page, Card and Activity Log requests
+// from state it holds, so a test can create a stream, upload parts, commit and then look at the rows
+// that landed. Faults can be injected to make chosen requests fail with a status such as 429 or
+// 503, or to slow them down.
+//
+//	server := domotest.NewServer()
+//	defer server.Close()
+//	client := server.Client()
+//
+// Each Client talks to its own base URL, so tests that use different Servers can run in parallel.
+package domotest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// Client credentials the Server accepts unless they are changed
+const (
+	ClientID = "domotest-client"
+	Secret   = "domotest-secret"
+)
+
+// Fault makes matching requests fail or slow down
+type Fault struct {
+	Method     string        // HTTP method to match, any when empty
+	Path       string        // path.Match pattern such as /v1/streams/*/executions/*/part/*, any when empty
+	Status     int           // Status to reply with instead of handling the request, none when 0
+	RetryAfter time.Duration // Sent as Retry-After with the status
+	Latency    time.Duration // Wait before replying
+	Times      int           // Number of requests affected, every one when 0
+}
+
+// Server an in-memory Domo API
+type Server struct {
+	*httptest.Server
+
+	ClientID  string     // Client id that gets a token, ClientID by default
+	Secret    string     // Secret that gets a token, Secret by default
+	Owner     domo.Owner // Owner of the DataSets the Server creates
+	TokenLife time.Duration
+
+	mu       sync.Mutex
+	nextID   int
+	tokens   map[string]grant
+	faults   []*Fault
+	requests []string
+	datasets map[string]*dataset
+	streams  map[int]*stream
+	users    map[int]*domo.User
+	groups   map[int]*group
+	pages    map[int]*page
+	cards    map[int]*domo.Card
+	activity []domo.ActivityEntry
+	roles    map[int]*domo.RoleDetail
+}
+
+// grant what a token allows, and until when
+type grant struct {
+	expires time.Time
+	scopes  map[string]bool
+}
+
+// scopes the scope a token needs for each API, by the first part of its path
+var scopes = map[string]string{
+	"audit":    "audit",
+	"cards":    "dashboard",
+	"datasets": "data",
+	"groups":   "user",
+	"pages":    "dashboard",
+	"roles":    "user",
+	"streams":  "data",
+	"users":    "user",
+}
+
+// route a request handler for a method and path pattern, where {} matches one segment
+type route struct {
+	method  string
+	pattern string
+	handle  func(s *Server, w http.ResponseWriter, r *http.Request, params []string)
+}
+
+// routes every endpoint the Server knows
+var routes []route
+
+func init() {
+	routes = append(routes, datasetRoutes...)
+	routes = append(routes, streamRoutes...)
+	routes = append(routes, userRoutes...)
+	routes = append(routes, groupRoutes...)
+	routes = append(routes, roleRoutes...)
+	routes = append(routes, pageRoutes...)
+	routes = append(routes, cardRoutes...)
+	routes = append(routes, activityRoutes...)
+}
+
+// NewServer starts a Server with no data in it
+func NewServer() *Server {
+	s := &Server{
+		ClientID:  ClientID,
+		Secret:    Secret,
+		Owner:     domo.Owner{ID: 1, Name: "Domo Test"},
+		TokenLife: time.Hour,
+		nextID:    100,
+		tokens:    make(map[string]grant),
+		datasets:  make(map[string]*dataset),
+		streams:   make(map[int]*stream),
+		users:     make(map[int]*domo.User),
+		groups:    make(map[int]*group),
+		pages:     make(map[int]*page),
+		cards:     make(map[int]*domo.Card),
+		roles: map[int]*domo.RoleDetail{
+			1: {ID: 1, Name: domo.RoleAdmin, Description: "Full access to everything"},
+			2: {ID: 2, Name: domo.RolePrivileged, Description: "Full access except for user management"},
+			3: {ID: 3, Name: domo.RoleParticipant, Description: "Access to shared content"},
+		},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client a domo Client that talks to the Server with its credentials
+func (s *Server) Client() *domo.Client {
+	client := domo.New(s.ClientID, s.Secret)
+	client.SetBaseURL(s.URL)
+	return client
+}
+
+// Inject adds a fault, checked before the ones already added
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fault := f
+	s.faults = append([]*Fault{&fault}, s.faults...)
+}
+
+// ClearFaults removes every fault
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests every request served so far, as "METHOD /path"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// ExpireTokens makes every token handed out so far invalid
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]grant)
+}
+
+// serve checks faults and credentials, then hands the request to its route
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	fault := s.fault(r)
+	s.mu.Unlock()
+
+	if fault != nil {
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.Status != 0 {
+			if fault.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(fault.RetryAfter/time.Second)))
+			}
+			s.fail(w, fault.Status)
+			return
+		}
+	}
+
+	if r.URL.Path == "/oauth/token" {
+		s.token(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status := s.authorized(r); status != http.StatusOK {
+		s.fail(w, status)
+		return
+	}
+
+	known := false
+	for _, rt := range routes {
+		params, ok := match(rt.pattern, r.URL.Path)
+		if !ok {
+			continue
+		}
+		known = true
+		if rt.method == r.Method {
+			rt.handle(s, w, r, params)
+			return
+		}
+	}
+	if known {
+		s.fail(w, http.StatusMethodNotAllowed)
+		return
+	}
+	s.fail(w, http.StatusNotFound)
+}
+
+// fault the first fault that matches the request, using up one of its times
+func (s *Server) fault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if f.Method != "" && f.Method != r.Method {
+			continue
+		}
+		if f.Path != "" {
+			if ok, _ := path.Match(f.Path, r.URL.Path); !ok {
+				continue
+			}
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// token hands out a bearer token for the client credentials
+// GET /oauth/token?grant_type=client_credentials&scope=...
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != s.ClientID || secret != s.Secret {
+		s.fail(w, http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Query().Get("grant_type") != "client_credentials" {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+
+	token := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", clientID, s.id())))
+	granted := grant{expires: time.Now().Add(s.TokenLife), scopes: make(map[string]bool)}
+	for _, scope := range strings.Fields(r.URL.Query().Get("scope")) {
+		granted.scopes[scope] = true
+	}
+	s.tokens[token] = granted
+	s.reply(w, http.StatusOK, domo.Access{
+		AccessToken: token,
+		TokenType:   "bearer",
+		ExpiresIn:   int64(s.TokenLife / time.Second),
+		Scope:       r.URL.Query().Get("scope"),
+		Customer:    "domotest",
+		UserID:      s.Owner.ID,
+		Role:        "Admin",
+	})
+}
+
+// authorized whether the request carries a live bearer token with the scope its API needs,
+// answering 401 when it doesn't have a token and 403 when the token lacks the scope
+func (s *Server) authorized(r *http.Request) int {
+	fields := strings.Fields(r.Header.Get("Authorization"))
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "bearer") {
+		return http.StatusUnauthorized
+	}
+	granted, ok := s.tokens[fields[1]]
+	if !ok || !time.Now().Before(granted.expires) {
+		return http.StatusUnauthorized
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+	if scope, ok := scopes[parts[0]]; ok && !granted.scopes[scope] {
+		return http.StatusForbidden
+	}
+	return http.StatusOK
+}
+
+// match whether a path fits a pattern, returning the segments that matched {}
+func match(pattern string, urlPath string) (params []string, ok bool) {
+	want := strings.Split(strings.Trim(pattern, "/"), "/")
+	got := strings.Split(strings.Trim(urlPath, "/"), "/")
+	if len(want) != len(got) {
+		return nil, false
+	}
+	for i := range want {
+		switch {
+		case want[i] == "{}":
+			params = append(params, got[i])
+		case want[i] != got[i]:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// id the next id for a new object
+func (s *Server) id() int {
+	s.nextID++
+	return s.nextID
+}
+
+// reply writes v as JSON
+func (s *Server) reply(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// fail writes the error message Domo sends with a status
+func (s *Server) fail(w http.ResponseWriter, status int) {
+	s.reply(w, status, domo.ErrorMessage{
+		Status:       status,
+		StatusReason: http.StatusText(status),
+		Toe:          fmt.Sprintf("DOMOTEST-%d", time.Now().UnixNano()),
+	})
+}
+
+// decode reads the JSON body of a request, failing it with 400 if it can't
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		s.fail(w, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// patch overlays the fields in the JSON body of a request onto v, leaving the rest alone
+func (s *Server) patch(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	var changes map[string]json.RawMessage
+	if !s.decode(w, r, &changes) {
+		return false
+	}
+	return s.merge(w, changes, v)
+}
+
+// merge overlays changes onto v, leaving the fields they don't name alone
+func (s *Server) merge(w http.ResponseWriter, changes map[string]json.RawMessage, v interface{}) bool {
+	current, err := json.Marshal(v)
+	if err != nil {
+		s.fail(w, http.StatusInternalServerError)
+		return false
+	}
+	fields := make(map[string]json.RawMessage)
+	json.Unmarshal(current, &fields)
+	for key, value := range changes {
+		fields[key] = value
+	}
+	merged, _ := json.Marshal(fields)
+	if err = json.Unmarshal(merged, v); err != nil {
+		s.fail(w, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// intParam the numeric path segment, failing the request with 404 if it isn't one
+func (s *Server) intParam(w http.ResponseWriter, param string) (int, bool) {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		s.fail(w, http.StatusNotFound)
+		return 0, false
+	}
+	return n, true
+}
+
+// window applies the limit and offset query parameters to a list of n items
+func window(r *http.Request, n int) (from int, to int) {
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if offset < 0 || offset > n {
+		offset = n
+	}
+	if err != nil || limit < 0 || offset+limit > n {
+		return offset, n
+	}
+	return offset, offset + limit
+}
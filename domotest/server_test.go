@@ -0,0 +1,419 @@
+package domotest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSchema id and name columns
+func testSchema() domo.Schema {
+	schema := domo.Schema{Columns: make(domo.Columns, 2)}
+	schema.Columns[0].Name, schema.Columns[0].Type = "id", domo.ColumnLong
+	schema.Columns[1].Name, schema.Columns[1].Type = "name", domo.ColumnString
+	return schema
+}
+
+// count the requests made to a method and path
+func count(requests []string, request string) (n int) {
+	for _, r := range requests {
+		if r == request {
+			n++
+		}
+	}
+	return
+}
+
+func TestServer_streams(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		keys        []string
+		seed        [][]string
+		parts       []string
+		faults      []Fault
+		wantErr     bool
+		wantRecords [][]string
+		wantState   string
+		wantParts   int
+	}{
+		{
+			name:        "append",
+			method:      domo.UpdateMethodAppend,
+			seed:        [][]string{{"1", "old"}},
+			parts:       []string{"2,b\n", "3,c\n"},
+			wantRecords: [][]string{{"1", "old"}, {"2", "b"}, {"3", "c"}},
+			wantState:   "SUCCESS",
+			wantParts:   1,
+		},
+		{
+			name:        "replace",
+			method:      domo.UpdateMethodReplace,
+			seed:        [][]string{{"1", "old"}},
+			parts:       []string{"2,b\n"},
+			wantRecords: [][]string{{"2", "b"}},
+			wantState:   "SUCCESS",
+			wantParts:   1,
+		},
+		{
+			name:        "upsert",
+			method:      domo.UpdateMethodUpsert,
+			keys:        []string{"id"},
+			seed:        [][]string{{"1", "old"}, {"2", "keep"}},
+			parts:       []string{"1,new\n3,c\n"},
+			wantRecords: [][]string{{"1", "new"}, {"2", "keep"}, {"3", "c"}},
+			wantState:   "SUCCESS",
+			wantParts:   1,
+		},
+		{
+			name:   "part retried after a 503 and a 429",
+			method: domo.UpdateMethodAppend,
+			parts:  []string{"1,a\n"},
+			faults: []Fault{
+				{Method: "PUT", Path: "/v1/streams/*/executions/*/part/*", Status: 503, Times: 1},
+				{Path: "/v1/streams/*/executions/*/part/1", Status: 429, RetryAfter: time.Second, Times: 1},
+			},
+			wantRecords: [][]string{{"1", "a"}},
+			wantState:   "SUCCESS",
+			wantParts:   3,
+		},
+		{
+			name:      "commit fails",
+			method:    domo.UpdateMethodAppend,
+			parts:     []string{"1,a\n"},
+			faults:    []Fault{{Path: "/v1/streams/*/executions/*/commit", Status: 500}},
+			wantErr:   true,
+			wantState: "ACTIVE",
+			wantParts: 1,
+		},
+		{
+			name:      "part that doesn't fit the schema",
+			method:    domo.UpdateMethodAppend,
+			parts:     []string{"1,a,extra\n"},
+			wantErr:   true,
+			wantState: "ABORTED",
+			wantParts: 1, // a 400 is never retried
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer()
+			defer server.Close()
+			client := server.Client()
+
+			stream := server.AddStream("orders", testSchema(), tt.method, tt.keys...)
+			server.SetRecords(stream.DataSet.ID, tt.seed)
+			for _, f := range tt.faults {
+				server.Inject(f)
+			}
+
+			_, err := client.Stream.UploadParts(stream.ID, tt.parts, domo.UploadOptions{Retries: 2, RetryWait: time.Millisecond})
+			assert.Equal(t, tt.wantErr, err != nil, "Bad error code")
+
+			assert.Equal(t, tt.wantRecords, server.StreamRecords(stream.ID), "Bad records")
+			executions := server.Executions(stream.ID)
+			assert.Equal(t, 1, len(executions), "Bad execution count")
+			assert.Equal(t, tt.wantState, executions[0].CurrentState, "Bad state")
+			part := fmt.Sprintf("PUT /v1/streams/%d/executions/1/part/1", stream.ID)
+			assert.Equal(t, tt.wantParts, count(server.Requests(), part), "Bad part requests")
+		})
+	}
+}
+
+func TestServer_directory(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	ann := server.AddUser(domo.User{Name: "Ann", Email: "ann@example.com", Role: "Admin"})
+	bob := server.AddUser(domo.User{Name: "Bob", Email: "bob@example.com", Role: "Participant"})
+
+	users, err := client.User.List()
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 2, len(users), "Bad user count")
+
+	group, err := client.Group.Create(context.Background(), domo.GroupCreate{Name: "Sales"})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, nil, client.Group.AddUser(group.ID, ann.ID), "Bad add")
+	assert.Equal(t, nil, client.Group.AddUser(group.ID, bob.ID), "Bad add")
+	assert.Equal(t, nil, client.Group.AddUser(group.ID, bob.ID), "Bad second add")
+
+	members, err := client.Group.ListUsers(group.ID)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, domo.GroupUsers{ann.ID, bob.ID}, members, "Bad members")
+
+	user, err := client.User.Retrieve(bob.ID)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 1, len(user.Groups), "Bad groups")
+
+	change, err := client.Group.SetMembers(context.Background(), group.ID, []int{bob.ID})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, []int{ann.ID}, change.Removed, "Bad removes")
+	change, err = client.Group.SetMembers(context.Background(), group.ID, []int{ann.ID, bob.ID})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, []int{ann.ID}, change.Added, "Bad adds")
+
+	assert.Equal(t, nil, client.User.Delete(bob.ID), "Bad delete")
+	assert.Equal(t, []int{ann.ID}, server.Members(group.ID), "Bad members after delete")
+	assert.Equal(t, true, client.User.Delete(bob.ID) != nil, "Bad second delete")
+	assert.Equal(t, true, client.Group.RemoveUser(group.ID, bob.ID) != nil, "Bad remove of a non member")
+
+	auditor := server.AddRole(domo.RoleDetail{Name: "Auditor"})
+	changed, err := client.Role.Assign(context.Background(), auditor.ID, []int{ann.ID})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, domo.Role("Auditor"), changed[0].Role, "Bad assigned role")
+	roles, err := client.Role.List(context.Background())
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 4, len(roles), "Bad role count")
+	assert.Equal(t, 0, roles[0].UserCount, "Bad Admin count")
+	assert.Equal(t, 1, roles[3].UserCount, "Bad Auditor count")
+	privileged := domo.RolePrivileged
+	user, err = client.User.Update(context.Background(), ann.ID, domo.UserPatch{Role: &privileged})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 2, user.RoleID, "Bad role id after a change of name")
+
+	req, _ := http.NewRequest("GET", server.URL+"/oauth/token?grant_type=client_credentials&scope=user", nil)
+	req.SetBasicAuth(ClientID, Secret)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Equal(t, nil, err, "Bad token request")
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.NewDecoder(resp.Body).Decode(&token)
+	resp.Body.Close()
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("%s/v1/users/%d", server.URL, ann.ID), strings.NewReader(`{"phone": "555-0100"}`))
+	req.Header.Set("Authorization", "bearer "+token.AccessToken)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Equal(t, nil, err, "Bad update request")
+	resp.Body.Close()
+	assert.Equal(t, 400, resp.StatusCode, "Partial user update accepted")
+
+	found, err := client.Group.Find("Sales")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, group.ID, found, "Bad find")
+}
+
+func TestServer_pages(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	parent := server.AddPage(Page{Name: "Sales"})
+	child := server.AddPage(Page{Name: "Sales EMEA", ParentID: parent.ID, Visibility: Visibility{UserIDs: []int{7}}})
+
+	pages, err := client.Page.List()
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 1, len(pages), "Bad page count")
+	assert.Equal(t, child.ID, pages[0].Children[0].ID, "Bad child")
+
+	created, err := client.Page.Create(context.Background(), domo.PageCreate{Name: "Sales APAC", ParentID: parent.ID,
+		Visibility: &domo.Visibility{GroupIds: []int{3}}})
+	assert.Equal(t, nil, err, "Bad error code")
+	retrieved, err := client.Page.Retrieve(parent.ID)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 2, len(retrieved.Children), "Bad children")
+	assert.Equal(t, []int{3}, retrieved.Visibility.GroupIds, "Access not given to the parent")
+
+	plan, err := client.Page.PlanVisibility(context.Background(), child.ID, domo.Visibility{GroupIds: []int{4}}, true)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, parent.ID, plan.Parents[0].PageID, "Bad parent pages")
+	_, err = client.Page.Update(context.Background(), child.ID, domo.PagePatch{Visibility: &domo.Visibility{UserIds: []int{7, 8}}})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, nil, client.Page.ApplyVisibility(context.Background(), plan), "Bad apply")
+	retrieved, _ = client.Page.Retrieve(parent.ID)
+	assert.Equal(t, []int{3, 4}, retrieved.Visibility.GroupIds, "Parent page not granted as planned")
+	assert.Equal(t, true, plan.Parents[0].Done, "Granted parent page not done")
+	retrieved, _ = client.Page.Retrieve(child.ID)
+	assert.Equal(t, []int{7, 8}, retrieved.Visibility.UserIds, "Change made since planning lost")
+	assert.Equal(t, []int{4}, retrieved.Visibility.GroupIds, "Bad grant")
+
+	updated, err := client.Page.Update(context.Background(), created.ID, domo.PagePatch{ParentID: domo.Int(0), Locked: domo.Bool(true)})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, true, updated.Locked, "Bad update")
+	_, err = client.Page.Update(context.Background(), created.ID, domo.PagePatch{ParentID: domo.Int(999)})
+	assert.NotEqual(t, nil, err, "Moved below a missing page")
+
+	ctx := context.Background()
+	revenue, err := client.Page.CreateCollection(ctx, parent.ID, domo.PageCollectionCreate{Title: "Revenue", CardIDs: []int{21}})
+	assert.Equal(t, nil, err, "Bad error code")
+	costs, err := client.Page.CreateCollection(ctx, parent.ID, domo.PageCollectionCreate{Title: "Costs"})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, nil, client.Page.UpdateCollection(ctx, parent.ID, costs.ID, domo.PageCollectionPatch{CardIDs: domo.IDs(22, 23)}), "Bad update")
+	assert.Equal(t, nil, client.Page.ReorderCollections(ctx, parent.ID, []int{costs.ID, revenue.ID}), "Bad reorder")
+	assert.NotEqual(t, nil, client.Page.ReorderCollections(ctx, parent.ID, []int{costs.ID}), "Collection dropped by a reorder")
+	collections, err := client.Page.ListCollections(ctx, parent.ID)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, []domo.PageCollection{{ID: costs.ID, Title: "Costs", CardIDs: []int{22, 23}}, {ID: revenue.ID, Title: "Revenue", CardIDs: []int{21}}}, collections, "Bad collections")
+	assert.Equal(t, nil, client.Page.DeleteCollection(ctx, parent.ID, revenue.ID), "Bad delete")
+
+	assert.Equal(t, nil, client.Page.DeletePage(child.ID), "Bad delete")
+	assert.Equal(t, nil, client.Page.Delete(context.Background(), created.ID), "Bad delete")
+	assert.Equal(t, 1, len(server.Pages()), "Bad pages after delete")
+}
+
+func TestServer_offboard(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	ann := server.AddUser(domo.User{Name: "Ann", Email: "ann@example.com", Role: "Participant"})
+	bob := server.AddUser(domo.User{Name: "Bob", Email: "bob@example.com", Role: "Admin"})
+	server.AddGroup(domo.Group{Name: "Sales"}, ann.ID, bob.ID)
+	server.Owner = domo.Owner{ID: ann.ID, Name: ann.Name}
+	stream := server.AddStream("orders", testSchema(), domo.UpdateMethodAppend)
+	parent := server.AddPage(Page{Name: "Sales", OwnerID: bob.ID})
+	child := server.AddPage(Page{Name: "Sales EMEA", ParentID: parent.ID})
+
+	record, err := client.User.Offboard(context.Background(), ann.ID, domo.OffboardOptions{SuccessorID: bob.ID})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 5, len(record.Steps), "Bad steps")
+	assert.Equal(t, true, record.Deleted, "Not deleted")
+
+	assert.Equal(t, 1, len(server.Users()), "Bad users")
+	streams, err := client.Stream.ListOwned(context.Background(), bob.ID)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, stream.ID, streams[0].ID, "Stream not reassigned")
+	assert.Equal(t, "Bob", streams[0].DataSet.Owner.Name, "Bad owner name")
+	assert.Equal(t, bob.ID, server.Pages()[1].OwnerID, "Page not reassigned")
+	assert.Equal(t, child.ID, server.Pages()[1].ID, "Bad page")
+}
+
+func TestServer_cards(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	revenue := server.AddCard(domo.Card{Title: "Revenue", Type: "kpi",
+		DataSources: []domo.CardDataSource{{ID: "ds-1", Name: "Orders", Columns: []string{"amount", "region"}}}})
+	server.AddCard(domo.Card{Title: "Notes", Type: "doc"})
+	page := server.AddPage(Page{Name: "Sales", CardIDs: []int{revenue.ID}})
+
+	cards, err := client.Card.ListAll(context.Background())
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 2, len(cards), "Bad card count")
+
+	card, err := client.Card.Retrieve(context.Background(), revenue.ID)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, []int{page.ID}, card.PageIDs, "Bad pages")
+
+	uses, _, err := client.Card.UsingColumn(context.Background(), "ds-1", "region")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, revenue.ID, uses[0].ID, "Bad card using region")
+}
+
+func TestServer_activity(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+	dir, err := ioutil.TempDir("", "domotest")
+	assert.Equal(t, nil, err, "Bad temp dir")
+	defer os.RemoveAll(dir)
+
+	at := time.Now().Add(-10*time.Minute).UnixNano() / int64(time.Millisecond)
+	server.AddActivity(
+		activityEntry(7, "PAGE", "Viewed", at),
+		activityEntry(8, "PAGE", "Viewed", at),
+		activityEntry(7, "CARD", "Viewed", at-1),
+	)
+	opts := domo.ActivityTailOptions{Interval: 10 * time.Millisecond, Cursor: filepath.Join(dir, "cursor.json")}
+	opts.Start = time.Now().Add(-time.Hour)
+	opts.ObjectType = "PAGE"
+
+	// tail reads n entries, then stops and checks there were no more
+	tail := func(n int) (users []int) {
+		ctx, cancel := context.WithCancel(context.Background())
+		tail, err := client.ActivityLog.Tail(ctx, opts)
+		assert.Equal(t, nil, err, "Bad error code")
+		for entry := range tail.Events() {
+			users = append(users, entry.UserID)
+			if len(users) == n {
+				time.Sleep(50 * time.Millisecond)
+				cancel()
+			}
+		}
+		cancel()
+		assert.Equal(t, nil, tail.Err(), "Bad tail error")
+		return
+	}
+
+	assert.Equal(t, []int{7, 8}, tail(2), "Bad first tail")
+	server.AddActivity(activityEntry(9, "PAGE", "Viewed", at), activityEntry(7, "PAGE", "Deleted", at+1))
+	assert.Equal(t, []int{9, 7}, tail(2), "Restarted tail repeated or missed entries")
+
+	entries, err := client.ActivityLog.Query(context.Background(), domo.ActivityQuery{Start: opts.Start, UserID: 7})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 3, len(entries), "Bad user filter")
+}
+
+// activityEntry an entry of the activity log about a user
+func activityEntry(userID int, objectType string, action string, at int64) domo.ActivityEntry {
+	return domo.ActivityEntry{UserID: userID, ObjectType: objectType, EventText: action, Time: at}
+}
+
+func TestServer_faults(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	token := func(secret string) *http.Response {
+		req, _ := http.NewRequest("GET", server.URL+"/oauth/token?grant_type=client_credentials&scope=data", nil)
+		req.SetBasicAuth(ClientID, secret)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Equal(t, nil, err, "Bad request")
+		resp.Body.Close()
+		return resp
+	}
+
+	assert.Equal(t, 401, token("wrong").StatusCode, "Bad secret accepted")
+	assert.Equal(t, 200, token(Secret).StatusCode, "Bad token")
+
+	server.Inject(Fault{Path: "/oauth/token", Status: 429, RetryAfter: 2 * time.Second, Times: 1})
+	resp := token(Secret)
+	assert.Equal(t, 429, resp.StatusCode, "Bad fault status")
+	assert.Equal(t, "2", resp.Header.Get("Retry-After"), "Bad Retry-After")
+	assert.Equal(t, 200, token(Secret).StatusCode, "Bad fault count")
+
+	server.Inject(Fault{Latency: 50 * time.Millisecond})
+	start := time.Now()
+	token(Secret)
+	assert.Equal(t, true, time.Since(start) >= 50*time.Millisecond, "Bad latency")
+	server.ClearFaults()
+
+	resp, err := http.Get(server.URL + "/v1/users")
+	assert.Equal(t, nil, err, "Bad request")
+	resp.Body.Close()
+	assert.Equal(t, 401, resp.StatusCode, "Bad request without a token")
+
+	req, _ := http.NewRequest("GET", server.URL+"/oauth/token?grant_type=client_credentials&scope=data", nil)
+	req.SetBasicAuth(ClientID, Secret)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Equal(t, nil, err, "Bad token request")
+	var data struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.NewDecoder(resp.Body).Decode(&data)
+	resp.Body.Close()
+	req, _ = http.NewRequest("GET", server.URL+"/v1/users", nil)
+	req.Header.Set("Authorization", "bearer "+data.AccessToken)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Equal(t, nil, err, "Bad request")
+	resp.Body.Close()
+	assert.Equal(t, 403, resp.StatusCode, "Bad request without the user scope")
+
+	client := server.Client()
+	_, err = client.DataSet.List()
+	assert.Equal(t, nil, err, "Bad error code")
+	_, err = client.User.List()
+	assert.Equal(t, nil, err, "Bad error code")
+	// six asked for directly, and two by the client as it needed the user scope as well as data
+	assert.Equal(t, 8, count(server.Requests(), "GET /oauth/token"), "Bad token request count")
+}
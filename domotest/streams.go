@@ -0,0 +1,579 @@
+package domotest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// dataset a DataSet and its rows
+type dataset struct {
+	domo.Dataset
+	Owner   domo.Owner
+	records [][]string
+}
+
+// stream a Stream, its DataSet and executions
+type stream struct {
+	ID             int
+	DataSetID      string
+	UpdateMethod   string
+	KeyColumnNames []string
+	CreatedAt      time.Time
+	ModifiedAt     time.Time
+	executions     []*execution
+}
+
+// execution an upload to a stream, and the parts sent so far
+type execution struct {
+	domo.Execution
+	parts map[int]string
+}
+
+var datasetRoutes = []route{
+	{"GET", "/v1/datasets", (*Server).listDataSets},
+	{"POST", "/v1/datasets", (*Server).createDataSet},
+	{"GET", "/v1/datasets/{}", (*Server).retrieveDataSet},
+	{"PUT", "/v1/datasets/{}", (*Server).updateDataSet},
+	{"DELETE", "/v1/datasets/{}", (*Server).deleteDataSet},
+	{"GET", "/v1/datasets/{}/data", (*Server).exportDataSet},
+	{"PUT", "/v1/datasets/{}/data", (*Server).importDataSet},
+}
+
+var streamRoutes = []route{
+	{"GET", "/v1/streams/search", (*Server).searchStreams},
+	{"POST", "/v1/streams", (*Server).createStream},
+	{"GET", "/v1/streams/{}", (*Server).retrieveStream},
+	{"PATCH", "/v1/streams/{}", (*Server).updateStream},
+	{"DELETE", "/v1/streams/{}", (*Server).deleteStream},
+	{"GET", "/v1/streams/{}/executions", (*Server).listExecutions},
+	{"POST", "/v1/streams/{}/executions", (*Server).createExecution},
+	{"GET", "/v1/streams/{}/executions/{}", (*Server).retrieveExecution},
+	{"PUT", "/v1/streams/{}/executions/{}/part/{}", (*Server).uploadPart},
+	{"PUT", "/v1/streams/{}/executions/{}/commit", (*Server).commitExecution},
+	{"PUT", "/v1/streams/{}/executions/{}/abort", (*Server).abortExecution},
+}
+
+// AddDataSet stores a DataSet with the given rows, giving it an id if it has none
+func (s *Server) AddDataSet(ds domo.Dataset, records [][]string) domo.Dataset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addDataSet(ds, records).Dataset
+}
+
+func (s *Server) addDataSet(ds domo.Dataset, records [][]string) *dataset {
+	if ds.ID == "" {
+		ds.ID = fmt.Sprintf("00000000-0000-0000-0000-%012d", s.id())
+	}
+	now := time.Now().UTC()
+	ds.CreatedAt, ds.UpdatedAt = now, now
+	d := &dataset{Dataset: ds, Owner: s.Owner}
+	d.setRecords(records)
+	s.datasets[ds.ID] = d
+	return d
+}
+
+// Records the rows of a DataSet, nil if there is no such DataSet
+func (s *Server) Records(datasetID string) [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.datasets[datasetID]
+	if d == nil {
+		return nil
+	}
+	return append([][]string(nil), d.records...)
+}
+
+// SetRecords replaces the rows of a DataSet
+func (s *Server) SetRecords(datasetID string, records [][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d := s.datasets[datasetID]; d != nil {
+		d.setRecords(records)
+	}
+}
+
+// AddStream creates a DataSet with the schema and a stream that loads it
+func (s *Server) AddStream(name string, schema domo.Schema, updateMethod string, keyColumns ...string) domo.Stream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.addStream(domo.Dataset{Name: name, Schema: schema}, updateMethod, keyColumns)
+	return s.streamView(st)
+}
+
+func (s *Server) addStream(ds domo.Dataset, updateMethod string, keyColumns []string) *stream {
+	if updateMethod == "" {
+		updateMethod = domo.UpdateMethodAppend
+	}
+	d := s.addDataSet(ds, nil)
+	now := time.Now().UTC()
+	st := &stream{ID: s.id(), DataSetID: d.ID, UpdateMethod: updateMethod, KeyColumnNames: keyColumns, CreatedAt: now, ModifiedAt: now}
+	s.streams[st.ID] = st
+	return st
+}
+
+// StreamRecords the rows of the DataSet a stream loads
+func (s *Server) StreamRecords(streamID int) [][]string {
+	s.mu.Lock()
+	st := s.streams[streamID]
+	s.mu.Unlock()
+	if st == nil {
+		return nil
+	}
+	return s.Records(st.DataSetID)
+}
+
+// Executions the executions of a stream, oldest first
+func (s *Server) Executions(streamID int) (executions []domo.Execution) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st := s.streams[streamID]; st != nil {
+		for _, e := range st.executions {
+			executions = append(executions, e.Execution)
+		}
+	}
+	return
+}
+
+// setRecords replaces the rows and keeps the counts up to date
+func (d *dataset) setRecords(records [][]string) {
+	d.records = records
+	d.Rows = len(records)
+	d.Columns = len(d.Schema.Columns)
+	d.UpdatedAt = time.Now().UTC()
+}
+
+// summary the DataSet as it appears in lists
+func (d *dataset) summary() interface{} {
+	return struct {
+		domo.Dataset
+		Owner         domo.Owner `json:"owner"`
+		DataCurrentAt time.Time  `json:"dataCurrentAt"`
+	}{d.Dataset, d.Owner, d.UpdatedAt}
+}
+
+// parseCSV the records of a CSV body, which must fit the schema
+func parseCSV(body []byte, schema domo.Schema) ([][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = len(schema.Columns)
+	if len(schema.Columns) == 0 {
+		reader.FieldsPerRecord = 0
+	}
+	return reader.ReadAll()
+}
+
+// GET /v1/datasets
+func (s *Server) listDataSets(w http.ResponseWriter, r *http.Request, params []string) {
+	var ids []string
+	for id := range s.datasets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	from, to := window(r, len(ids))
+	list := []interface{}{}
+	for _, id := range ids[from:to] {
+		list = append(list, s.datasets[id].summary())
+	}
+	s.reply(w, http.StatusOK, list)
+}
+
+// POST /v1/datasets
+func (s *Server) createDataSet(w http.ResponseWriter, r *http.Request, params []string) {
+	var ds domo.Dataset
+	if !s.decode(w, r, &ds) {
+		return
+	}
+	if ds.Name == "" || len(ds.Schema.Columns) == 0 {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	ds.ID = ""
+	s.reply(w, http.StatusCreated, s.addDataSet(ds, nil).summary())
+}
+
+// GET /v1/datasets/{id}
+func (s *Server) retrieveDataSet(w http.ResponseWriter, r *http.Request, params []string) {
+	d := s.datasets[params[0]]
+	if d == nil {
+		s.fail(w, http.StatusNotFound)
+		return
+	}
+	s.reply(w, http.StatusOK, d.summary())
+}
+
+// PUT /v1/datasets/{id}, which can also change the owner
+func (s *Server) updateDataSet(w http.ResponseWriter, r *http.Request, params []string) {
+	d := s.datasets[params[0]]
+	if d == nil {
+		s.fail(w, http.StatusNotFound)
+		return
+	}
+	changed := struct {
+		domo.Dataset
+		Owner domo.Owner `json:"owner"`
+	}{d.Dataset, d.Owner}
+	if !s.patch(w, r, &changed) {
+		return
+	}
+	if changed.Owner.ID != d.Owner.ID {
+		user := s.users[changed.Owner.ID]
+		if user == nil {
+			s.fail(w, http.StatusBadRequest)
+			return
+		}
+		changed.Owner.Name = user.Name
+	}
+	changed.ID = d.ID
+	d.Dataset, d.Owner = changed.Dataset, changed.Owner
+	d.setRecords(d.records)
+	s.reply(w, http.StatusOK, d.summary())
+}
+
+// DELETE /v1/datasets/{id}
+func (s *Server) deleteDataSet(w http.ResponseWriter, r *http.Request, params []string) {
+	if s.datasets[params[0]] == nil {
+		s.fail(w, http.StatusNotFound)
+		return
+	}
+	delete(s.datasets, params[0])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /v1/datasets/{id}/data?includeHeader=true
+func (s *Server) exportDataSet(w http.ResponseWriter, r *http.Request, params []string) {
+	d := s.datasets[params[0]]
+	if d == nil {
+		s.fail(w, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	out := csv.NewWriter(w)
+	if r.URL.Query().Get("includeHeader") == "true" {
+		var header []string
+		for _, column := range d.Schema.Columns {
+			header = append(header, column.Name)
+		}
+		out.Write(header)
+	}
+	out.WriteAll(d.records)
+}
+
+// PUT /v1/datasets/{id}/data
+func (s *Server) importDataSet(w http.ResponseWriter, r *http.Request, params []string) {
+	d := s.datasets[params[0]]
+	if d == nil {
+		s.fail(w, http.StatusNotFound)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	records, err := parseCSV(body, d.Schema)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	d.setRecords(records)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// streamView the Stream as the API returns it
+func (s *Server) streamView(st *stream) domo.Stream {
+	view := domo.Stream{
+		ID:             st.ID,
+		UpdateMethod:   st.UpdateMethod,
+		KeyColumnNames: st.KeyColumnNames,
+		CreatedAt:      st.CreatedAt,
+		ModifiedAt:     st.ModifiedAt,
+	}
+	if d := s.datasets[st.DataSetID]; d != nil {
+		view.DataSet = domo.StreamDataSet{
+			ID:            d.ID,
+			Name:          d.Name,
+			Description:   d.Description,
+			Rows:          d.Rows,
+			Columns:       d.Columns,
+			Owner:         d.Owner,
+			DataCurrentAt: d.UpdatedAt,
+			CreatedAt:     d.CreatedAt,
+			UpdatedAt:     d.UpdatedAt,
+			PdpEnabled:    d.PdpEnabled,
+		}
+	}
+	return view
+}
+
+// lookupStream the stream named by the first path segment, failing the request with 404 if there is none
+func (s *Server) lookupStream(w http.ResponseWriter, params []string) *stream {
+	id, ok := s.intParam(w, params[0])
+	if !ok {
+		return nil
+	}
+	st := s.streams[id]
+	if st == nil {
+		s.fail(w, http.StatusNotFound)
+	}
+	return st
+}
+
+// lookupExecution the execution named by the second path segment
+func (s *Server) lookupExecution(w http.ResponseWriter, params []string) (*stream, *execution) {
+	st := s.lookupStream(w, params)
+	if st == nil {
+		return nil, nil
+	}
+	id, ok := s.intParam(w, params[1])
+	if !ok {
+		return nil, nil
+	}
+	for _, e := range st.executions {
+		if e.ID == id {
+			return st, e
+		}
+	}
+	s.fail(w, http.StatusNotFound)
+	return nil, nil
+}
+
+// GET /v1/streams/search?q=dataSource.name:{name} or q=dataSource.owner.id:{id}
+func (s *Server) searchStreams(w http.ResponseWriter, r *http.Request, params []string) {
+	q := r.URL.Query().Get("q")
+	var ids []int
+	for id, st := range s.streams {
+		d := s.datasets[st.DataSetID]
+		switch {
+		case d == nil:
+		case strings.HasPrefix(q, "dataSource.name:") && d.Name == strings.TrimPrefix(q, "dataSource.name:"),
+			strings.HasPrefix(q, "dataSource.owner.id:") && fmt.Sprint(d.Owner.ID) == strings.TrimPrefix(q, "dataSource.owner.id:"):
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	list := []domo.Stream{}
+	for _, id := range ids {
+		list = append(list, s.streamView(s.streams[id]))
+	}
+	s.reply(w, http.StatusOK, list)
+}
+
+// POST /v1/streams
+func (s *Server) createStream(w http.ResponseWriter, r *http.Request, params []string) {
+	var request struct {
+		DataSet        domo.Dataset `json:"dataSet"`
+		UpdateMethod   string       `json:"updateMethod"`
+		KeyColumnNames []string     `json:"keyColumnNames"`
+	}
+	if !s.decode(w, r, &request) {
+		return
+	}
+	if request.DataSet.Name == "" || len(request.DataSet.Schema.Columns) == 0 ||
+		(request.UpdateMethod != "" && !domo.CheckUpdateMethod(request.UpdateMethod)) ||
+		(request.UpdateMethod == domo.UpdateMethodUpsert && len(request.KeyColumnNames) == 0) {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	request.DataSet.ID = ""
+	st := s.addStream(request.DataSet, request.UpdateMethod, request.KeyColumnNames)
+	s.reply(w, http.StatusCreated, s.streamView(st))
+}
+
+// GET /v1/streams/{id}
+func (s *Server) retrieveStream(w http.ResponseWriter, r *http.Request, params []string) {
+	if st := s.lookupStream(w, params); st != nil {
+		s.reply(w, http.StatusOK, s.streamView(st))
+	}
+}
+
+// PATCH /v1/streams/{id}
+func (s *Server) updateStream(w http.ResponseWriter, r *http.Request, params []string) {
+	st := s.lookupStream(w, params)
+	if st == nil {
+		return
+	}
+	var request struct {
+		UpdateMethod   string   `json:"updateMethod"`
+		KeyColumnNames []string `json:"keyColumnNames"`
+	}
+	if !s.decode(w, r, &request) {
+		return
+	}
+	if request.UpdateMethod != "" {
+		if !domo.CheckUpdateMethod(request.UpdateMethod) {
+			s.fail(w, http.StatusBadRequest)
+			return
+		}
+		st.UpdateMethod = request.UpdateMethod
+	}
+	if request.KeyColumnNames != nil {
+		st.KeyColumnNames = request.KeyColumnNames
+	}
+	if st.UpdateMethod == domo.UpdateMethodUpsert && len(st.KeyColumnNames) == 0 {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	st.ModifiedAt = time.Now().UTC()
+	s.reply(w, http.StatusOK, s.streamView(st))
+}
+
+// DELETE /v1/streams/{id}, which leaves the DataSet behind
+func (s *Server) deleteStream(w http.ResponseWriter, r *http.Request, params []string) {
+	if st := s.lookupStream(w, params); st != nil {
+		delete(s.streams, st.ID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GET /v1/streams/{id}/executions
+func (s *Server) listExecutions(w http.ResponseWriter, r *http.Request, params []string) {
+	st := s.lookupStream(w, params)
+	if st == nil {
+		return
+	}
+	from, to := window(r, len(st.executions))
+	list := []domo.Execution{}
+	for _, e := range st.executions[from:to] {
+		list = append(list, e.Execution)
+	}
+	s.reply(w, http.StatusOK, list)
+}
+
+// POST /v1/streams/{id}/executions
+func (s *Server) createExecution(w http.ResponseWriter, r *http.Request, params []string) {
+	st := s.lookupStream(w, params)
+	if st == nil {
+		return
+	}
+	now := time.Now().UTC()
+	e := &execution{parts: make(map[int]string)}
+	e.ID = len(st.executions) + 1
+	e.CurrentState = "ACTIVE"
+	e.UpdateMethod = st.UpdateMethod
+	e.StartedAt, e.CreatedAt, e.ModifiedAt = now, now, now
+	st.executions = append(st.executions, e)
+	s.reply(w, http.StatusCreated, e.Execution)
+}
+
+// GET /v1/streams/{id}/executions/{execution}
+func (s *Server) retrieveExecution(w http.ResponseWriter, r *http.Request, params []string) {
+	if _, e := s.lookupExecution(w, params); e != nil {
+		s.reply(w, http.StatusOK, e.Execution)
+	}
+}
+
+// PUT /v1/streams/{id}/executions/{execution}/part/{part}
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, params []string) {
+	st, e := s.lookupExecution(w, params)
+	if e == nil {
+		return
+	}
+	partID, ok := s.intParam(w, params[2])
+	if !ok {
+		return
+	}
+	if e.CurrentState != "ACTIVE" {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if _, err := parseCSV(body, s.datasets[st.DataSetID].Schema); err != nil {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	e.parts[partID] = string(body)
+	e.ModifiedAt = time.Now().UTC()
+	s.reply(w, http.StatusOK, e.Execution)
+}
+
+// PUT /v1/streams/{id}/executions/{execution}/commit loads the parts, in part order, into the DataSet
+func (s *Server) commitExecution(w http.ResponseWriter, r *http.Request, params []string) {
+	st, e := s.lookupExecution(w, params)
+	if e == nil {
+		return
+	}
+	d := s.datasets[st.DataSetID]
+	if e.CurrentState != "ACTIVE" || d == nil {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+
+	var partIDs []int
+	for id := range e.parts {
+		partIDs = append(partIDs, id)
+	}
+	sort.Ints(partIDs)
+	var records [][]string
+	for _, id := range partIDs {
+		part, _ := parseCSV([]byte(e.parts[id]), d.Schema)
+		records = append(records, part...)
+	}
+
+	switch st.UpdateMethod {
+	case domo.UpdateMethodReplace:
+		d.setRecords(records)
+	case domo.UpdateMethodUpsert:
+		d.setRecords(upsert(d.records, records, d.Schema, st.KeyColumnNames))
+	default:
+		d.setRecords(append(d.records, records...))
+	}
+
+	now := time.Now().UTC()
+	e.CurrentState = "SUCCESS"
+	e.EndedAt, e.ModifiedAt = now, now
+	s.reply(w, http.StatusOK, e.Execution)
+}
+
+// PUT /v1/streams/{id}/executions/{execution}/abort
+func (s *Server) abortExecution(w http.ResponseWriter, r *http.Request, params []string) {
+	_, e := s.lookupExecution(w, params)
+	if e == nil {
+		return
+	}
+	if e.CurrentState != "ACTIVE" {
+		s.fail(w, http.StatusBadRequest)
+		return
+	}
+	now := time.Now().UTC()
+	e.CurrentState = "ABORTED"
+	e.EndedAt, e.ModifiedAt = now, now
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// upsert replaces the rows whose key columns match a new row, and appends the rest
+func upsert(existing [][]string, records [][]string, schema domo.Schema, keyColumns []string) [][]string {
+	var indexes []int
+	for _, key := range keyColumns {
+		for i, column := range schema.Columns {
+			if column.Name == key {
+				indexes = append(indexes, i)
+			}
+		}
+	}
+	keyOf := func(record []string) string {
+		var key []string
+		for _, i := range indexes {
+			key = append(key, record[i])
+		}
+		return strings.Join(key, "\x00")
+	}
+
+	result := append([][]string(nil), existing...)
+	position := make(map[string]int)
+	for i, record := range result {
+		position[keyOf(record)] = i
+	}
+	for _, record := range records {
+		key := keyOf(record)
+		if i, ok := position[key]; ok {
+			result[i] = record
+			continue
+		}
+		position[key] = len(result)
+		result = append(result, record)
+	}
+	return result
+}
@@ -1,7 +1,9 @@
 package domo
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -23,7 +25,7 @@ func (g *GroupService) Retrieve(groupID int) (group Group, err error) {
 
 	g.client.getAccessToken("user")
 
-	url := fmt.Sprintf("%s/v1/groups/%d", baseURL, groupID)
+	url := fmt.Sprintf("%s/v1/groups/%d", g.client.baseURL, groupID)
 	bodyBytes, _, err := g.client.genericGET(url, nil)
 
 	err = json.Unmarshal(bodyBytes, &group)
@@ -39,74 +41,65 @@ func (g *GroupService) Retrieve(groupID int) (group Group, err error) {
 	return
 }
 
+// GroupCreate the fields of a new group. Name is required.
+type GroupCreate struct {
+	Name    string `json:"name"`    // The name of the group
+	Default bool   `json:"default"` // Whether new users are added to the group
+}
+
+// GroupPatch the fields of a group to change. Fields left nil keep their current value.
+type GroupPatch struct {
+	Name    *string `json:"name,omitempty"`
+	Active  *bool   `json:"active,omitempty"`
+	Default *bool   `json:"default,omitempty"`
+}
+
+// Bool a pointer to v, for the fields of a GroupPatch
+func Bool(v bool) *bool {
+	return &v
+}
+
 // Create Creates a new group in your Domo instance.
 // Definition
 // POST https://api.domo.com/v1/groups
 // Returns
 // Returns a group object when successful.
 // The returned group will have user attributes based on the information that was provided when group was created.
-func (g *GroupService) Create(name string, isDefault bool) (group *Group, err error) {
-	g.client.getAccessToken("user")
-
-	url := fmt.Sprintf("%s/v1/groups", baseURL)
-	payload := fmt.Sprintf("{\"name\": \"%s\",\"default\": %t}", name, isDefault)
-	body := strings.NewReader(payload)
-
-	header := map[string]string{
-		"Content-Type": "application/json",
+func (g *GroupService) Create(ctx context.Context, create GroupCreate) (group Group, err error) {
+	if create.Name == "" {
+		err = errors.New("A new group needs a name")
+		return
 	}
-
-	bodyBytes, statusCode, err := g.client.genericPOST(url, body, header)
-
+	bodyBytes, err := g.client.sendJSON(ctx, "user", "POST", fmt.Sprintf("%s/v1/groups", g.client.baseURL), create)
 	if err != nil {
-		return group, fmt.Errorf(
-			"Unable to Create response from Domo API %s",
-			err,
-		)
+		err = fmt.Errorf("Failed to create group %s : %s", create.Name, err)
+		return
 	}
 
-	logger(fmt.Sprintf("createStream Status Code : %d", statusCode))
-	err = json.Unmarshal(bodyBytes, &group)
-	bodyString := string(bodyBytes)
-
-	logger("createStream" + bodyString)
-
+	if err = json.Unmarshal(bodyBytes, &group); err != nil {
+		err = fmt.Errorf("Unable to unmarshal group %s", err)
+	}
+	logger(fmt.Sprintf("[GroupService] Create : group '%s' created with ID %d", group.Name, group.ID))
 	return
-
 }
 
-// Update Updates the specified group by providing values to parameters passed.
-// Any parameter left out of the request will cause the specific group’s attribute to remain unchanged.
+// Update Updates the specified group with the fields set in patch.
+// Any field left nil will cause the specific group’s attribute to remain unchanged.
 // Definition
 // PUT https://api.domo.com/v1/groups/{GROUP_ID}
 // Returns
-// Returns the parameter of success or error based on the group ID being valid.
-func (g *GroupService) Update(groupID int, name string, isActive bool, isDefault bool) (err error) {
-
-	g.client.getAccessToken("user")
-
-	url := fmt.Sprintf("%s/v1/groups/%d", baseURL, groupID)
-	payload := fmt.Sprintf("{\"name\": \"%s\",\"active\": %t ,\"default\": %t}", name, isActive, isDefault)
-
-	body := strings.NewReader(payload)
-
-	header := map[string]string{
-		"Content-Type": "application/json",
-	}
-
-	_, statusCode, err := g.client.genericPUT(url, body, header)
-
-	logger(fmt.Sprintf("updategroup Status Code : %d", statusCode))
-
+// Returns the updated group object when successful.
+func (g *GroupService) Update(ctx context.Context, groupID int, patch GroupPatch) (group Group, err error) {
+	bodyBytes, err := g.client.sendJSON(ctx, "user", "PUT", fmt.Sprintf("%s/v1/groups/%d", g.client.baseURL, groupID), patch)
 	if err != nil {
-		err = fmt.Errorf(
-			"Unable to Create response from Domo API %s",
-			err,
-		)
+		err = fmt.Errorf("Failed to update group %d : %s", groupID, err)
+		return
 	}
 
+	if err = json.Unmarshal(bodyBytes, &group); err != nil {
+		err = fmt.Errorf("Unable to unmarshal group %s", err)
+	}
 	return
-
 }
 
 // Delete Permanently deletes a group from your Domo instance.
@@ -119,7 +112,7 @@ func (g *GroupService) Delete(groupID int) (err error) {
 
 	g.client.getAccessToken("user")
 
-	url := fmt.Sprintf("%s/v1/groups/%d", baseURL, groupID)
+	url := fmt.Sprintf("%s/v1/groups/%d", g.client.baseURL, groupID)
 	statusCode, err := g.client.genericDELETE(url, nil)
 
 	if err != nil {
@@ -148,7 +141,7 @@ func (g *GroupService) List() (userGroups Groups, err error) {
 
 	g.client.getAccessToken("user")
 
-	url := fmt.Sprintf("%s/v1/groups", baseURL)
+	url := fmt.Sprintf("%s/v1/groups", g.client.baseURL)
 	bodyBytes, _, err := g.client.genericGET(url, nil)
 
 	if err != nil {
@@ -171,29 +164,77 @@ func (g *GroupService) List() (userGroups Groups, err error) {
 	return
 }
 
-// Find locates group by name from your Domo instance.
+// groupPageSize the most groups, or members of a group, Domo returns in one page
+const groupPageSize = 500
+
+// ListAll Get every group in your Domo instance, a page at a time.
+// Definition
+// GET https://api.domo.com/v1/groups?limit={LIMIT}&offset={OFFSET}
 // Returns
-// Returns the ID og the group
-func (g *GroupService) Find(name string) (groupID int, err error) {
+// Returns all group objects.
+func (g *GroupService) ListAll(ctx context.Context) (groups Groups, err error) {
+	for offset := 0; ; offset += groupPageSize {
+		url := fmt.Sprintf("%s/v1/groups?limit=%d&offset=%d", g.client.baseURL, groupPageSize, offset)
+		bodyBytes, err := g.client.sendJSON(ctx, "user", "GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list groups : %s", err)
+		}
 
-	g.client.getAccessToken("user")
-	data, err := g.List()
+		var page Groups
+		if err = json.Unmarshal(bodyBytes, &page); err != nil {
+			return nil, fmt.Errorf("Unable to unmarshal list %s", err)
+		}
+
+		groups = append(groups, page...)
+		if len(page) < groupPageSize {
+			logger(fmt.Sprintf("[GroupService] ListAll : %d groups found", len(groups)))
+			return groups, nil
+		}
+	}
+}
 
+// Find locates group by name, ignoring case and surrounding spaces, from your Domo instance.
+// Returns
+// Returns the ID of the group, a NotFoundError if no group has the name,
+// or an AmbiguousError listing the groups if several have it
+func (g *GroupService) Find(name string) (groupID int, err error) {
+	groups, err := g.ListAll(context.Background())
 	if err != nil {
 		return
 	}
 
-	for _, r := range data {
-		if r.Name == name {
-			groupID = r.ID
+	var candidates []Candidate
+	for _, r := range groups {
+		if strings.EqualFold(strings.TrimSpace(r.Name), strings.TrimSpace(name)) {
+			candidates = append(candidates, Candidate{ID: r.ID, Name: r.Name})
 		}
 	}
+	groupID, err = lookupOne("group", "name", name, candidates)
 
 	logger(fmt.Sprintf("[GroupService] FindGroup : '%s' found ID %d", name, groupID))
 
 	return
 }
 
+// Search finds the groups whose names match a pattern, ignoring case.
+// A pattern with * or ? must match the whole name, any other pattern matches names that start with it.
+// Returns
+// Returns the matching groups, in the order Domo listed them
+func (g *GroupService) Search(ctx context.Context, pattern string) (found Groups, err error) {
+	groups, err := g.ListAll(ctx)
+	if err != nil {
+		return
+	}
+
+	match := matcher(pattern)
+	for _, r := range groups {
+		if match(r.Name) {
+			found = append(found, r)
+		}
+	}
+	return
+}
+
 // AddUser Add to a group Add user to a group in your Domo instance.
 // Definition
 // PUT https://api.domo.com/v1/groups/{GROUP_ID}/users/{USER_ID}
@@ -202,7 +243,7 @@ func (g *GroupService) Find(name string) (groupID int, err error) {
 func (g *GroupService) AddUser(groupID int, userID int) (err error) {
 	g.client.getAccessToken("user")
 
-	url := fmt.Sprintf("%s/v1/groups/%d/users/%d", baseURL, groupID, userID)
+	url := fmt.Sprintf("%s/v1/groups/%d/users/%d", g.client.baseURL, groupID, userID)
 
 	header := map[string]string{
 		"Content-Type": "application/json",
@@ -231,7 +272,7 @@ func (g *GroupService) ListUsers(groupID int) (groupUsers GroupUsers, err error)
 
 	g.client.getAccessToken("user")
 
-	url := fmt.Sprintf("%s/v1/groups/%d/users", baseURL, groupID)
+	url := fmt.Sprintf("%s/v1/groups/%d/users", g.client.baseURL, groupID)
 	bodyBytes, _, err := g.client.genericGET(url, nil)
 
 	if err != nil {
@@ -255,6 +296,32 @@ func (g *GroupService) ListUsers(groupID int) (groupUsers GroupUsers, err error)
 	return
 }
 
+// ListAllUsers Get the ids of every user in a group, a page at a time.
+// Definition
+// GET https://api.domo.com/v1/groups/{GROUP_ID}/users?limit={LIMIT}&offset={OFFSET}
+// Returns
+// Returns the ids of all the members of the group.
+func (g *GroupService) ListAllUsers(ctx context.Context, groupID int) (userIDs GroupUsers, err error) {
+	for offset := 0; ; offset += groupPageSize {
+		url := fmt.Sprintf("%s/v1/groups/%d/users?limit=%d&offset=%d", g.client.baseURL, groupID, groupPageSize, offset)
+		bodyBytes, err := g.client.sendJSON(ctx, "user", "GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list users of group %d : %s", groupID, err)
+		}
+
+		var page GroupUsers
+		if err = json.Unmarshal(bodyBytes, &page); err != nil {
+			return nil, fmt.Errorf("Unable to unmarshal GroupUsers %s", err)
+		}
+
+		userIDs = append(userIDs, page...)
+		if len(page) < groupPageSize {
+			logger(fmt.Sprintf("[GroupService] ListAllUsers : %d users found", len(userIDs)))
+			return userIDs, nil
+		}
+	}
+}
+
 // RemoveUser from a group Remove a user from a group in your Domo instance.
 // Definition
 // DELETE https://api.domo.com/v1/groups/{GROUP_ID}/users/{USER_ID}
@@ -264,7 +331,7 @@ func (g *GroupService) RemoveUser(groupID int, userID int) (err error) {
 
 	g.client.getAccessToken("user")
 
-	url := fmt.Sprintf("%s/v1/groups/%d/users/%d", baseURL, groupID, userID)
+	url := fmt.Sprintf("%s/v1/groups/%d/users/%d", g.client.baseURL, groupID, userID)
 
 	statusCode, err := g.client.genericDELETE(url, nil)
 
@@ -1,8 +1,13 @@
 package domo
 
 import (
+	"context"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGroupService_Retrieve(t *testing.T) {
@@ -307,3 +312,49 @@ func TestGroupService_RemoveUser(t *testing.T) {
 		})
 	}
 }
+
+func TestGroupService_Create(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token":  tokenRoute,
+		"POST /v1/groups":   {responseCode: 201, response: `{"id": 12, "name": "Sales \"EMEA\"", "active": true}`},
+		"PUT /v1/groups/12": {responseCode: 200, response: `{"id": 12, "name": "Sales \"EMEA\"", "active": false}`},
+	}}
+	g := CreateTestClient(doer)
+
+	group, err := g.Group.Create(context.Background(), GroupCreate{Name: `Sales "EMEA"`})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 12, group.ID, "Bad group")
+	assert.Equal(t, `{"name":"Sales \"EMEA\"","default":false}`, doer.bodies["POST /v1/groups"], "Bad create body")
+
+	group, err = g.Group.Update(context.Background(), 12, GroupPatch{Active: Bool(false)})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, false, group.Active, "Bad updated group")
+	assert.Equal(t, `{"active":false}`, doer.bodies["PUT /v1/groups/12"], "Bad update body")
+
+	_, err = g.Group.Update(context.Background(), 13, GroupPatch{Name: String("Sales")})
+	assert.NotEqual(t, nil, err, "Missing group not reported")
+	_, err = g.Group.Create(context.Background(), GroupCreate{})
+	assert.NotEqual(t, nil, err, "Nameless group accepted")
+}
+
+func TestGroupService_ListAllUsers(t *testing.T) {
+	page := make([]string, 500)
+	for i := range page {
+		page[i] = strconv.Itoa(i + 1)
+	}
+	doer := &pageDoer{pages: []string{"[" + strings.Join(page, ",") + "]", `[501]`}}
+	d := CreateTestClient(doer)
+
+	ids, err := d.Group.ListAllUsers(context.Background(), 5)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 501, len(ids), "Bad member count")
+	assert.Equal(t, 501, ids[500], "Bad last member")
+	assert.Equal(t, []string{"limit=500&offset=0", "limit=500&offset=500"}, doer.queries, "Bad pages")
+
+	d = CreateTestClient(&routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token":       tokenRoute,
+		"GET /v1/groups/5/users": {responseCode: 403, response: `{"status":403,"statusReason":"Forbidden","toe":"TEST"}`},
+	}})
+	_, err = d.Group.ListAllUsers(context.Background(), 5)
+	assert.NotEqual(t, nil, err, "Failed list not reported")
+}
@@ -0,0 +1,188 @@
+package domo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MembershipParallel the number of requests that add or remove group members at the same time
+const MembershipParallel = 4
+
+// MembershipChange the members a SetMembers added and removed
+type MembershipChange struct {
+	Added   []int // Users now in the group
+	Removed []int // Users no longer in the group
+}
+
+// bulkSupport remembers an instance that has no bulk membership endpoints, so they are only tried once
+type bulkSupport struct {
+	sync.Mutex
+	unsupported bool
+}
+
+func (b *bulkSupport) available() bool {
+	b.Lock()
+	defer b.Unlock()
+	return !b.unsupported
+}
+
+func (b *bulkSupport) disable() {
+	b.Lock()
+	defer b.Unlock()
+	b.unsupported = true
+}
+
+// SetMembers Makes the users the only members of a group, adding the ones missing and removing the rest.
+// The adds and removes are made concurrently, in bulk where the instance allows.
+// Returns
+// Returns the users added and removed, and an error listing any that failed
+func (g *GroupService) SetMembers(ctx context.Context, groupID int, userIDs []int) (change MembershipChange, err error) {
+	current, err := g.ListAllUsers(ctx, groupID)
+	if err != nil {
+		return
+	}
+
+	want := make(map[int]bool)
+	for _, id := range userIDs {
+		want[id] = true
+	}
+	have := make(map[int]bool)
+	var add, remove []int
+	for _, id := range current {
+		have[id] = true
+		if !want[id] {
+			remove = append(remove, id)
+		}
+	}
+	for id := range want {
+		if !have[id] {
+			add = append(add, id)
+		}
+	}
+	sort.Ints(add)
+	sort.Ints(remove)
+
+	var addFailed, removeFailed map[int]error
+	change.Added, addFailed = g.changeMembers(ctx, groupID, add, true)
+	change.Removed, removeFailed = g.changeMembers(ctx, groupID, remove, false)
+	if failed := append(failureList(addFailed), failureList(removeFailed)...); len(failed) > 0 {
+		err = fmt.Errorf("%d of %d membership changes to group %d failed: %s",
+			len(failed), len(add)+len(remove), groupID, strings.Join(failed, ", "))
+	}
+	return
+}
+
+// AddUsers Adds users to a group, in bulk where the instance allows, otherwise concurrently one at a time.
+// Returns
+// Returns a *MembershipError naming the users that couldn't be added
+func (g *GroupService) AddUsers(ctx context.Context, groupID int, userIDs []int) error {
+	_, failed := g.changeMembers(ctx, groupID, userIDs, true)
+	return membershipError("add", groupID, failed, len(userIDs))
+}
+
+// RemoveUsers Removes users from a group, in bulk where the instance allows, otherwise concurrently one at a time.
+// Returns
+// Returns a *MembershipError naming the users that couldn't be removed
+func (g *GroupService) RemoveUsers(ctx context.Context, groupID int, userIDs []int) error {
+	_, failed := g.changeMembers(ctx, groupID, userIDs, false)
+	return membershipError("remove", groupID, failed, len(userIDs))
+}
+
+// MembershipError the users an AddUsers or RemoveUsers couldn't change
+type MembershipError struct {
+	Verb    string        // add or remove
+	GroupID int           // The group changed
+	Total   int           // The users asked for
+	Failed  map[int]error // Why each user that failed did, by user id
+}
+
+func (e *MembershipError) Error() string {
+	return fmt.Sprintf("Unable to %s %d of %d users of group %d: %s", e.Verb, len(e.Failed), e.Total, e.GroupID, strings.Join(failureList(e.Failed), ", "))
+}
+
+func membershipError(verb string, groupID int, failed map[int]error, total int) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	return &MembershipError{Verb: verb, GroupID: groupID, Total: total, Failed: failed}
+}
+
+// failureList describes each failure as "id reason", in order of user id
+func failureList(failed map[int]error) []string {
+	ids := make([]int, 0, len(failed))
+	for id := range failed {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = fmt.Sprintf("%d %s", id, failed[id])
+	}
+	return list
+}
+
+// changeMembers adds or removes the users, first trying the bulk endpoint.
+// Instances without one answer 405 or 501, and the users are then changed one request each.
+// A 404 is taken to be about the group, or a user, so it fails the change rather than giving up on bulk.
+func (g *GroupService) changeMembers(ctx context.Context, groupID int, userIDs []int, add bool) (done []int, failed map[int]error) {
+	if len(userIDs) == 0 {
+		return
+	}
+	method := "DELETE"
+	if add {
+		method = "PUT"
+	}
+
+	if g.client.groupBulk.available() {
+		_, err := g.client.sendJSON(ctx, "user", method, fmt.Sprintf("%s/v1/groups/%d/users", g.client.baseURL, groupID), userIDs)
+		var status *statusError
+		switch {
+		case err == nil:
+			return userIDs, nil
+		case errors.As(err, &status) && (status.statusCode == 405 || status.statusCode == 501):
+			logger(fmt.Sprintf("[GroupService] no bulk membership endpoint, %s", err))
+			g.client.groupBulk.disable()
+		default:
+			failed = make(map[int]error)
+			for _, id := range userIDs {
+				failed[id] = err
+			}
+			return
+		}
+	}
+
+	errs := make([]error, len(userIDs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < MembershipParallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				url := fmt.Sprintf("%s/v1/groups/%d/users/%d", g.client.baseURL, groupID, userIDs[i])
+				_, errs[i] = g.client.sendJSON(ctx, "user", method, url, nil)
+			}
+		}()
+	}
+	for i := range userIDs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			if failed == nil {
+				failed = make(map[int]error)
+			}
+			failed[userIDs[i]] = err
+			continue
+		}
+		done = append(done, userIDs[i])
+	}
+	return
+}
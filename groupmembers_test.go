@@ -0,0 +1,87 @@
+package domo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupService_SetMembers(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token":            tokenRoute,
+		"GET /v1/groups/5/users":      {responseCode: 200, response: `[1, 2, 3]`},
+		"PUT /v1/groups/5/users":      {responseCode: 405, response: `{"status":405,"statusReason":"Method Not Allowed","toe":"TEST"}`},
+		"PUT /v1/groups/5/users/*":    {responseCode: 204},
+		"DELETE /v1/groups/5/users/*": {responseCode: 204},
+		"DELETE /v1/groups/5/users/3": {responseCode: 403, response: `{"status":403,"statusReason":"Forbidden","toe":"TEST"}`},
+	}}
+	g := CreateTestClient(doer)
+
+	change, err := g.Group.SetMembers(context.Background(), 5, []int{2, 4, 5})
+	assert.NotEqual(t, nil, err, "Failed remove not reported")
+	assert.Equal(t, []int{4, 5}, change.Added, "Bad adds")
+	assert.Equal(t, []int{1}, change.Removed, "Bad removes")
+	assert.Equal(t, 1, doer.count("PUT /v1/groups/5/users"), "Bulk add not tried")
+	assert.Equal(t, 0, doer.count("DELETE /v1/groups/5/users"), "Bulk remove tried again")
+	assert.Equal(t, 1, doer.count("PUT /v1/groups/5/users/4"), "Bad add")
+	assert.Equal(t, 0, doer.count("DELETE /v1/groups/5/users/2"), "Kept member removed")
+}
+
+func TestGroupService_SetMembers_bulk(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token":          tokenRoute,
+		"GET /v1/groups/5/users":    {responseCode: 200, response: `[1, 2]`},
+		"PUT /v1/groups/5/users":    {responseCode: 204},
+		"DELETE /v1/groups/5/users": {responseCode: 204},
+	}}
+	g := CreateTestClient(doer)
+
+	change, err := g.Group.SetMembers(context.Background(), 5, []int{2, 3, 4})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, []int{3, 4}, change.Added, "Bad adds")
+	assert.Equal(t, `[3,4]`, doer.bodies["PUT /v1/groups/5/users"], "Bad bulk add")
+	assert.Equal(t, `[1]`, doer.bodies["DELETE /v1/groups/5/users"], "Bad bulk remove")
+	assert.Equal(t, 0, doer.count("PUT /v1/groups/5/users/*"), "Users added one at a time")
+
+	change, err = g.Group.SetMembers(context.Background(), 5, []int{1, 2})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 0, len(change.Added)+len(change.Removed), "Changed nothing and still sent requests")
+}
+
+func TestGroupService_AddUsers(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token":         tokenRoute,
+		"PUT /v1/groups/5/users":   {responseCode: 405, response: `{"status":405,"statusReason":"Method Not Allowed","toe":"TEST"}`},
+		"PUT /v1/groups/5/users/*": {responseCode: 204},
+		"PUT /v1/groups/5/users/3": {responseCode: 403, response: `{"status":403,"statusReason":"Forbidden","toe":"TEST"}`},
+	}}
+	g := CreateTestClient(doer)
+
+	err := g.Group.AddUsers(context.Background(), 5, []int{2, 3, 4})
+	var membership *MembershipError
+	assert.Equal(t, true, errors.As(err, &membership), "Bad error type")
+	assert.Equal(t, 1, len(membership.Failed), "Bad failure count")
+	assert.NotEqual(t, nil, membership.Failed[3], "Failed add not named")
+	assert.Equal(t, "Unable to add 1 of 3 users of group 5: 3 403 Forbidden", err.Error(), "Bad message")
+
+	assert.Equal(t, nil, g.Group.AddUsers(context.Background(), 5, []int{2}), "Bad error code")
+}
+
+func TestGroupService_AddUsers_notFound(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token":         tokenRoute,
+		"PUT /v1/groups/9/users":   {responseCode: 404, response: `{"status":404,"statusReason":"Not Found","toe":"TEST"}`},
+		"PUT /v1/groups/5/users":   {responseCode: 204},
+		"PUT /v1/groups/9/users/*": {responseCode: 404, response: `{"status":404,"statusReason":"Not Found","toe":"TEST"}`},
+	}}
+	g := CreateTestClient(doer)
+
+	err := g.Group.AddUsers(context.Background(), 9, []int{2, 3})
+	assert.NotEqual(t, nil, err, "Add to a missing group not reported")
+	assert.Equal(t, 0, doer.count("PUT /v1/groups/9/users/*"), "Users added one at a time after a 404")
+
+	assert.Equal(t, nil, g.Group.AddUsers(context.Background(), 5, []int{2, 3}), "Bad error code")
+	assert.Equal(t, 1, doer.count("PUT /v1/groups/5/users"), "Bulk given up after a 404")
+}
@@ -0,0 +1,153 @@
+// Package ingest turns files that aren't CSV into Domo CSV.
+//
+// Each adapter is a Reader that maps its input onto a Domo Schema and hands back records as the text
+// Domo expects. A Reader can be uploaded to a stream with Upload, or replace the data of a DataSet with Import.
+//
+// JSON Lines and XLSX are always available. Parquet needs the parquet build tag.
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// Reader a source of records that fit a Domo schema
+type Reader interface {
+	// Schema the columns every record has
+	Schema() domo.Schema
+	// Read returns the next record, or io.EOF when there are no more
+	Read() (record []string, err error)
+}
+
+// CSV renders the records of r as CSV text, ready for Stream.UploadReader.
+// Close the result if it is abandoned before the end.
+func CSV(r Reader) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeCSV(r, writer))
+	}()
+	return reader
+}
+
+// writeCSV copies every record of r to w
+func writeCSV(r Reader, w io.Writer) error {
+	out := csv.NewWriter(w)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// Upload sends every record of r to the stream as one execution.
+// Cancelling ctx stops the upload.
+func Upload(ctx context.Context, client *domo.Client, streamID int, r Reader, opts domo.UploadOptions) (domo.UploadSummary, error) {
+	reader := CSV(r)
+	summary, err := client.Stream.UploadReaderContext(ctx, streamID, reader, opts)
+	reader.Close()
+	return summary, err
+}
+
+// Import replaces the data of the DataSet with every record of r.
+func Import(client *domo.Client, datasetID string, r Reader) error {
+	var buf bytes.Buffer
+	if err := writeCSV(r, &buf); err != nil {
+		return err
+	}
+	if buf.Len() == 0 {
+		return errors.New("Nothing to import")
+	}
+	return client.DataSet.Import(datasetID, buf.String())
+}
+
+// formatTime the text Domo expects for a DATE or DATETIME, both in UTC
+func formatTime(t time.Time, columnType string) string {
+	if columnType == domo.ColumnDate {
+		return t.UTC().Format("2006-01-02")
+	}
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// widen the type that fits values of both types
+func widen(current string, next string) string {
+	switch {
+	case current == "" || current == next:
+		return next
+	case next == "":
+		return current
+	case current == domo.ColumnLong && next == domo.ColumnDouble, current == domo.ColumnDouble && next == domo.ColumnLong:
+		return domo.ColumnDouble
+	case current == domo.ColumnDate && next == domo.ColumnDateTime, current == domo.ColumnDateTime && next == domo.ColumnDate:
+		return domo.ColumnDateTime
+	default:
+		return domo.ColumnString
+	}
+}
+
+// checkText whether text is a value of the column type, as Domo would reject the upload otherwise
+func checkText(text string, columnType string) error {
+	var err error
+	switch columnType {
+	case domo.ColumnLong:
+		_, err = strconv.ParseInt(text, 10, 64)
+	case domo.ColumnDouble, domo.ColumnDecimal:
+		_, err = strconv.ParseFloat(text, 64)
+	case domo.ColumnDate:
+		_, err = time.Parse("2006-01-02", text)
+	case domo.ColumnDateTime:
+		_, err = time.Parse(time.RFC3339Nano, text)
+	}
+	if err != nil {
+		return fmt.Errorf("'%s' is not a %s", text, columnType)
+	}
+	return nil
+}
+
+// numberType LONG for whole numbers, DOUBLE for the rest
+func numberType(text string) string {
+	if _, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return domo.ColumnLong
+	}
+	return domo.ColumnDouble
+}
+
+// textType DATE or DATETIME for text that holds one, STRING otherwise
+func textType(text string) string {
+	if _, err := time.Parse("2006-01-02", text); err == nil {
+		return domo.ColumnDate
+	}
+	if _, err := time.Parse(time.RFC3339Nano, text); err == nil {
+		return domo.ColumnDateTime
+	}
+	return domo.ColumnString
+}
+
+// schemaOf builds a schema from names and types, STRING where no type was seen
+func schemaOf(names []string, types []string) (schema domo.Schema) {
+	schema.Columns = make(domo.Columns, len(names))
+	for i, name := range names {
+		schema.Columns[i].Name = name
+		schema.Columns[i].Type = types[i]
+		if types[i] == "" {
+			schema.Columns[i].Type = domo.ColumnString
+		}
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDomo records the parts uploaded to stream 3 and the data imported into DataSet "ds"
+type fakeDomo struct {
+	sync.Mutex
+	uploaded string
+	imported string
+}
+
+func (f *fakeDomo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch {
+	case r.URL.Path == "/oauth/token":
+		fmt.Fprint(w, `{"access_token": "token", "expires_in": 3600}`)
+	case r.URL.Path == "/v1/streams/3/executions":
+		w.WriteHeader(201)
+		fmt.Fprint(w, `{"id": 1, "currentState": "ACTIVE"}`)
+	case strings.HasPrefix(r.URL.Path, "/v1/streams/3/executions/1/part/"):
+		f.uploaded += string(body)
+		fmt.Fprint(w, `{"id": 1}`)
+	case r.URL.Path == "/v1/streams/3/executions/1/commit":
+		fmt.Fprint(w, `{"id": 1, "currentState": "SUCCESS"}`)
+	case r.Method == "PUT" && r.URL.Path == "/v1/datasets/ds/data":
+		f.imported = string(body)
+		w.WriteHeader(204)
+	default:
+		w.WriteHeader(404)
+		fmt.Fprint(w, `{"status": 404, "statusReason": "Not Found"}`)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	fake := &fakeDomo{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := domo.New("<clientID>", "<secret>")
+	client.SetBaseURL(server.URL)
+
+	r, _ := NewJSONLines(strings.NewReader(`{"id": 1, "name": "a, b"}`+"\n"+`{"id": 2, "name": "c"}`), JSONOptions{})
+	summary, err := Upload(context.Background(), client, 3, r, domo.UploadOptions{})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, int64(2), summary.Rows, "Bad row count")
+	assert.Equal(t, "1,\"a, b\"\n2,c\n", fake.uploaded, "Bad upload")
+
+	r, _ = NewJSONLines(strings.NewReader(`{"id": 3}`), JSONOptions{})
+	err = Import(client, "ds", r)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, "3\n", fake.imported, "Bad import")
+
+	r, _ = NewJSONLines(strings.NewReader(``), JSONOptions{})
+	err = Import(client, "ds", r)
+	assert.Equal(t, true, err != nil, "Bad error code")
+}
+
+func Test_formatTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("west", -8*3600)
+	defer func() { time.Local = local }()
+
+	midnight := time.Unix(18322*86400, 0)
+	assert.Equal(t, "2020-03-01", formatTime(midnight, domo.ColumnDate), "Bad date west of UTC")
+	assert.Equal(t, "2020-03-01T00:00:00Z", formatTime(midnight, domo.ColumnDateTime), "Bad datetime west of UTC")
+}
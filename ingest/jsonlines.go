@@ -0,0 +1,245 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// JSONColumn one column taken from each JSON object
+type JSONColumn struct {
+	Name string // Column name in the schema, defaults to Path
+	Path string // Where the value is, keys and array indexes joined by the separator, such as "customer.address.city"
+	Type string // Domo column type, inferred from the sample when empty
+}
+
+// JSONOptions controls how JSON objects are flattened into records
+type JSONOptions struct {
+	Columns    []JSONColumn // Columns to take, every leaf of the sampled objects when empty
+	Separator  string       // Joins the parts of a path, defaults to "."
+	SampleRows int          // Objects read to infer the schema, defaults to 1000
+}
+
+// JSONLinesReader reads records from newline delimited JSON, one object per record.
+// Nested objects are flattened into columns named by their path. Arrays are kept as JSON text
+// unless a column path indexes into them.
+type JSONLinesReader struct {
+	decoder  *json.Decoder
+	opts     JSONOptions
+	schema   domo.Schema
+	buffered []jsonObject
+	line     int
+}
+
+// jsonObject an object read ahead for the sample, with the number it had in the input
+type jsonObject struct {
+	line   int
+	values map[string]interface{}
+}
+
+// NewJSONLines reads the sample from r and works out the schema.
+func NewJSONLines(r io.Reader, opts JSONOptions) (*JSONLinesReader, error) {
+	if opts.Separator == "" {
+		opts.Separator = "."
+	}
+	if opts.SampleRows < 1 {
+		opts.SampleRows = 1000
+	}
+
+	j := &JSONLinesReader{decoder: json.NewDecoder(r), opts: opts}
+	j.decoder.UseNumber()
+
+	for len(j.buffered) < opts.SampleRows {
+		object, err := j.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		j.buffered = append(j.buffered, jsonObject{line: j.line, values: object})
+	}
+
+	if len(opts.Columns) == 0 {
+		j.opts.Columns = j.leaves()
+	}
+
+	names := make([]string, len(j.opts.Columns))
+	types := make([]string, len(j.opts.Columns))
+	for i, column := range j.opts.Columns {
+		if column.Name == "" {
+			j.opts.Columns[i].Name = column.Path
+		}
+		names[i] = j.opts.Columns[i].Name
+		types[i] = column.Type
+		if types[i] == "" {
+			for _, object := range j.buffered {
+				types[i] = widen(types[i], jsonType(j.lookup(object.values, column.Path)))
+			}
+		}
+	}
+	j.schema = schemaOf(names, types)
+	return j, nil
+}
+
+// Schema the columns every record has
+func (j *JSONLinesReader) Schema() domo.Schema {
+	return j.schema
+}
+
+// Read returns the next record, or io.EOF when there are no more.
+// A value that doesn't fit its column's type, such as text after a sample of numbers, is an error
+// naming the object and column.
+func (j *JSONLinesReader) Read() (record []string, err error) {
+	var object jsonObject
+	if len(j.buffered) > 0 {
+		object = j.buffered[0]
+		j.buffered = j.buffered[1:]
+	} else if object.values, err = j.next(); err != nil {
+		return nil, err
+	} else {
+		object.line = j.line
+	}
+
+	record = make([]string, len(j.opts.Columns))
+	for i, column := range j.opts.Columns {
+		record[i], err = jsonText(j.lookup(object.values, column.Path), j.schema.Columns[i].Type)
+		if err != nil {
+			return nil, fmt.Errorf("Object %d column '%s' : %s", object.line, column.Name, err)
+		}
+	}
+	return
+}
+
+// next decodes the next object
+func (j *JSONLinesReader) next() (object map[string]interface{}, err error) {
+	err = j.decoder.Decode(&object)
+	if err == io.EOF {
+		return nil, err
+	}
+	j.line++
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode object %d %s", j.line, err)
+	}
+	return
+}
+
+// leaves the paths of every value that isn't an object, keys sorted within each object.
+// A key that is only ever null becomes a column, unless it is an object elsewhere.
+func (j *JSONLinesReader) leaves() (columns []JSONColumn) {
+	seen := make(map[string]bool)
+	var nulls []string
+	var walk func(prefix string, object map[string]interface{})
+	walk = func(prefix string, object map[string]interface{}) {
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			path := prefix + key
+			switch child := object[key].(type) {
+			case map[string]interface{}:
+				walk(path+j.opts.Separator, child)
+			case nil:
+				nulls = append(nulls, path)
+			default:
+				if !seen[path] {
+					seen[path] = true
+					columns = append(columns, JSONColumn{Path: path})
+				}
+			}
+		}
+	}
+	for _, object := range j.buffered {
+		walk("", object.values)
+	}
+
+	for _, path := range nulls {
+		nested := false
+		for _, column := range columns {
+			nested = nested || strings.HasPrefix(column.Path, path+j.opts.Separator)
+		}
+		if !seen[path] && !nested {
+			seen[path] = true
+			columns = append(columns, JSONColumn{Path: path})
+		}
+	}
+	return
+}
+
+// lookup follows a path through objects and arrays, nil if it leads nowhere
+func (j *JSONLinesReader) lookup(object map[string]interface{}, path string) interface{} {
+	var value interface{} = object
+	for _, key := range strings.Split(path, j.opts.Separator) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil
+			}
+			value = v[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+// jsonType the Domo type of a JSON value, empty for null
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case json.Number:
+		return numberType(v.String())
+	case string:
+		return textType(v)
+	default:
+		return domo.ColumnString
+	}
+}
+
+// jsonText the CSV text for a JSON value in a column of the given type.
+// A date on its own in a DATETIME column is taken as midnight UTC.
+func jsonText(value interface{}, columnType string) (string, error) {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case json.Number:
+		text = v.String()
+	case bool:
+		text = strconv.FormatBool(v)
+	case string:
+		text = v
+		if columnType == domo.ColumnDate || columnType == domo.ColumnDateTime {
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return formatTime(t, columnType), nil
+			}
+		}
+		if columnType == domo.ColumnDateTime {
+			if _, err := time.Parse("2006-01-02", v); err == nil {
+				return v + "T00:00:00Z", nil
+			}
+		}
+	default:
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(v); err != nil {
+			return "", err
+		}
+		text = strings.TrimSuffix(buf.String(), "\n")
+	}
+	return text, checkText(text, columnType)
+}
@@ -0,0 +1,110 @@
+package ingest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readAll the names and types of the schema, and every record
+func readAll(t *testing.T, r Reader) (columns []string, records [][]string) {
+	for _, column := range r.Schema().Columns {
+		columns = append(columns, column.Name+" "+column.Type)
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return
+		}
+		assert.Equal(t, nil, err, "Bad read")
+		if err != nil {
+			return
+		}
+		records = append(records, record)
+	}
+}
+
+func TestNewJSONLines(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		opts        JSONOptions
+		wantColumns []string
+		wantRecords [][]string
+		wantErr     bool
+		wantReadErr string
+	}{
+		{
+			name: "flattens nested objects and infers types",
+			input: `{"id": 1, "amount": 2, "customer": {"name": "Ann", "since": "2019-04-01"}, "tags": ["a", "b"]}
+{"id": 2, "amount": 2.5, "customer": {"name": "Bob", "since": "2019-04-02T10:30:00Z"}, "ok": true}
+{"id": 3, "customer": null}`,
+			wantColumns: []string{"amount DOUBLE", "customer.name STRING", "customer.since DATETIME", "id LONG", "tags STRING", "ok STRING"},
+			wantRecords: [][]string{
+				{"2", "Ann", "2019-04-01T00:00:00Z", "1", `["a","b"]`, ""},
+				{"2.5", "Bob", "2019-04-02T10:30:00Z", "2", "", "true"},
+				{"", "", "", "3", "", ""},
+			},
+		},
+		{
+			name:  "chosen columns with array indexes",
+			input: `{"order": {"lines": [{"sku": "x1"}, {"sku": "x2"}]}, "total": "12"}`,
+			opts: JSONOptions{Separator: "/", Columns: []JSONColumn{
+				{Name: "first_sku", Path: "order/lines/0/sku"},
+				{Path: "order/lines/5/sku"},
+				{Path: "total", Type: "LONG"},
+			}},
+			wantColumns: []string{"first_sku STRING", "order/lines/5/sku STRING", "total LONG"},
+			wantRecords: [][]string{{"x1", "", "12"}},
+		},
+		{
+			name:        "types come from the sample only",
+			input:       "{\"n\": 1}\n{\"n\": \"many\"}\n",
+			opts:        JSONOptions{SampleRows: 1},
+			wantColumns: []string{"n LONG"},
+			wantRecords: [][]string{{"1"}},
+			wantReadErr: "Object 2 column 'n'",
+		},
+		{
+			name:        "values must fit a chosen type",
+			input:       "{\"n\": 1.5, \"d\": \"2020-01-02\"}\n{\"n\": true, \"d\": \"soon\"}\n",
+			opts:        JSONOptions{Columns: []JSONColumn{{Path: "n", Type: "LONG"}}},
+			wantColumns: []string{"n LONG"},
+			wantReadErr: "Object 1 column 'n'",
+		},
+		{
+			name:    "bad json",
+			input:   `{"id": 1}{"id":`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewJSONLines(strings.NewReader(tt.input), tt.opts)
+			assert.Equal(t, tt.wantErr, err != nil, "Bad error code")
+			if err != nil {
+				return
+			}
+			if tt.wantReadErr != "" {
+				assert.Equal(t, tt.wantColumns[0], r.Schema().Columns[0].Name+" "+r.Schema().Columns[0].Type, "Bad schema")
+				var records [][]string
+				for {
+					record, err := r.Read()
+					if err != nil {
+						assert.Equal(t, true, strings.Contains(fmt.Sprint(err), tt.wantReadErr), "Bad read error")
+						break
+					}
+					records = append(records, record)
+				}
+				assert.Equal(t, tt.wantRecords, records, "Bad records before the error")
+				return
+			}
+			columns, records := readAll(t, r)
+			assert.Equal(t, tt.wantColumns, columns, "Bad schema")
+			assert.Equal(t, tt.wantRecords, records, "Bad records")
+		})
+	}
+}
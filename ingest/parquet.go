@@ -0,0 +1,190 @@
+//go:build parquet
+// +build parquet
+
+package ingest
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// ParquetReader reads the records of a Parquet file, one row group at a time.
+// Only flat schemas are supported: every top level field must be a leaf.
+type ParquetReader struct {
+	file    *parquet.File
+	fields  []parquet.Field
+	schema  domo.Schema
+	group   int
+	rows    parquet.Rows
+	buffer  []parquet.Row
+	pending []parquet.Row
+}
+
+// NewParquet opens the Parquet file in r, size bytes long, and maps its schema onto Domo types.
+func NewParquet(r io.ReaderAt, size int64) (*ParquetReader, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open parquet file %s", err)
+	}
+
+	p := &ParquetReader{file: file, fields: file.Schema().Fields(), buffer: make([]parquet.Row, 100)}
+	names := make([]string, len(p.fields))
+	types := make([]string, len(p.fields))
+	for i, field := range p.fields {
+		if !field.Leaf() {
+			return nil, fmt.Errorf("Column '%s' is nested, only flat schemas are supported", field.Name())
+		}
+		names[i] = field.Name()
+		types[i] = parquetType(field.Type())
+	}
+	p.schema = schemaOf(names, types)
+	return p, nil
+}
+
+// Schema the columns every record has
+func (p *ParquetReader) Schema() domo.Schema {
+	return p.schema
+}
+
+// Read returns the next record, or io.EOF when there are no more
+func (p *ParquetReader) Read() (record []string, err error) {
+	for len(p.pending) == 0 {
+		if err = p.fill(); err != nil {
+			return nil, err
+		}
+	}
+	row := p.pending[0]
+	p.pending = p.pending[1:]
+
+	record = make([]string, len(p.fields))
+	for _, value := range row {
+		column := value.Column()
+		if column < 0 || column >= len(record) || value.IsNull() {
+			continue
+		}
+		if record[column], err = parquetText(value, p.fields[column].Type()); err != nil {
+			return nil, fmt.Errorf("Column '%s' : %s", p.fields[column].Name(), err)
+		}
+	}
+	return record, nil
+}
+
+// fill reads the next batch of rows, moving on to the next row group when one runs out
+func (p *ParquetReader) fill() error {
+	groups := p.file.RowGroups()
+	if p.rows == nil {
+		if p.group >= len(groups) {
+			return io.EOF
+		}
+		p.rows = groups[p.group].Rows()
+		p.group++
+	}
+
+	n, err := p.rows.ReadRows(p.buffer)
+	p.pending = p.buffer[:n]
+	if err == io.EOF {
+		p.rows.Close()
+		p.rows = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to read row group %d %s", p.group-1, err)
+	}
+	return nil
+}
+
+// parquetType the Domo type of a Parquet column
+func parquetType(t parquet.Type) string {
+	if logical := t.LogicalType(); logical != nil {
+		switch {
+		case logical.Date != nil:
+			return domo.ColumnDate
+		case logical.Timestamp != nil:
+			return domo.ColumnDateTime
+		case logical.Decimal != nil:
+			return domo.ColumnDecimal
+		case logical.UTF8 != nil, logical.Enum != nil, logical.Json != nil, logical.UUID != nil:
+			return domo.ColumnString
+		}
+	}
+
+	switch t.Kind() {
+	case parquet.Int32, parquet.Int64:
+		return domo.ColumnLong
+	case parquet.Float, parquet.Double:
+		return domo.ColumnDouble
+	default:
+		return domo.ColumnString
+	}
+}
+
+// parquetText the CSV text for a value of a column of type t
+func parquetText(value parquet.Value, t parquet.Type) (string, error) {
+	if logical := t.LogicalType(); logical != nil {
+		switch {
+		case logical.Date != nil:
+			return formatTime(time.Unix(int64(value.Int32())*86400, 0).UTC(), domo.ColumnDate), nil
+		case logical.Timestamp != nil:
+			return formatTime(timestamp(value.Int64(), logical.Timestamp.Unit), domo.ColumnDateTime), nil
+		case logical.Decimal != nil:
+			return decimal(value, int(logical.Decimal.Scale)), nil
+		}
+	}
+
+	switch value.Kind() {
+	case parquet.Boolean:
+		return strconv.FormatBool(value.Boolean()), nil
+	case parquet.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10), nil
+	case parquet.Int64:
+		return strconv.FormatInt(value.Int64(), 10), nil
+	case parquet.Float:
+		return strconv.FormatFloat(float64(value.Float()), 'g', -1, 32), nil
+	case parquet.Double:
+		return strconv.FormatFloat(value.Double(), 'g', -1, 64), nil
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return string(value.ByteArray()), nil
+	default:
+		return "", fmt.Errorf("unsupported type %s", value.Kind())
+	}
+}
+
+// timestamp converts a Parquet timestamp in the given unit
+func timestamp(v int64, unit format.TimeUnit) time.Time {
+	switch {
+	case unit.Nanos != nil:
+		return time.Unix(0, v)
+	case unit.Micros != nil:
+		return time.Unix(0, v*int64(time.Microsecond))
+	default:
+		return time.Unix(0, v*int64(time.Millisecond))
+	}
+}
+
+// decimal the text of an unscaled decimal, stored as an int or as big endian two's complement bytes
+func decimal(value parquet.Value, scale int) string {
+	unscaled := new(big.Int)
+	switch value.Kind() {
+	case parquet.Int32:
+		unscaled.SetInt64(int64(value.Int32()))
+	case parquet.Int64:
+		unscaled.SetInt64(value.Int64())
+	default:
+		b := value.ByteArray()
+		unscaled.SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+		}
+	}
+	return new(big.Float).SetPrec(256).Quo(
+		new(big.Float).SetInt(unscaled),
+		new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)),
+	).Text('f', scale)
+}
@@ -0,0 +1,66 @@
+//go:build parquet
+// +build parquet
+
+package ingest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// parquetOrder a row of the test file
+type parquetOrder struct {
+	ID      int64     `parquet:"id"`
+	Name    string    `parquet:"name,optional"`
+	Ordered int32     `parquet:"ordered,date"`
+	Shipped time.Time `parquet:"shipped,timestamp"`
+	Amount  float64   `parquet:"amount"`
+}
+
+// parquetFile writes orders to an in-memory Parquet file
+func parquetFile(t *testing.T, orders []parquetOrder) *bytes.Reader {
+	var buf bytes.Buffer
+	w := parquet.NewGenericWriter[parquetOrder](&buf)
+	_, err := w.Write(orders)
+	assert.Equal(t, nil, err, "Bad write")
+	assert.Equal(t, nil, w.Close(), "Bad close")
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestNewParquet(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("west", -8*3600)
+	defer func() { time.Local = local }()
+
+	r := parquetFile(t, []parquetOrder{
+		{ID: 1, Name: "Ann", Ordered: 18322, Shipped: time.Date(2020, 3, 2, 10, 30, 0, 0, time.UTC), Amount: 10},
+		{ID: 2, Ordered: 18323, Shipped: time.Date(2020, 3, 3, 0, 0, 0, 0, time.UTC), Amount: 2.5},
+	})
+	p, err := NewParquet(r, r.Size())
+	assert.Equal(t, nil, err, "Bad error code")
+
+	var columns []string
+	for _, column := range p.Schema().Columns {
+		columns = append(columns, column.Name+" "+column.Type)
+	}
+	assert.Equal(t, []string{"id LONG", "name STRING", "ordered DATE", "shipped DATETIME", "amount DOUBLE"}, columns, "Bad schema")
+
+	var records [][]string
+	for {
+		record, err := p.Read()
+		if err == io.EOF {
+			break
+		}
+		assert.Equal(t, nil, err, "Bad read")
+		records = append(records, record)
+	}
+	assert.Equal(t, [][]string{
+		{"1", "Ann", "2020-03-01", "2020-03-02T10:30:00Z", "10"},
+		{"2", "", "2020-03-02", "2020-03-03T00:00:00Z", "2.5"},
+	}, records, "Bad records")
+}
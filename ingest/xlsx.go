@@ -0,0 +1,345 @@
+package ingest
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// XLSXOptions controls which sheet is read and how
+type XLSXOptions struct {
+	Sheet    string // Name of the sheet, defaults to the first one
+	NoHeader bool   // The first row is data, columns are named after their letters
+}
+
+// XLSXReader reads the records of one sheet of an Excel workbook.
+// Column types come from the cells: numbers become LONG or DOUBLE, cells with
+// a date format become DATE or DATETIME, and anything else is a STRING.
+type XLSXReader struct {
+	schema domo.Schema
+	rows   [][]string
+}
+
+// xlsxCell a cell of a sheet as it is stored
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Style  int    `xml:"s,attr"`
+	Value  string `xml:"v"`
+	Inline struct {
+		Text string `xml:",innerxml"`
+	} `xml:"is"`
+}
+
+// xlsxText the text of a shared or inline string, which may be split into runs
+type xlsxText struct {
+	Text string   `xml:"t"`
+	Runs []string `xml:"r>t"`
+}
+
+func (t xlsxText) String() string {
+	return t.Text + strings.Join(t.Runs, "")
+}
+
+// NewXLSX reads the workbook in r, size bytes long, and works out the schema of the sheet.
+func NewXLSX(r io.ReaderAt, size int64, opts XLSXOptions) (*XLSXReader, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open workbook %s", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheetPath, date1904, err := findSheet(files, opts.Sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if err = readXML(files, "xl/sharedStrings.xml", &shared); err != nil {
+		return nil, err
+	}
+
+	dateStyles, err := readDateStyles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Cells []xlsxCell `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err = readXML(files, sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+	if files[sheetPath] == nil {
+		return nil, fmt.Errorf("Workbook has no sheet %s", sheetPath)
+	}
+
+	// turn every cell into text and a type, keeping cell positions
+	var cells [][]string
+	var cellTypes [][]string
+	width := 0
+	for _, row := range sheet.Rows {
+		var texts, types []string
+		for i, cell := range row.Cells {
+			column := i
+			if cell.Ref != "" {
+				column = columnIndex(cell.Ref)
+			}
+			for len(texts) <= column {
+				texts = append(texts, "")
+				types = append(types, "")
+			}
+			texts[column], types[column], err = cellText(cell, shared.Items, dateStyles, date1904)
+			if err != nil {
+				return nil, fmt.Errorf("Cell %s : %s", cell.Ref, err)
+			}
+		}
+		if len(texts) > width {
+			width = len(texts)
+		}
+		cells = append(cells, texts)
+		cellTypes = append(cellTypes, types)
+	}
+
+	names := make([]string, width)
+	for i := range names {
+		names[i] = columnName(i)
+	}
+	if !opts.NoHeader && len(cells) > 0 {
+		for i, text := range cells[0] {
+			if text != "" {
+				names[i] = text
+			}
+		}
+		cells, cellTypes = cells[1:], cellTypes[1:]
+	}
+
+	types := make([]string, width)
+	for _, row := range cellTypes {
+		for i, cellType := range row {
+			types[i] = widen(types[i], cellType)
+		}
+	}
+
+	x := &XLSXReader{schema: schemaOf(names, types)}
+	for r, row := range cells {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		for i, cellType := range cellTypes[r] {
+			// a date cell in a column that has become DATETIME needs the time too
+			if cellType == domo.ColumnDate && types[i] == domo.ColumnDateTime {
+				row[i] += "T00:00:00Z"
+			}
+		}
+		x.rows = append(x.rows, row)
+	}
+	return x, nil
+}
+
+// Schema the columns every record has
+func (x *XLSXReader) Schema() domo.Schema {
+	return x.schema
+}
+
+// Read returns the next record, or io.EOF when there are no more
+func (x *XLSXReader) Read() (record []string, err error) {
+	if len(x.rows) == 0 {
+		return nil, io.EOF
+	}
+	record, x.rows = x.rows[0], x.rows[1:]
+	return record, nil
+}
+
+// findSheet the path of the named sheet, or the first, and whether the workbook counts days from 1904
+func findSheet(files map[string]*zip.File, name string) (sheetPath string, date1904 bool, err error) {
+	var workbook struct {
+		Properties struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err = readXML(files, "xl/workbook.xml", &workbook); err != nil {
+		return
+	}
+	date1904 = workbook.Properties.Date1904 == "1" || workbook.Properties.Date1904 == "true"
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err = readXML(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return
+	}
+
+	for _, sheet := range workbook.Sheets {
+		if name != "" && sheet.Name != name {
+			continue
+		}
+		for _, rel := range rels.Relationships {
+			if rel.ID == sheet.ID {
+				if strings.HasPrefix(rel.Target, "/") {
+					return strings.TrimPrefix(rel.Target, "/"), date1904, nil
+				}
+				return path.Join("xl", rel.Target), date1904, nil
+			}
+		}
+	}
+
+	if name == "" {
+		return "", false, fmt.Errorf("Workbook has no sheets")
+	}
+	return "", false, fmt.Errorf("Workbook has no sheet named '%s'", name)
+}
+
+// readDateStyles which cell styles show a date
+func readDateStyles(files map[string]*zip.File) (map[int]bool, error) {
+	var styles struct {
+		Formats []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellFormats []struct {
+			FormatID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := readXML(files, "xl/styles.xml", &styles); err != nil {
+		return nil, err
+	}
+
+	dateFormats := make(map[int]bool)
+	for _, id := range []int{14, 15, 16, 17, 18, 19, 20, 21, 22, 45, 46, 47} {
+		dateFormats[id] = true
+	}
+	for _, format := range styles.Formats {
+		dateFormats[format.ID] = isDateFormat(format.Code)
+	}
+
+	dateStyles := make(map[int]bool)
+	for i, cellFormat := range styles.CellFormats {
+		dateStyles[i] = dateFormats[cellFormat.FormatID]
+	}
+	return dateStyles, nil
+}
+
+// isDateFormat whether a number format shows a date or time, ignoring quoted text and [colours]
+func isDateFormat(code string) bool {
+	quoted, bracket := false, false
+	for _, c := range strings.ToLower(code) {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			bracket = true
+		case c == ']':
+			bracket = false
+		case bracket:
+		case c == 'y' || c == 'd' || c == 'h':
+			return true
+		}
+	}
+	return false
+}
+
+// cellText the CSV text and Domo type of a cell
+func cellText(cell xlsxCell, shared []xlsxText, dateStyles map[int]bool, date1904 bool) (text string, cellType string, err error) {
+	switch cell.Type {
+	case "s":
+		index, err := strconv.Atoi(cell.Value)
+		if err != nil || index < 0 || index >= len(shared) {
+			return "", "", fmt.Errorf("bad shared string %s", cell.Value)
+		}
+		text = shared[index].String()
+		return text, domo.ColumnString, nil
+	case "inlineStr":
+		var inline xlsxText
+		if err = xml.Unmarshal([]byte("<is>"+cell.Inline.Text+"</is>"), &inline); err != nil {
+			return
+		}
+		return inline.String(), domo.ColumnString, nil
+	case "b":
+		return strconv.FormatBool(cell.Value == "1"), domo.ColumnString, nil
+	case "str", "e":
+		return cell.Value, domo.ColumnString, nil
+	}
+
+	if cell.Value == "" {
+		return "", "", nil
+	}
+	if !dateStyles[cell.Style] {
+		return cell.Value, numberType(cell.Value), nil
+	}
+
+	serial, err := strconv.ParseFloat(cell.Value, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("bad date %s", cell.Value)
+	}
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 86400)
+	t := epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+	if seconds == 0 {
+		return formatTime(t, domo.ColumnDate), domo.ColumnDate, nil
+	}
+	return formatTime(t, domo.ColumnDateTime), domo.ColumnDateTime, nil
+}
+
+// columnIndex the zero based column of a cell reference such as "AB12"
+func columnIndex(ref string) (index int) {
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		index = index*26 + int(c-'A') + 1
+	}
+	return index - 1
+}
+
+// columnName the letters of a zero based column
+func columnName(index int) (name string) {
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return
+}
+
+// readXML decodes a file of the workbook, leaving v alone if the file isn't there
+func readXML(files map[string]*zip.File, name string, v interface{}) error {
+	file := files[name]
+	if file == nil {
+		return nil
+	}
+	r, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("Unable to open %s %s", name, err)
+	}
+	defer r.Close()
+	if err = xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("Unable to decode %s %s", name, err)
+	}
+	return nil
+}
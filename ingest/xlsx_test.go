@@ -0,0 +1,109 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// workbook zips up the parts of an XLSX file
+func workbook(t *testing.T, files map[string]string) *bytes.Reader {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		assert.Equal(t, nil, err, "Bad zip entry")
+		f.Write([]byte(content))
+	}
+	assert.Equal(t, nil, w.Close(), "Bad zip")
+	return bytes.NewReader(buf.Bytes())
+}
+
+// testWorkbook two sheets, the second holding a header, numbers, dates, shared, rich and inline strings
+func testWorkbook(t *testing.T, date1904 bool) *bytes.Reader {
+	properties := ""
+	if date1904 {
+		properties = `<workbookPr date1904="1"/>`
+	}
+	return workbook(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			properties + `<sheets><sheet name="Notes" sheetId="1" r:id="rId1"/><sheet name="Orders" sheetId="2" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<si><t>id</t></si><si><t>name</t></si><si><t>ordered</t></si><si><t>amount</t></si><si><r><t>Ann </t></r><r><t>Lee</t></r></si></sst>`,
+		"xl/styles.xml": `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<numFmts><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd hh:mm"/><numFmt numFmtId="165" formatCode="&quot;day&quot; 0.00"/></numFmts>` +
+			`<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs></styleSheet>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			`<row r="1"><c r="A1" t="str"><v>hello</v></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c></row>` +
+			`<row r="2"><c r="A2"><v>1</v></c><c r="B2" t="s"><v>4</v></c><c r="C2" s="1"><v>43922</v></c><c r="D2" s="3"><v>10</v></c></row>` +
+			`<row r="3"><c r="A3"><v>2</v></c><c r="B3" t="inlineStr"><is><t>Bob</t></is></c><c r="C3" s="2"><v>43922.75</v></c><c r="D3"><v>2.5</v></c><c r="E3" t="b"><v>1</v></c></row>` +
+			`</sheetData></worksheet>`,
+	})
+}
+
+func TestNewXLSX(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        XLSXOptions
+		date1904    bool
+		wantColumns []string
+		wantRecords [][]string
+		wantErr     bool
+	}{
+		{
+			name:        "first sheet",
+			wantColumns: []string{"hello STRING"},
+		},
+		{
+			name:        "named sheet",
+			opts:        XLSXOptions{Sheet: "Orders"},
+			wantColumns: []string{"id LONG", "name STRING", "ordered DATETIME", "amount DOUBLE", "E STRING"},
+			wantRecords: [][]string{
+				{"1", "Ann Lee", "2020-04-01T00:00:00Z", "10", ""},
+				{"2", "Bob", "2020-04-01T18:00:00Z", "2.5", "true"},
+			},
+		},
+		{
+			name:        "1904 dates without a header",
+			opts:        XLSXOptions{Sheet: "Orders", NoHeader: true},
+			date1904:    true,
+			wantColumns: []string{"A STRING", "B STRING", "C STRING", "D STRING", "E STRING"},
+			wantRecords: [][]string{
+				{"id", "name", "ordered", "amount", ""},
+				{"1", "Ann Lee", "2024-04-02", "10", ""},
+				{"2", "Bob", "2024-04-02T18:00:00Z", "2.5", "true"},
+			},
+		},
+		{
+			name:    "missing sheet",
+			opts:    XLSXOptions{Sheet: "Returns"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := testWorkbook(t, tt.date1904)
+			r, err := NewXLSX(file, file.Size(), tt.opts)
+			assert.Equal(t, tt.wantErr, err != nil, "Bad error code")
+			if err != nil {
+				return
+			}
+			columns, records := readAll(t, r)
+			assert.Equal(t, tt.wantColumns, columns, "Bad schema")
+			assert.Equal(t, tt.wantRecords, records, "Bad records")
+		})
+	}
+}
+
+func Test_columnName(t *testing.T) {
+	for index, name := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		assert.Equal(t, name, columnName(index), "Bad name")
+		assert.Equal(t, index, columnIndex(name+"12"), "Bad index")
+	}
+}
@@ -0,0 +1,116 @@
+package domo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// RequestIDHeader carries the id RequestID gives each request
+const RequestIDHeader = "X-Request-Id"
+
+// Interceptor wraps the Doer that sends a request, so it can change the request,
+// look at the response or stand in for the Doer altogether.
+type Interceptor func(next Doer) Doer
+
+// DoerFunc lets an ordinary function be used as a Doer
+type DoerFunc func(*http.Request) (*http.Response, error)
+
+// Do calls f(req)
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// AuditRecord a request that changed something in Domo
+type AuditRecord struct {
+	Time       time.Time     // When the request was sent
+	Method     string        // POST, PUT, PATCH or DELETE
+	URL        string        // Address of the request, without its query
+	StatusCode int           // Status of the response, 0 if there wasn't one
+	RequestID  string        // The X-Request-Id header, if RequestID set one
+	Duration   time.Duration // Time taken to get the response
+	Err        error         // Error from sending the request
+}
+
+// Use adds interceptors around every request made through the Client, including token requests.
+// The first interceptor given is the outermost, so it sees the request first and the response last.
+// Interceptors should be added before the Client is shared between goroutines.
+func (d *Client) Use(interceptors ...Interceptor) {
+	d.interceptors = append(d.interceptors, interceptors...)
+}
+
+// doer the Doer with every interceptor wrapped around it
+func (d *Client) doer() Doer {
+	doer := d.myDoer
+	for i := len(d.interceptors) - 1; i >= 0; i-- {
+		doer = d.interceptors[i](doer)
+	}
+	return doer
+}
+
+// SetHeaders sets the headers on every request, replacing any the Client set
+func SetHeaders(headers map[string]string) Interceptor {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			for key, value := range headers {
+				req.Header.Set(key, value)
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// RequestID gives every request a random X-Request-Id header, unless it already has one
+func RequestID() Interceptor {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(RequestIDHeader) == "" {
+				id := make([]byte, 16)
+				rand.Read(id)
+				req.Header.Set(RequestIDHeader, hex.EncodeToString(id))
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// Timing reports how long each request took to get its response
+func Timing(report func(req *http.Request, resp *http.Response, elapsed time.Duration, err error)) Interceptor {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.Do(req)
+			report(req, resp, time.Since(start), err)
+			return resp, err
+		})
+	}
+}
+
+// Audit reports every request that can change something in Domo, that is every
+// request that isn't a GET, HEAD or OPTIONS.
+func Audit(record func(AuditRecord)) Interceptor {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			switch req.Method {
+			case "GET", "HEAD", "OPTIONS":
+				return next.Do(req)
+			}
+
+			audit := AuditRecord{
+				Time:      time.Now(),
+				Method:    req.Method,
+				URL:       req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+				RequestID: req.Header.Get(RequestIDHeader),
+			}
+			resp, err := next.Do(req)
+			audit.Duration = time.Since(audit.Time)
+			audit.Err = err
+			if resp != nil {
+				audit.StatusCode = resp.StatusCode
+			}
+			record(audit)
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package domo
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Use(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"PUT /v1/groups/5": {responseCode: 200, response: `{}`},
+		"GET /v1/groups/5": {responseCode: 200, response: `{"id": 5, "name": "Sales"}`},
+	}}
+	d := CreateTestClient(doer)
+
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+" "+req.Method)
+				return next.Do(req)
+			})
+		}
+	}
+
+	var seen http.Header
+	var timed []string
+	var audited []AuditRecord
+	d.Use(
+		trace("outer"),
+		SetHeaders(map[string]string{"Proxy-Authorization": "Basic cHJveHk6cGFzcw=="}),
+		RequestID(),
+		Timing(func(req *http.Request, resp *http.Response, elapsed time.Duration, err error) {
+			timed = append(timed, req.Method+" "+req.URL.Path)
+		}),
+		Audit(func(record AuditRecord) { audited = append(audited, record) }),
+		trace("inner"),
+		func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				seen = req.Header.Clone()
+				return next.Do(req)
+			})
+		},
+	)
+
+	_, err := d.Group.Retrieve(5)
+	assert.Equal(t, nil, err, "Bad error code")
+	_, err = d.Group.Update(context.Background(), 5, GroupPatch{Name: String("Sales"), Active: Bool(true)})
+	assert.Equal(t, nil, err, "Bad error code")
+
+	assert.Equal(t, []string{"outer GET", "inner GET", "outer GET", "inner GET", "outer PUT", "inner PUT"}, order, "Bad order")
+	assert.Equal(t, []string{"GET /oauth/token", "GET /v1/groups/5", "PUT /v1/groups/5"}, timed, "Bad timing")
+	assert.Equal(t, "Basic cHJveHk6cGFzcw==", seen.Get("Proxy-Authorization"), "Bad header")
+	assert.Equal(t, "bearer token", seen.Get("Authorization"), "Bad authorization")
+	assert.Equal(t, 32, len(seen.Get(RequestIDHeader)), "Bad request id")
+
+	assert.Equal(t, 1, len(audited), "Bad audit count")
+	assert.Equal(t, "PUT", audited[0].Method, "Bad audit method")
+	assert.Equal(t, true, strings.HasSuffix(audited[0].URL, "/v1/groups/5"), "Bad audit url")
+	assert.Equal(t, 200, audited[0].StatusCode, "Bad audit status")
+	assert.Equal(t, seen.Get(RequestIDHeader), audited[0].RequestID, "Bad audit request id")
+}
+
+func TestRequestID(t *testing.T) {
+	var got string
+	doer := RequestID()(DoerFunc(func(req *http.Request) (*http.Response, error) {
+		got = req.Header.Get(RequestIDHeader)
+		return nil, nil
+	}))
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	req.Header.Set(RequestIDHeader, "mine")
+	doer.Do(req)
+	assert.Equal(t, "mine", got, "Bad request id kept")
+
+	req, _ = http.NewRequest("GET", "http://example.com", nil)
+	doer.Do(req)
+	first := got
+	req, _ = http.NewRequest("GET", "http://example.com", nil)
+	doer.Do(req)
+	assert.NotEqual(t, first, got, "Bad request ids repeat")
+}
@@ -0,0 +1,86 @@
+package domo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrNotFound is matched, with errors.Is, by every error a lookup returns when nothing matches
+var ErrNotFound = errors.New("not found")
+
+// ErrAmbiguous is matched, with errors.Is, by every error a lookup returns when several things match
+var ErrAmbiguous = errors.New("ambiguous")
+
+// NotFoundError nothing matched a lookup
+type NotFoundError struct {
+	Kind  string // What was looked for, such as user
+	Field string // What it was looked up by, such as email
+	Value string // The value looked for
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("No %s with %s %s", e.Kind, e.Field, e.Value)
+}
+
+// Is makes errors.Is(err, ErrNotFound) true
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// Candidate one of the things that matched an ambiguous lookup
+type Candidate struct {
+	ID   int
+	Name string
+}
+
+// AmbiguousError more than one thing matched a lookup that should find one
+type AmbiguousError struct {
+	Kind       string // What was looked for, such as group
+	Field      string // What it was looked up by, such as name
+	Value      string // The value looked for
+	Candidates []Candidate
+}
+
+func (e *AmbiguousError) Error() string {
+	var candidates []string
+	for _, c := range e.Candidates {
+		candidates = append(candidates, fmt.Sprintf("%s (%d)", c.Name, c.ID))
+	}
+	return fmt.Sprintf("%d %ss have %s %s: %s", len(e.Candidates), e.Kind, e.Field, e.Value, strings.Join(candidates, ", "))
+}
+
+// Is makes errors.Is(err, ErrAmbiguous) true
+func (e *AmbiguousError) Is(target error) bool {
+	return target == ErrAmbiguous
+}
+
+// lookupOne the id of the only candidate, or the error saying why there isn't one
+func lookupOne(kind string, field string, value string, candidates []Candidate) (int, error) {
+	switch len(candidates) {
+	case 0:
+		return 0, &NotFoundError{Kind: kind, Field: field, Value: value}
+	case 1:
+		return candidates[0].ID, nil
+	}
+	return 0, &AmbiguousError{Kind: kind, Field: field, Value: value, Candidates: candidates}
+}
+
+// matcher matches names against a search pattern, ignoring case. A pattern with * (any run of
+// characters) or ? (any one character) must match the whole name; any other pattern is a prefix.
+func matcher(pattern string) func(name string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if !strings.ContainsAny(pattern, "*?") {
+		return func(name string) bool {
+			return strings.HasPrefix(strings.ToLower(name), pattern)
+		}
+	}
+
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+	re := regexp.MustCompile("^" + expr + "$")
+	return func(name string) bool {
+		return re.MatchString(strings.ToLower(name))
+	}
+}
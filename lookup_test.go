@@ -0,0 +1,86 @@
+package domo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lookupTestDoer() *routeDoer {
+	return &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"GET /v1/groups": {responseCode: 200, response: `[
+			{"id": 1, "name": "Sales"},
+			{"id": 2, "name": "sales "},
+			{"id": 3, "name": "Sales EMEA"},
+			{"id": 4, "name": "Finance"}
+		]`},
+		"GET /v1/users": {responseCode: 200, response: `[
+			{"id": 7, "name": "Ann Lee", "email": "ann@example.com"},
+			{"id": 8, "name": "Ann Lee", "email": "ann.lee@example.com"},
+			{"id": 9, "name": "Bob Ray", "email": "bob@example.com"}
+		]`},
+	}}
+}
+
+func TestGroupService_Find_lookup(t *testing.T) {
+	client := CreateTestClient(lookupTestDoer())
+
+	id, err := client.Group.Find("finance")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 4, id, "Bad case insensitive find")
+
+	_, err = client.Group.Find("Marketing")
+	assert.Equal(t, true, errors.Is(err, ErrNotFound), "Bad not found")
+	assert.Equal(t, "No group with name Marketing", err.Error(), "Bad not found message")
+
+	_, err = client.Group.Find("SALES")
+	assert.Equal(t, true, errors.Is(err, ErrAmbiguous), "Bad ambiguous")
+	var ambiguous *AmbiguousError
+	assert.Equal(t, true, errors.As(err, &ambiguous), "Bad error type")
+	assert.Equal(t, []Candidate{{1, "Sales"}, {2, "sales "}}, ambiguous.Candidates, "Bad candidates")
+}
+
+func TestGroupService_Search(t *testing.T) {
+	client := CreateTestClient(lookupTestDoer())
+
+	tests := []struct {
+		pattern string
+		want    []int
+	}{
+		{"sal", []int{1, 2, 3}},
+		{"*emea", []int{3}},
+		{"?inance", []int{4}},
+		{"s*s", []int{1}},
+		{"Marketing", nil},
+	}
+	for _, tt := range tests {
+		groups, err := client.Group.Search(context.Background(), tt.pattern)
+		assert.Equal(t, nil, err, "Bad error code")
+		var ids []int
+		for _, group := range groups {
+			ids = append(ids, group.ID)
+		}
+		assert.Equal(t, tt.want, ids, "Bad search for "+tt.pattern)
+	}
+}
+
+func TestUserService_Find_lookup(t *testing.T) {
+	client := CreateTestClient(lookupTestDoer())
+
+	id, err := client.User.Find("BOB RAY")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 9, id, "Bad case insensitive find")
+
+	_, err = client.User.Find("Ann Lee")
+	assert.Equal(t, true, errors.Is(err, ErrAmbiguous), "Bad ambiguous")
+	assert.Equal(t, "2 users have name Ann Lee: ann@example.com (7), ann.lee@example.com (8)", err.Error(), "Bad ambiguous message")
+
+	users, err := client.User.Search(context.Background(), "ann*")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 2, len(users), "Bad search")
+	users, _ = client.User.Search(context.Background(), "*@example.com")
+	assert.Equal(t, 3, len(users), "Bad email search")
+}
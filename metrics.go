@@ -0,0 +1,43 @@
+package domo
+
+import (
+	"fmt"
+	"time"
+)
+
+// Metrics is told about every request, token refresh and stream upload a Client makes,
+// so they can be counted. The prometheus package implements it.
+type Metrics interface {
+	// ObserveRequest a request to service finished with statusCode, which is 0 if no response came back
+	ObserveRequest(service string, method string, statusCode int, elapsed time.Duration)
+	// ObserveTokenRefresh a new access token for scope was asked for, err says whether it failed
+	ObserveTokenRefresh(scope string, err error)
+	// ObserveRetry a part upload to the stream is being tried again
+	ObserveRetry(streamID int)
+	// ObservePart a part with rows rows in bytes bytes was accepted by the stream
+	ObservePart(streamID int, rows int, bytes int)
+	// ObserveCommit an execution of the stream was committed
+	ObserveCommit(streamID int)
+}
+
+// SetMetrics reports every request, token refresh and upload to metrics
+//
+// Metrics are off by default
+func (d *Client) SetMetrics(metrics Metrics) {
+	d.metrics = metrics
+}
+
+// observe hands the metrics to report, when there are any
+func (d *Client) observe(report func(Metrics)) {
+	if d.metrics != nil {
+		report(d.metrics)
+	}
+}
+
+// StatusClass groups a status code as 2xx, 4xx and so on, or "error" when there was no response
+func StatusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
@@ -0,0 +1,96 @@
+package domo
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testMetrics counts what it is told
+type testMetrics struct {
+	sync.Mutex
+	requests  []string
+	refreshes []string
+	retries   int
+	parts     int
+	rows      int
+	bytes     int
+	commits   int
+}
+
+func (m *testMetrics) ObserveRequest(service string, method string, statusCode int, elapsed time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.requests = append(m.requests, service+" "+method+" "+StatusClass(statusCode))
+}
+
+func (m *testMetrics) ObserveTokenRefresh(scope string, err error) {
+	m.Lock()
+	defer m.Unlock()
+	result := "ok"
+	if err != nil {
+		result = "failed"
+	}
+	m.refreshes = append(m.refreshes, scope+" "+result)
+}
+
+func (m *testMetrics) ObserveRetry(streamID int) {
+	m.Lock()
+	defer m.Unlock()
+	m.retries++
+}
+
+func (m *testMetrics) ObservePart(streamID int, rows int, bytes int) {
+	m.Lock()
+	defer m.Unlock()
+	m.parts++
+	m.rows += rows
+	m.bytes += bytes
+}
+
+func (m *testMetrics) ObserveCommit(streamID int) {
+	m.Lock()
+	defer m.Unlock()
+	m.commits++
+}
+
+func TestClient_SetMetrics(t *testing.T) {
+	doer := uploadTestDoer()
+	doer.failures = map[string]int{"PUT /v1/streams/7/executions/4/part/2": 1}
+	d := CreateTestClient(doer)
+	metrics := &testMetrics{}
+	d.SetMetrics(metrics)
+
+	_, err := d.Stream.UploadParts(7, []string{"a,1\n", "b,2\nc,3\n"}, UploadOptions{Retries: 1, RetryWait: time.Millisecond})
+	assert.Equal(t, nil, err, "Bad error code")
+
+	assert.Equal(t, []string{"data ok"}, metrics.refreshes, "Bad refreshes")
+	assert.Equal(t, "oauth GET 2xx", metrics.requests[0], "Bad token request")
+	assert.Equal(t, "streams POST 2xx", metrics.requests[1], "Bad execution request")
+	assert.Equal(t, 6, len(metrics.requests), "Bad request count")
+	assert.Equal(t, 1, metrics.retries, "Bad retries")
+	assert.Equal(t, 2, metrics.parts, "Bad parts")
+	assert.Equal(t, 3, metrics.rows, "Bad rows")
+	assert.Equal(t, 12, metrics.bytes, "Bad bytes")
+	assert.Equal(t, 1, metrics.commits, "Bad commits")
+}
+
+func TestClient_SetMetrics_refreshFailure(t *testing.T) {
+	d := CreateTestClient(testDoer{responseCode: 401, response: `{}`})
+	metrics := &testMetrics{}
+	d.SetMetrics(metrics)
+
+	err := d.getAccessToken("user")
+	assert.Equal(t, errors.New("Unauthorized"), err, "Bad error code")
+	assert.Equal(t, []string{"user failed"}, metrics.refreshes, "Bad refreshes")
+	assert.Equal(t, []string{"oauth GET 4xx"}, metrics.requests, "Bad requests")
+}
+
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, "2xx", StatusClass(204), "Bad 204")
+	assert.Equal(t, "5xx", StatusClass(503), "Bad 503")
+	assert.Equal(t, "error", StatusClass(0), "Bad 0")
+}
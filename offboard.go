@@ -0,0 +1,262 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// OffboardOptions controls an Offboard
+type OffboardOptions struct {
+	SuccessorID int  // The user who takes over the datasets, streams and pages, required if there are any
+	DryRun      bool // Only plan, changing nothing
+	KeepUser    bool // Hand over the content and leave the groups, but don't delete the user
+}
+
+// The actions of an offboarding
+const (
+	OffboardReassign = "reassign"
+	OffboardLeave    = "leave"
+	OffboardDelete   = "delete"
+)
+
+// OffboardStep one change an offboarding makes
+type OffboardStep struct {
+	Action string `json:"action"`          // reassign, leave or delete
+	Kind   string `json:"kind"`            // dataset, stream, page, group or user
+	ID     string `json:"id"`              // The id of what is changed
+	Name   string `json:"name"`            // The name of what is changed
+	Via    string `json:"via,omitempty"`   // The DataSet a stream moves with
+	Done   bool   `json:"done"`            // Whether the change was made
+	Error  string `json:"error,omitempty"` // Why the change failed
+}
+
+func (s OffboardStep) String() string {
+	switch s.Action {
+	case OffboardLeave:
+		return fmt.Sprintf("leave group %s (%s)", s.Name, s.ID)
+	case OffboardDelete:
+		return fmt.Sprintf("delete user %s (%s)", s.Name, s.ID)
+	}
+	return fmt.Sprintf("reassign %s %s (%s)", s.Kind, s.Name, s.ID)
+}
+
+// OffboardRecord the audit record of an offboarding: whose, what was planned and what was done.
+// It is meant to be kept, and marshals to JSON.
+type OffboardRecord struct {
+	UserID      int            `json:"userId"`
+	Email       string         `json:"email"`
+	Name        string         `json:"name"`
+	SuccessorID int            `json:"successorId,omitempty"`
+	DryRun      bool           `json:"dryRun"`
+	StartedAt   time.Time      `json:"startedAt"`
+	FinishedAt  time.Time      `json:"finishedAt"`
+	Steps       []OffboardStep `json:"steps"`
+	Deleted     bool           `json:"deleted"`
+}
+
+// Failed the number of steps that failed
+func (r *OffboardRecord) Failed() (failed int) {
+	for _, step := range r.Steps {
+		if step.Error != "" {
+			failed++
+		}
+	}
+	return
+}
+
+// Print writes the steps, one per line, marking the ones that failed
+func (r *OffboardRecord) Print(w io.Writer) {
+	prefix := ""
+	if r.DryRun {
+		prefix = "[dry run] "
+	}
+	for _, step := range r.Steps {
+		if step.Error != "" {
+			fmt.Fprintf(w, "%s%s failed: %s\n", prefix, step, step.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s%s\n", prefix, step)
+	}
+}
+
+// Offboard Safely removes a user: the datasets, streams and pages they own are reassigned to
+// a successor, they are removed from all their groups, and only then are they deleted.
+// A user is never deleted if anything they own could not be handed over.
+// Returns
+// Returns the audit record, which for a dry run is the plan.
+func (u *UserService) Offboard(ctx context.Context, userID int, opts OffboardOptions) (record OffboardRecord, err error) {
+	record = OffboardRecord{UserID: userID, SuccessorID: opts.SuccessorID, DryRun: opts.DryRun, StartedAt: time.Now().UTC()}
+	defer func() { record.FinishedAt = time.Now().UTC() }()
+
+	user, err := u.Retrieve(userID)
+	if err == nil && user.ID != userID {
+		err = &NotFoundError{Kind: "user", Field: "id", Value: strconv.Itoa(userID)}
+	}
+	if err != nil {
+		return
+	}
+	record.Email, record.Name = user.Email, user.Name
+
+	if opts.SuccessorID == userID {
+		err = errors.New("A user can't be their own successor")
+		return
+	}
+	if opts.SuccessorID != 0 {
+		successor, err := u.Retrieve(opts.SuccessorID)
+		if err == nil && successor.ID != opts.SuccessorID {
+			err = &NotFoundError{Kind: "user", Field: "id", Value: strconv.Itoa(opts.SuccessorID)}
+		}
+		if err != nil {
+			return record, fmt.Errorf("Unable to find successor %d %s", opts.SuccessorID, err)
+		}
+	}
+
+	record.Steps, err = u.offboardPlan(ctx, user, opts)
+	if err != nil {
+		return
+	}
+	owned := 0
+	for _, step := range record.Steps {
+		if step.Action == OffboardReassign {
+			owned++
+		}
+	}
+	if owned > 0 && opts.SuccessorID == 0 {
+		err = fmt.Errorf("User %d owns %d datasets, streams and pages, and needs a successor", userID, owned)
+		return
+	}
+	if opts.DryRun {
+		return
+	}
+
+	u.offboardApply(ctx, &record)
+	if failed := record.Failed(); failed > 0 {
+		err = fmt.Errorf("%d of %d offboarding steps failed", failed, len(record.Steps))
+	}
+	return
+}
+
+// offboardPlan the steps that offboard a user, in the order they are made
+func (u *UserService) offboardPlan(ctx context.Context, user User, opts OffboardOptions) (steps []OffboardStep, err error) {
+	datasets, err := u.client.ownedDataSets(ctx, user.ID)
+	if err != nil {
+		return
+	}
+	planned := make(map[string]bool)
+	for _, ds := range datasets {
+		planned[ds.ID] = true
+		steps = append(steps, OffboardStep{Action: OffboardReassign, Kind: "dataset", ID: ds.ID, Name: ds.Name})
+	}
+
+	streams, err := u.client.Stream.ListOwned(ctx, user.ID)
+	if err != nil {
+		return
+	}
+	for _, stream := range streams {
+		if !planned[stream.DataSet.ID] {
+			planned[stream.DataSet.ID] = true
+			steps = append(steps, OffboardStep{Action: OffboardReassign, Kind: "dataset", ID: stream.DataSet.ID, Name: stream.DataSet.Name})
+		}
+		steps = append(steps, OffboardStep{Action: OffboardReassign, Kind: "stream", ID: strconv.Itoa(stream.ID), Name: stream.DataSet.Name, Via: stream.DataSet.ID})
+	}
+
+	pages, err := u.client.ownedPages(ctx, user.ID)
+	if err != nil {
+		return
+	}
+	for _, page := range pages {
+		steps = append(steps, OffboardStep{Action: OffboardReassign, Kind: "page", ID: strconv.Itoa(page.ID), Name: page.Name})
+	}
+
+	for _, group := range user.Groups {
+		steps = append(steps, OffboardStep{Action: OffboardLeave, Kind: "group", ID: strconv.Itoa(group.ID), Name: group.Name})
+	}
+	if !opts.KeepUser {
+		steps = append(steps, OffboardStep{Action: OffboardDelete, Kind: "user", ID: strconv.Itoa(user.ID), Name: user.Email})
+	}
+	return
+}
+
+// offboardApply makes the steps in order, deleting the user only if every other step worked
+func (u *UserService) offboardApply(ctx context.Context, record *OffboardRecord) {
+	moved := make(map[string]bool)
+	for i := range record.Steps {
+		step := &record.Steps[i]
+		err := ctx.Err()
+		switch {
+		case err != nil:
+		case step.Kind == "dataset":
+			err = u.client.reassignDataSet(ctx, step.ID, record.SuccessorID)
+			moved[step.ID] = err == nil
+		case step.Kind == "stream" && !moved[step.Via]:
+			err = fmt.Errorf("dataset %s was not reassigned", step.Via)
+		case step.Kind == "page":
+			id, _ := strconv.Atoi(step.ID)
+			_, err = u.client.Page.Update(ctx, id, PagePatch{OwnerID: Int(record.SuccessorID)})
+		case step.Kind == "group":
+			id, _ := strconv.Atoi(step.ID)
+			err = u.client.Group.RemoveUsers(ctx, id, []int{record.UserID})
+		case step.Kind == "user" && record.Failed() > 0:
+			err = errors.New("not deleted, as an earlier step failed")
+		case step.Kind == "user":
+			err = u.Delete(record.UserID)
+			record.Deleted = err == nil
+		}
+		step.Done = err == nil
+		if err != nil {
+			step.Error = err.Error()
+		}
+	}
+}
+
+// ownedDataSets the DataSets a user owns, reading every page of the list
+func (d *Client) ownedDataSets(ctx context.Context, ownerID int) (owned Datasets, err error) {
+	const limit = 50
+	for offset := 0; ; offset += limit {
+		url := fmt.Sprintf("%s/v1/datasets?limit=%d&offset=%d", d.baseURL, limit, offset)
+		bodyBytes, err := d.sendJSON(ctx, "data", "GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list datasets : %s", err)
+		}
+
+		var list Datasets
+		if err = json.Unmarshal(bodyBytes, &list); err != nil {
+			return nil, fmt.Errorf("Failed to get unmarshal list %s", err)
+		}
+		for _, ds := range list {
+			if ds.Owner.ID == ownerID {
+				owned = append(owned, ds)
+			}
+		}
+		if len(list) < limit {
+			return owned, nil
+		}
+	}
+}
+
+// ownedPages the pages a user owns, from the whole page tree
+func (d *Client) ownedPages(ctx context.Context, ownerID int) (owned []Page, err error) {
+	tree, err := d.Page.Tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tree.Walk(func(node *PageNode) error {
+		if node.OwnerID == ownerID {
+			owned = append(owned, node.Page)
+		}
+		return nil
+	})
+	return owned, nil
+}
+
+// reassignDataSet makes a user the owner of a DataSet, and so of its stream
+func (d *Client) reassignDataSet(ctx context.Context, datasetID string, ownerID int) error {
+	url := fmt.Sprintf("%s/v1/datasets/%s", d.baseURL, datasetID)
+	_, err := d.sendJSON(ctx, "data", "PUT", url, map[string]interface{}{"owner": map[string]int{"id": ownerID}})
+	return err
+}
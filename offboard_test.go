@@ -0,0 +1,96 @@
+package domo
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// offboardTestDoer Ann (7) owns a dataset, a stream and a page and is in Sales, Bob (8) owns the rest
+func offboardTestDoer() *routeDoer {
+	return &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"GET /v1/users/7":  {responseCode: 200, response: `{"id": 7, "name": "Ann", "email": "ann@example.com", "groups": [{"id": 10, "name": "Sales"}]}`},
+		"GET /v1/users/8":  {responseCode: 200, response: `{"id": 8, "name": "Bob", "email": "bob@example.com"}`},
+		"GET /v1/datasets": {responseCode: 200, response: `[
+			{"id": "ds-1", "name": "Orders", "owner": {"id": 7, "name": "Ann"}},
+			{"id": "ds-2", "name": "Returns", "owner": {"id": 8, "name": "Bob"}}
+		]`},
+		"GET /v1/streams/search":     {responseCode: 200, response: `[{"id": 5, "dataSet": {"id": "ds-3", "name": "Events", "owner": {"id": 7, "name": "Ann"}}}]`},
+		"GET /v1/pages":              {responseCode: 200, response: `[{"id": 100, "name": "Home", "children": [{"id": 101, "name": "Pipeline"}]}]`},
+		"GET /v1/pages/100":          {responseCode: 200, response: `{"id": 100, "name": "Home", "ownerId": 8, "children": [{"id": 101, "name": "Pipeline"}]}`},
+		"GET /v1/pages/101":          {responseCode: 200, response: `{"id": 101, "name": "Pipeline", "ownerId": 7, "children": []}`},
+		"PUT /v1/datasets/ds-1":      {responseCode: 200, response: `{}`},
+		"PUT /v1/datasets/ds-3":      {responseCode: 200, response: `{}`},
+		"PUT /v1/pages/101":          {responseCode: 200, response: `{}`},
+		"DELETE /v1/groups/10/users": {responseCode: 204},
+		"DELETE /v1/users/7":         {responseCode: 204},
+	}}
+}
+
+func TestOffboard(t *testing.T) {
+	doer := offboardTestDoer()
+	client := CreateTestClient(doer)
+
+	record, err := client.User.Offboard(context.Background(), 7, OffboardOptions{SuccessorID: 8, DryRun: true})
+	assert.Equal(t, nil, err, "Bad error code")
+	var steps []string
+	for _, step := range record.Steps {
+		steps = append(steps, step.String())
+	}
+	assert.Equal(t, []string{
+		"reassign dataset Orders (ds-1)",
+		"reassign dataset Events (ds-3)",
+		"reassign stream Events (5)",
+		"reassign page Pipeline (101)",
+		"leave group Sales (10)",
+		"delete user ann@example.com (7)",
+	}, steps, "Bad plan")
+	assert.Equal(t, "ann@example.com", record.Email, "Bad audit email")
+	assert.Equal(t, 0, doer.count("PUT *")+doer.count("DELETE *"), "Dry run changed something")
+
+	var out bytes.Buffer
+	record.Print(&out)
+	assert.Equal(t, true, strings.HasPrefix(out.String(), "[dry run] reassign dataset Orders (ds-1)\n"), "Bad dry run output")
+
+	record, err = client.User.Offboard(context.Background(), 7, OffboardOptions{SuccessorID: 8})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, true, record.Deleted, "User not deleted")
+	assert.Equal(t, 0, record.Failed(), "Bad failures")
+	assert.Equal(t, `{"owner":{"id":8}}`, doer.bodies["PUT /v1/datasets/ds-1"], "Bad dataset reassignment")
+	assert.Equal(t, `{"ownerId":8}`, doer.bodies["PUT /v1/pages/101"], "Bad page reassignment")
+	assert.Equal(t, 0, doer.count("PUT /v1/pages/100"), "Reassigned someone else's page")
+	assert.Equal(t, 1, doer.count("DELETE /v1/groups/10/users"), "Not removed from group")
+	assert.Equal(t, "[7]", doer.bodies["DELETE /v1/groups/10/users"], "Bad group removal")
+	assert.Equal(t, "DELETE /v1/users/7", doer.requests[len(doer.requests)-1], "User not deleted last")
+}
+
+func TestOffboard_failure(t *testing.T) {
+	doer := offboardTestDoer()
+	doer.routes["PUT /v1/datasets/ds-3"] = testDoer{responseCode: 403, response: `{"status":403,"statusReason":"Forbidden","toe":"TEST"}`}
+	client := CreateTestClient(doer)
+
+	record, err := client.User.Offboard(context.Background(), 7, OffboardOptions{SuccessorID: 8})
+	assert.NotEqual(t, nil, err, "Failure not reported")
+	assert.Equal(t, 3, record.Failed(), "Bad failures")
+	assert.Equal(t, false, record.Steps[2].Done, "Stream moved without its dataset")
+	assert.Equal(t, true, record.Steps[3].Done, "Page not reassigned")
+	assert.Equal(t, false, record.Deleted, "Deleted with content left behind")
+	assert.Equal(t, 0, doer.count("DELETE /v1/users/7"), "Deleted with content left behind")
+}
+
+func TestOffboard_successor(t *testing.T) {
+	doer := offboardTestDoer()
+	client := CreateTestClient(doer)
+
+	_, err := client.User.Offboard(context.Background(), 7, OffboardOptions{})
+	assert.NotEqual(t, nil, err, "Content orphaned")
+	_, err = client.User.Offboard(context.Background(), 7, OffboardOptions{SuccessorID: 7})
+	assert.NotEqual(t, nil, err, "Own successor accepted")
+	_, err = client.User.Offboard(context.Background(), 7, OffboardOptions{SuccessorID: 9})
+	assert.NotEqual(t, nil, err, "Unknown successor accepted")
+	assert.Equal(t, 0, doer.count("PUT *")+doer.count("DELETE *"), "Changed something")
+}
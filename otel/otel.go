@@ -0,0 +1,88 @@
+// Package otel reports the requests and uploads a domo.Client makes as OpenTelemetry spans.
+//
+//	client.SetTracer(otel.NewTracer(otel.GetTracerProvider()))
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	domo "github.com/davecb/domoStreamApi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer spans are created by
+const instrumentationName = "github.com/davecb/domoStreamApi"
+
+// Tracer a domo.Tracer that starts OpenTelemetry spans
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates spans with a tracer from provider, or from the global provider when it is nil
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: provider.Tracer(instrumentationName)}
+}
+
+// GetTracerProvider the global TracerProvider, so callers needn't import otel as well
+func GetTracerProvider() trace.TracerProvider {
+	return otel.GetTracerProvider()
+}
+
+// Start begins a client span as a child of any span in ctx
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...domo.Attribute) (context.Context, domo.Span) {
+	ctx, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(convert(attrs)...),
+	)
+	return ctx, &Span{span: span}
+}
+
+// Span a domo.Span backed by an OpenTelemetry span
+type Span struct {
+	span trace.Span
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...domo.Attribute) {
+	s.span.SetAttributes(convert(attrs)...)
+}
+
+// RecordError records err on the span and marks it failed
+func (s *Span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End ends the span
+func (s *Span) End() {
+	s.span.End()
+}
+
+// convert turns domo attributes into OpenTelemetry ones, formatting any other type of value as a string
+func convert(attrs []domo.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		case float64:
+			kvs = append(kvs, attribute.Float64(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,100 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attrs the attributes of a span by key
+func attrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := NewTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	_, span := tracer.Start(context.Background(), "domo GET",
+		domo.Attribute{Key: domo.AttrObjectID, Value: "5"},
+		domo.Attribute{Key: domo.AttrStatusCode, Value: 404},
+		domo.Attribute{Key: domo.AttrBytes, Value: int64(10)},
+		domo.Attribute{Key: "domo.test", Value: []string{"a"}},
+	)
+	span.SetAttributes(domo.Attribute{Key: domo.AttrToe, Value: "ABC"})
+	span.RecordError(errors.New("404 Not Found"))
+	span.End()
+
+	spans := recorder.Ended()
+	assert.Equal(t, 1, len(spans), "Bad span count")
+	got := attrs(spans[0])
+	assert.Equal(t, "domo GET", spans[0].Name(), "Bad name")
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind(), "Bad kind")
+	assert.Equal(t, attribute.StringValue("5"), got[domo.AttrObjectID], "Bad object id")
+	assert.Equal(t, attribute.IntValue(404), got[domo.AttrStatusCode], "Bad status")
+	assert.Equal(t, attribute.Int64Value(10), got[domo.AttrBytes], "Bad bytes")
+	assert.Equal(t, attribute.StringValue("[a]"), got["domo.test"], "Bad other value")
+	assert.Equal(t, attribute.StringValue("ABC"), got[domo.AttrToe], "Bad toe")
+	assert.Equal(t, codes.Error, spans[0].Status().Code, "Bad status code")
+	assert.Equal(t, 1, len(spans[0].Events()), "Error not recorded")
+}
+
+func TestTracer_upload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/oauth/token":
+			fmt.Fprint(w, `{"access_token": "token", "expires_in": 3600}`)
+		case r.URL.Path == "/v1/streams/7/executions":
+			w.WriteHeader(201)
+			fmt.Fprint(w, `{"id": 4, "currentState": "ACTIVE"}`)
+		case strings.HasPrefix(r.URL.Path, "/v1/streams/7/executions/4/"):
+			fmt.Fprint(w, `{"id": 4, "currentState": "SUCCESS"}`)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client := domo.New("<clientID>", "<secret>")
+	client.SetBaseURL(server.URL)
+	client.SetTracer(NewTracer(provider))
+
+	ctx, caller := provider.Tracer("test").Start(context.Background(), "caller")
+	_, err := client.Stream.UploadPartsContext(ctx, 7, []string{"a,1\n", "b,2\n"}, domo.UploadOptions{})
+	caller.End()
+	assert.Equal(t, nil, err, "Bad error code")
+
+	names := make(map[trace.SpanID]string)
+	for _, span := range recorder.Ended() {
+		names[span.SpanContext().SpanID()] = span.Name()
+	}
+	parents := make(map[string]string)
+	for _, span := range recorder.Ended() {
+		if attrs(span)[domo.AttrService].AsString() == "oauth" {
+			continue // the token is shared by every call, so its request isn't part of any one trace
+		}
+		assert.Equal(t, caller.SpanContext().TraceID(), span.SpanContext().TraceID(), "Span not in the caller's trace")
+		parents[span.Name()] = names[span.Parent().SpanID()]
+	}
+	assert.Equal(t, "caller", parents["domo upload"], "Upload not under the caller")
+	assert.Equal(t, "domo upload", parents["domo upload part"], "Part not under the upload")
+	assert.Equal(t, "domo upload", parents["domo commit"], "Commit not under the upload")
+}
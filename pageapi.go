@@ -1,9 +1,11 @@
 package domo
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
 )
 
 // PageService Page API service
@@ -16,7 +18,7 @@ type PageService service
 // Returns a page object if valid page ID was provided.
 func (p *PageService) Retrieve(pageID int) (page Page, err error) {
 	p.client.getAccessToken("dashboard")
-	url := fmt.Sprintf("%s/v1/pages/%d", baseURL, pageID)
+	url := fmt.Sprintf("%s/v1/pages/%d", p.client.baseURL, pageID)
 	bodyBytes, statuscode, err := p.client.genericGET(url, nil)
 
 	if err != nil {
@@ -26,7 +28,7 @@ func (p *PageService) Retrieve(pageID int) (page Page, err error) {
 		)
 	}
 
-	if statuscode == 404 {
+	if statuscode >= 300 {
 		message, _ := bytesToErrorMessage(bodyBytes)
 		err = fmt.Errorf("%d %s PageID: %d", message.Status, message.StatusReason, pageID)
 	} else {
@@ -41,90 +43,118 @@ func (p *PageService) Retrieve(pageID int) (page Page, err error) {
 
 }
 
+// PageCreate the fields of a new page. Name is required.
+type PageCreate struct {
+	Name       string      `json:"name"`                 // The name of the page
+	ParentID   int         `json:"parentId,omitempty"`   // The page this is a subpage of, none for a top level page
+	Locked     bool        `json:"locked"`               // Only the owner can change a locked page
+	CardIDs    []int       `json:"cardIds,omitempty"`    // The cards on the page
+	Visibility *Visibility `json:"visibility,omitempty"` // The users and groups that can see the page, besides the owner
+}
+
+// PagePatch the fields of a page to change. Fields left nil keep their current value.
+type PagePatch struct {
+	Name          *string     `json:"name,omitempty"`
+	ParentID      *int        `json:"parentId,omitempty"`      // Moves the page below another
+	OwnerID       *int        `json:"ownerId,omitempty"`       // Hands the page to another user
+	Locked        *bool       `json:"locked,omitempty"`        // Only the owner can change a locked page
+	CollectionIDs *[]int      `json:"collectionIds,omitempty"` // Every collection of the page, in their new order
+	CardIDs       *[]int      `json:"cardIds,omitempty"`       // The cards on the page
+	Visibility    *Visibility `json:"visibility,omitempty"`    // The users and groups that can see the page, besides the owner
+}
+
+// IDs a pointer to a list of ids, for the lists of a PagePatch
+func IDs(ids ...int) *[]int {
+	if ids == nil {
+		ids = []int{}
+	}
+	return &ids
+}
+
 // Create Creates a new page in your Domo instance.
+// Giving access to a user or group also gives them access to the parent page, if there is one.
 // Definition
 // POST https://api.domo.com/v1/pages
 // Returns
 // Returns a page object when successful.
-func (p *PageService) Create() (err error) {
-	p.client.getAccessToken("dashboard")
-
-	url := fmt.Sprintf("%s/v1/pages", baseURL)
-	header := map[string]string{
-		"Content-Type": "application/json",
-		"Accept":       "application/json",
+func (p *PageService) Create(ctx context.Context, create PageCreate) (page *Page, err error) {
+	if create.Name == "" {
+		return nil, errors.New("A new page needs a name")
 	}
-
-	// {
-	// 	"name": "API test page",
-	// 	 "parentId": 431196438,
-	// 	 "locked": "TRUE",
-	// 	 "cardIds": [12,2535,233,694],
-	// 	 "visibility": {
-	// 		  "userIds": [793,20,993,19234],
-	// 		  "groupIds": [32,25,17,74]
-	// 	  }
-	//   }
-
-	body := strings.NewReader("{\"name\":\"API test page\",\"id\":0,\"parentId\":431196438,\"ownerId\":0,\"locked\":true,\"collectionIds\":0,\"cardIds\":[12,2535,233,694],\"visibility\":{\"userIds\":[12,2535,233,694],\"groupIds\":[12,2535,233,694]},\"userIds\":0,\"groupIds\":0}")
-
-	bodyBytes, statuscode, err := p.client.genericPOST(url, body, header)
-
+	bodyBytes, err := p.client.sendJSON(ctx, "dashboard", "POST", fmt.Sprintf("%s/v1/pages", p.client.baseURL), create)
 	if err != nil {
-		return fmt.Errorf(
-			"Unable to Create response from DOMO API %s",
-			err,
-		)
+		return nil, fmt.Errorf("Failed to create page %s : %s", create.Name, err)
 	}
 
-	str := fmt.Sprintf("%s", bodyBytes)
-
-	fmt.Println(fmt.Sprintf("%v", body))
-
-	fmt.Println(statuscode)
-	fmt.Println("GET : " + url)
-	fmt.Println(str)
-	return
+	created, err := bytesToPage(bodyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal page %s", err)
+	}
+	logger(fmt.Sprintf("[PageService] Create : page '%s' created with ID %d", created.Name, created.ID))
+	return &created, nil
 }
 
-// UpdatePage Updates the specified page by providing values to parameters passed.
-// Any parameter left out of the request will cause the specific page’s attribute to remain unchanged.
+// Update Updates the specified page with the fields set in patch.
+// Any field left nil will cause the specific page’s attribute to remain unchanged.
 // Also, collections cannot be added or removed via this endpoint, only reordered.
 // Giving access to a user or group will also cause that user or group to have access to the parent page (if the page is a subpage).
 // Moving a page by updating the parentId will also cause everyone with access to the page to have access to the new parent page.
 // Definition
 // PUT https://api.domo.com/v1/pages/{PAGE_ID}
 // Returns
-// Returns the parameter of success or error based on the page ID being valid.
+// Returns the updated page object, nil when Domo doesn't send it.
+func (p *PageService) Update(ctx context.Context, pageID int, patch PagePatch) (page *Page, err error) {
+	if patch.Name != nil && *patch.Name == "" {
+		return nil, errors.New("A page needs a name")
+	}
+	if patch.ParentID != nil && *patch.ParentID == pageID {
+		return nil, errors.New("A page can't be its own parent")
+	}
+	bodyBytes, err := p.client.sendJSON(ctx, "dashboard", "PUT", fmt.Sprintf("%s/v1/pages/%d", p.client.baseURL, pageID), patch)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to update page %d : %s", pageID, err)
+	}
+	if len(bytes.TrimSpace(bodyBytes)) == 0 {
+		return nil, nil
+	}
+
+	updated, err := bytesToPage(bodyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal page %s", err)
+	}
+	return &updated, nil
+}
 
-// DeletePage Permanently deletes a page from your Domo instance.
+// Delete Permanently deletes a page from your Domo instance.
 // WARNING: This is destructive and cannot be reversed.
 // Definition
 // DELETE https://api.domo.com/v1/pages/{PAGE_ID}
 // Returns
 // Returns the parameter of success or error based on the page ID being valid.
-func (p *PageService) DeletePage(pageID int) (err error) {
-	p.client.getAccessToken("dashboard")
-
-	url := fmt.Sprintf("%s/v1/pages/%d", baseURL, pageID)
-	statuscode, err := p.client.genericDELETE(url, nil)
-
-	fmt.Println(statuscode)
-	fmt.Println("DELETE : " + url)
+func (p *PageService) Delete(ctx context.Context, pageID int) (err error) {
+	_, err = p.client.sendJSON(ctx, "dashboard", "DELETE", fmt.Sprintf("%s/v1/pages/%d", p.client.baseURL, pageID), nil)
+	if err != nil {
+		return fmt.Errorf("Failed to delete page %d : %s", pageID, err)
+	}
+	logger(fmt.Sprintf("[PageService] Delete : pageID %d deleted", pageID))
+	return nil
+}
 
-	return
+// DeletePage Permanently deletes a page from your Domo instance, see Delete.
+func (p *PageService) DeletePage(pageID int) (err error) {
+	return p.Delete(context.Background(), pageID)
 }
 
 // List Get a list of all pages in your Domo instance.
 // Definition
 // GET https://api.domo.com/v1/pages
 // Returns
-// Returns all page objects that meet argument criteria from original request.
+// Returns the top level pages, each with the pages directly below it.
 func (p *PageService) List() (pages Pages, err error) {
 	p.client.getAccessToken("dashboard")
 
-	url := fmt.Sprintf("%s/v1/pages", baseURL)
-	bodyBytes, _, err := p.client.genericGET(url, nil)
+	url := fmt.Sprintf("%s/v1/pages", p.client.baseURL)
+	bodyBytes, statuscode, err := p.client.genericGET(url, nil)
 
 	if err != nil {
 		return Pages{}, fmt.Errorf(
@@ -133,11 +163,12 @@ func (p *PageService) List() (pages Pages, err error) {
 		)
 	}
 
-	pages, err = bytesToPages(bodyBytes)
-
-	str := fmt.Sprintf("%s", bodyBytes)
-	fmt.Println(str)
+	if statuscode >= 300 {
+		message, _ := bytesToErrorMessage(bodyBytes)
+		return Pages{}, fmt.Errorf("Failed to list pages : %d %s", statuscode, message.StatusReason)
+	}
 
+	pages, err = bytesToPages(bodyBytes)
 	return
 
 	// Status :  404 (error)
@@ -147,49 +178,102 @@ func (p *PageService) List() (pages Pages, err error) {
 
 }
 
-// RetrieveCollection Retrieve a page collection ...
+// RetrieveCollection Retrieve the collections of a page, see ListCollections.
+func (p *PageService) RetrieveCollection(pageID int) (collections []PageCollection, err error) {
+	return p.ListCollections(context.Background(), pageID)
+}
+
+// ListCollections Retrieve the collections of a page, in the order they are shown.
 // Definition
 // GET https://api.domo.com/v1/pages/{PAGE_ID}/collections
 // Returns
-// Returns the parameter of success or error based on the page ID being valid.
-func (p *PageService) RetrieveCollection(pageID int) (err error) {
-	p.client.getAccessToken("dashboard")
-
-	url := fmt.Sprintf("%s/v1/pages/%d/collections", baseURL, pageID)
-	bodyBytes, _, err := p.client.genericGET(url, nil)
-
+// Returns the page's collection objects.
+func (p *PageService) ListCollections(ctx context.Context, pageID int) (collections []PageCollection, err error) {
+	url := fmt.Sprintf("%s/v1/pages/%d/collections", p.client.baseURL, pageID)
+	bodyBytes, err := p.client.sendJSON(ctx, "dashboard", "GET", url, nil)
 	if err != nil {
-		return fmt.Errorf(
-			"Unable to RetrieveCollection pages response from DOMO API %s",
-			err,
-		)
+		return nil, fmt.Errorf("Failed to list the collections of page %d : %s", pageID, err)
 	}
 
-	str := fmt.Sprintf("%s", bodyBytes)
-
-	fmt.Println("GET : " + url)
-	fmt.Println(str)
+	if err = json.Unmarshal(bodyBytes, &collections); err != nil {
+		err = fmt.Errorf("Unable to unmarshal collections %s", err)
+	}
 	return
 }
 
-// CreatePageCollection ...
+// PageCollectionCreate the fields of a new collection. Title is required.
+type PageCollectionCreate struct {
+	Title       string `json:"title"`                 // The title of the collection
+	Description string `json:"description,omitempty"` // The description of the collection
+	CardIDs     []int  `json:"cardIds,omitempty"`     // The cards in the collection, in order
+}
+
+// PageCollectionPatch the fields of a collection to change. Fields left nil keep their current value.
+type PageCollectionPatch struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	CardIDs     *[]int  `json:"cardIds,omitempty"` // Every card in the collection, in their new order
+}
+
+// CreateCollection Creates a new collection at the end of a page.
 // Definition
 // POST https://api.domo.com/v1/pages/{PAGE_ID}/collections
 // Returns
-// Returns the parameter of success or error based on the page ID being valid.
+// Returns the collection object when successful.
+func (p *PageService) CreateCollection(ctx context.Context, pageID int, create PageCollectionCreate) (collection *PageCollection, err error) {
+	if create.Title == "" {
+		return nil, errors.New("A new collection needs a title")
+	}
+	url := fmt.Sprintf("%s/v1/pages/%d/collections", p.client.baseURL, pageID)
+	bodyBytes, err := p.client.sendJSON(ctx, "dashboard", "POST", url, create)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create collection %s on page %d : %s", create.Title, pageID, err)
+	}
 
-// UpdatePageCollection ...
+	collection = &PageCollection{}
+	if err = json.Unmarshal(bodyBytes, collection); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal collection %s", err)
+	}
+	logger(fmt.Sprintf("[PageService] CreateCollection : collection '%s' created with ID %d", collection.Title, collection.ID))
+	return
+}
+
+// UpdateCollection Updates the specified collection with the fields set in patch.
 // Definition
 // PUT https://api.domo.com/v1/pages/{PAGE_ID}/collections/{PAGE_COLLECTION_ID}
 // Returns
 // Returns the parameter of success or error based on the page collection ID being valid.
+func (p *PageService) UpdateCollection(ctx context.Context, pageID int, collectionID int, patch PageCollectionPatch) (err error) {
+	if patch.Title != nil && *patch.Title == "" {
+		return errors.New("A collection needs a title")
+	}
+	url := fmt.Sprintf("%s/v1/pages/%d/collections/%d", p.client.baseURL, pageID, collectionID)
+	if _, err = p.client.sendJSON(ctx, "dashboard", "PUT", url, patch); err != nil {
+		err = fmt.Errorf("Failed to update collection %d on page %d : %s", collectionID, pageID, err)
+	}
+	return
+}
 
-// DeletePageCollection Permanently deletes a page collection from your Domo instance.
+// ReorderCollections Puts the collections of a page in a new order.
+// Every collection of the page must be listed, as collections can't be added or removed this way.
+func (p *PageService) ReorderCollections(ctx context.Context, pageID int, collectionIDs []int) (err error) {
+	_, err = p.Update(ctx, pageID, PagePatch{CollectionIDs: IDs(collectionIDs...)})
+	return
+}
+
+// DeleteCollection Permanently deletes a page collection from your Domo instance.
 // WARNING: This is destructive and cannot be reversed.
 // Definition
 // DELETE https://api.domo.com/v1/pages/{PAGE_ID}/collections/{COLLECTION_ID}
 // Returns
 // Returns the parameter of success or error based on the page collection ID being valid.
+func (p *PageService) DeleteCollection(ctx context.Context, pageID int, collectionID int) (err error) {
+	url := fmt.Sprintf("%s/v1/pages/%d/collections/%d", p.client.baseURL, pageID, collectionID)
+	if _, err = p.client.sendJSON(ctx, "dashboard", "DELETE", url, nil); err != nil {
+		err = fmt.Errorf("Failed to delete collection %d on page %d : %s", collectionID, pageID, err)
+	}
+	return
+}
 
 func bytesToPage(bodyBytes []byte) (page Page, err error) {
 	return page, json.Unmarshal(bodyBytes, &page)
@@ -1,6 +1,7 @@
 package domo
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -140,3 +141,74 @@ func TestPageService_ListPages(t *testing.T) {
 		})
 	}
 }
+
+func TestPageService_decode(t *testing.T) {
+	page, err := bytesToPage([]byte(`{"id": 5, "name": "Sales", "parentId": 2, "ownerId": 7, "locked": true,
+		"collectionIds": [11, 12], "cardIds": [21], "children": [{"id": 6, "name": "EMEA"}],
+		"visibility": {"userIds": [7, 8], "groupIds": [9]}}`))
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, []int{11, 12}, page.CollectionIDs, "Bad collections")
+	assert.Equal(t, []ChildrenPage{{Name: "EMEA", ID: 6}}, page.Children, "Bad children")
+	assert.Equal(t, []int{9}, page.Visibility.GroupIds, "Bad visibility")
+}
+
+func TestPageService_Create(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token":   tokenRoute,
+		"POST /v1/pages":     {responseCode: 201, response: `{"id": 5, "name": "Sales", "parentId": 2, "collectionIds": [], "cardIds": [21]}`},
+		"PUT /v1/pages/5":    {responseCode: 200, response: `{"id": 5, "name": "Sales", "parentId": 3, "collectionIds": [], "cardIds": []}`},
+		"DELETE /v1/pages/5": {responseCode: 204},
+	}}
+	p := CreateTestClient(doer)
+
+	page, err := p.Page.Create(context.Background(), PageCreate{Name: "Sales", ParentID: 2, CardIDs: []int{21}})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 5, page.ID, "Bad page")
+	assert.Equal(t, `{"name":"Sales","parentId":2,"locked":false,"cardIds":[21]}`, doer.bodies["POST /v1/pages"], "Bad create body")
+
+	page, err = p.Page.Update(context.Background(), 5, PagePatch{ParentID: Int(3), CardIDs: IDs()})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 3, page.ParentID, "Bad moved page")
+	assert.Equal(t, `{"parentId":3,"cardIds":[]}`, doer.bodies["PUT /v1/pages/5"], "Bad update body")
+
+	_, err = p.Page.Update(context.Background(), 5, PagePatch{ParentID: Int(5)})
+	assert.NotEqual(t, nil, err, "Own parent accepted")
+	_, err = p.Page.Create(context.Background(), PageCreate{})
+	assert.NotEqual(t, nil, err, "Nameless page accepted")
+
+	assert.Equal(t, nil, p.Page.Delete(context.Background(), 5), "Bad delete")
+	assert.NotEqual(t, nil, p.Page.Delete(context.Background(), 6), "Missing page deleted")
+}
+
+func TestPageService_collections(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token":                  tokenRoute,
+		"GET /v1/pages/5/collections":       {responseCode: 200, response: `[{"id": 11, "title": "Revenue", "description": "", "cardIds": [21, 22]}]`},
+		"POST /v1/pages/5/collections":      {responseCode: 201, response: `{"id": 12, "title": "Costs", "description": "By region", "cardIds": []}`},
+		"PUT /v1/pages/5/collections/11":    {responseCode: 204},
+		"PUT /v1/pages/5":                   {responseCode: 200, response: `{"id": 5, "name": "Sales", "collectionIds": [12, 11]}`},
+		"DELETE /v1/pages/5/collections/12": {responseCode: 204},
+	}}
+	p := CreateTestClient(doer)
+
+	collections, err := p.Page.ListCollections(context.Background(), 5)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, []PageCollection{{ID: 11, Title: "Revenue", CardIDs: []int{21, 22}}}, collections, "Bad collections")
+
+	collection, err := p.Page.CreateCollection(context.Background(), 5, PageCollectionCreate{Title: "Costs", Description: "By region"})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 12, collection.ID, "Bad collection")
+	assert.Equal(t, `{"title":"Costs","description":"By region"}`, doer.bodies["POST /v1/pages/5/collections"], "Bad create body")
+
+	err = p.Page.UpdateCollection(context.Background(), 5, 11, PageCollectionPatch{CardIDs: IDs(22, 21)})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, `{"cardIds":[22,21]}`, doer.bodies["PUT /v1/pages/5/collections/11"], "Bad update body")
+
+	assert.Equal(t, nil, p.Page.ReorderCollections(context.Background(), 5, []int{12, 11}), "Bad reorder")
+	assert.Equal(t, `{"collectionIds":[12,11]}`, doer.bodies["PUT /v1/pages/5"], "Bad reorder body")
+
+	assert.Equal(t, nil, p.Page.DeleteCollection(context.Background(), 5, 12), "Bad delete")
+	assert.NotEqual(t, nil, p.Page.DeleteCollection(context.Background(), 5, 13), "Missing collection deleted")
+	_, err = p.Page.CreateCollection(context.Background(), 5, PageCollectionCreate{})
+	assert.NotEqual(t, nil, err, "Untitled collection accepted")
+}
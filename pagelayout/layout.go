@@ -0,0 +1,201 @@
+// Package pagelayout keeps a hierarchy of Domo pages in line with a description of it, written in
+// JSON or, with the yaml build tag, YAML.
+//
+// A Layout names the top level pages it manages and, below each, the subpages, collections, cards,
+// locks and visibility they should have. Plan compares it with Page.Tree and the collections of each
+// page, matching pages and collections by name, and works out what to create, update and delete.
+// Anything that differs from the layout is drift. Apply makes the planned changes.
+//
+// Names may use ${variables}, so one layout can be cloned for every region:
+//
+//	pages:
+//	  - name: Sales ${region}
+//	    visibility: {groups: ["Sales ${region}"]}
+//	    subpages:
+//	      - name: Pipeline
+//	        cards: [101, 102]
+//	        collections:
+//	          - title: This quarter
+//	            cards: [101]
+package pagelayout
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Layout the pages a description manages
+type Layout struct {
+	Pages []PageSpec `json:"pages" yaml:"pages"` // The top level pages, each managed with everything below it
+}
+
+// PageSpec a page as the layout describes it.
+// Locked and Cards are left as they are on the page when the layout leaves them out,
+// so cards: [] takes every card off a page but a page with no cards line keeps its own.
+type PageSpec struct {
+	Name        string           `json:"name" yaml:"name"`                                   // Matched against the pages with the same parent, ignoring case
+	Locked      *bool            `json:"locked,omitempty" yaml:"locked,omitempty"`           // Only the owner can change a locked page, left as it is when nil
+	Cards       []int            `json:"cards" yaml:"cards"`                                 // The cards on the page, in order, left as they are when nil
+	Visibility  *VisibilitySpec  `json:"visibility,omitempty" yaml:"visibility,omitempty"`   // Who can see the page, left as it is when nil
+	Collections []CollectionSpec `json:"collections,omitempty" yaml:"collections,omitempty"` // The collections of the page, in order
+	Subpages    []PageSpec       `json:"subpages,omitempty" yaml:"subpages,omitempty"`       // The pages directly below
+}
+
+// CollectionSpec a collection of cards as the layout describes it
+type CollectionSpec struct {
+	Title       string `json:"title" yaml:"title"`                                 // Matched against the collections of the page, ignoring case
+	Description string `json:"description,omitempty" yaml:"description,omitempty"` // The description of the collection
+	Cards       []int  `json:"cards" yaml:"cards"`                                 // The cards in the collection, in order, left as they are when nil
+}
+
+// VisibilitySpec the users and groups that can see a page, besides its owner.
+// Users and groups may be given by id, or by email and name to be looked up when planning.
+type VisibilitySpec struct {
+	UserIDs  []int    `json:"userIds,omitempty" yaml:"userIds,omitempty"`
+	GroupIDs []int    `json:"groupIds,omitempty" yaml:"groupIds,omitempty"`
+	Users    []string `json:"users,omitempty" yaml:"users,omitempty"`   // Emails
+	Groups   []string `json:"groups,omitempty" yaml:"groups,omitempty"` // Group names
+}
+
+// decoders unmarshal a layout, by file extension. The yaml build tag adds .yaml and .yml.
+var decoders = map[string]func(data []byte, layout *Layout) error{
+	".json": decodeJSON,
+}
+
+// decodeJSON unmarshals a JSON layout, refusing fields it doesn't know so a misspelt one isn't ignored
+func decodeJSON(data []byte, layout *Layout) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(layout)
+}
+
+// Load reads a layout from a file, choosing the format from its extension
+func Load(path string) (*Layout, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := Parse(data, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return layout, nil
+}
+
+// Parse reads a layout in a format named by its file extension, such as .json or .yaml, and checks it
+func Parse(data []byte, format string) (*Layout, error) {
+	format = strings.ToLower(format)
+	decode := decoders[format]
+	if decode == nil && (format == ".yaml" || format == ".yml") {
+		return nil, fmt.Errorf("YAML layouts need the yaml build tag")
+	}
+	if decode == nil {
+		return nil, fmt.Errorf("Unknown layout format %q", format)
+	}
+
+	layout := &Layout{}
+	if err := decode(data, layout); err != nil {
+		return nil, fmt.Errorf("Unable to read layout %s", err)
+	}
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// Validate checks every page and collection has a name, and that none shares it with another
+// of the same parent, which would make matching them against Domo ambiguous
+func (l *Layout) Validate() error {
+	if len(l.Pages) == 0 {
+		return fmt.Errorf("The layout has no pages")
+	}
+	return validatePages(l.Pages, "")
+}
+
+func validatePages(pages []PageSpec, parent string) error {
+	seen := make(map[string]bool)
+	for _, page := range pages {
+		path := joinPath(parent, page.Name)
+		if strings.TrimSpace(page.Name) == "" {
+			return fmt.Errorf("A page below %q has no name", parent)
+		}
+		if seen[key(page.Name)] {
+			return fmt.Errorf("The layout has more than one page %s", path)
+		}
+		seen[key(page.Name)] = true
+
+		titles := make(map[string]bool)
+		for _, collection := range page.Collections {
+			if strings.TrimSpace(collection.Title) == "" {
+				return fmt.Errorf("A collection of %s has no title", path)
+			}
+			if titles[key(collection.Title)] {
+				return fmt.Errorf("%s has more than one collection %s", path, collection.Title)
+			}
+			titles[key(collection.Title)] = true
+		}
+		if err := validatePages(page.Subpages, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Expand replaces ${name} and $name in page names, collection titles and descriptions, and the users
+// and groups of visibility with the values of vars. A variable without a value is an error, so a
+// layout is never applied half filled in.
+func (l *Layout) Expand(vars map[string]string) error {
+	var missing []string
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			value, ok := vars[name]
+			if !ok {
+				missing = append(missing, name)
+			}
+			return value
+		})
+	}
+	expandPages(l.Pages, expand)
+	if len(missing) > 0 {
+		return fmt.Errorf("The layout uses variables with no value: %s", strings.Join(missing, ", "))
+	}
+	return l.Validate()
+}
+
+func expandPages(pages []PageSpec, expand func(string) string) {
+	for i := range pages {
+		page := &pages[i]
+		page.Name = expand(page.Name)
+		for j := range page.Collections {
+			page.Collections[j].Title = expand(page.Collections[j].Title)
+			page.Collections[j].Description = expand(page.Collections[j].Description)
+		}
+		if page.Visibility != nil {
+			for j := range page.Visibility.Users {
+				page.Visibility.Users[j] = expand(page.Visibility.Users[j])
+			}
+			for j := range page.Visibility.Groups {
+				page.Visibility.Groups[j] = expand(page.Visibility.Groups[j])
+			}
+		}
+		expandPages(page.Subpages, expand)
+	}
+}
+
+// key the form of a name pages and collections are matched on
+func key(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// joinPath the path of a page below parent, with names separated by " / " as PageNode.Path does
+func joinPath(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + " / " + name
+}
@@ -0,0 +1,43 @@
+package pagelayout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	layout, err := Parse([]byte(`{"pages": [{"name": "Sales ${region}", "visibility": {"groups": ["Sales ${region}"]},
+		"subpages": [{"name": "Pipeline", "locked": true, "cards": [1, 2], "collections": [{"title": "This quarter", "cards": [1]}]}]}]}`), ".json")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, "Pipeline", layout.Pages[0].Subpages[0].Name, "Bad subpage")
+	assert.Equal(t, []int{1}, layout.Pages[0].Subpages[0].Collections[0].Cards, "Bad collection")
+
+	assert.Equal(t, nil, layout.Expand(map[string]string{"region": "EMEA"}), "Bad expand")
+	assert.Equal(t, "Sales EMEA", layout.Pages[0].Name, "Bad page name")
+	assert.Equal(t, []string{"Sales EMEA"}, layout.Pages[0].Visibility.Groups, "Bad group name")
+
+	layout, _ = Parse([]byte(`{"pages": [{"name": "Sales ${region}"}]}`), ".json")
+	assert.NotEqual(t, nil, layout.Expand(nil), "Missing variable accepted")
+}
+
+func TestParse_invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+	}{
+		{"unknown field", `{"pages": [{"name": "Sales", "lokced": true}]}`, ".json"},
+		{"no pages", `{"pages": []}`, ".json"},
+		{"no name", `{"pages": [{"name": " "}]}`, ".json"},
+		{"same name", `{"pages": [{"name": "Sales", "subpages": [{"name": "EMEA"}, {"name": "emea"}]}]}`, ".json"},
+		{"same title", `{"pages": [{"name": "Sales", "collections": [{"title": "Q1"}, {"title": "Q1 "}]}]}`, ".json"},
+		{"unknown format", `pages = []`, ".toml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data), tt.format)
+			assert.NotEqual(t, nil, err, "Bad layout accepted")
+		})
+	}
+}
@@ -0,0 +1,571 @@
+package pagelayout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// Options controls what a Run changes
+type Options struct {
+	Prune  bool // Delete the pages and collections below managed pages that the layout doesn't name
+	DryRun bool // Plan the changes and report them without making any
+}
+
+// Action the kind of a Change
+type Action string
+
+// Actions in the order they are applied
+const (
+	CreatePage         Action = "create page"
+	UpdatePage         Action = "update page"
+	CreateCollection   Action = "create collection"
+	UpdateCollection   Action = "update collection"
+	DeleteCollection   Action = "delete collection"
+	ReorderCollections Action = "reorder collections"
+	DeletePage         Action = "delete page"
+)
+
+// Change one thing an apply does
+type Change struct {
+	Action       Action
+	Path         string   // The page, as the names from the top level down
+	PageID       int      // The Domo id of the page, 0 until a new page is created
+	Collection   string   // The title of the collection created, updated or deleted
+	CollectionID int      // The Domo id of the collection, 0 until a new one is created
+	Fields       []string // The fields an update changes, which are the ones that drifted
+	Err          error    // Why the change failed, once it has been applied
+
+	parent           string // The path of the page a new page goes below
+	pageCreate       domo.PageCreate
+	pagePatch        domo.PagePatch
+	collectionCreate domo.PageCollectionCreate
+	collectionPatch  domo.PageCollectionPatch
+	order            []collectionRef
+}
+
+// collectionRef a collection in a new order, by id or, if it is still to be created, by title
+type collectionRef struct {
+	id    int
+	title string
+}
+
+// String describes the change, as in "update page Sales / Pipeline (cards, locked)"
+func (c Change) String() string {
+	fields := ""
+	if len(c.Fields) > 0 {
+		fields = " (" + strings.Join(c.Fields, ", ") + ")"
+	}
+	switch c.Action {
+	case CreateCollection, UpdateCollection, DeleteCollection:
+		return fmt.Sprintf("%s %s on %s%s", c.Action, c.Collection, c.Path, fields)
+	case ReorderCollections:
+		return fmt.Sprintf("%s of %s", c.Action, c.Path)
+	}
+	return fmt.Sprintf("%s %s%s", c.Action, c.Path, fields)
+}
+
+// Report what an apply did, or would do on a dry run
+type Report struct {
+	Changes   []Change // Every change, in the order it was applied
+	Unchanged int      // Pages the layout names that needed no change
+	Unmanaged []string // Pages and collections below managed pages that the layout doesn't name, left alone as Prune was off
+	Failed    int      // Changes that couldn't be made
+	DryRun    bool     // Whether the changes were only planned
+}
+
+// Count the number of changes of a kind
+func (r Report) Count(action Action) (n int) {
+	for _, change := range r.Changes {
+		if change.Action == action {
+			n++
+		}
+	}
+	return
+}
+
+// Drift the changes to pages and collections that already exist, which were changed outside the layout
+func (r Report) Drift() (drift []Change) {
+	for _, change := range r.Changes {
+		if change.Action != CreatePage && change.Action != CreateCollection {
+			drift = append(drift, change)
+		}
+	}
+	return
+}
+
+// InSync whether Domo already matches the layout, with nothing to change and nothing unmanaged
+func (r Report) InSync() bool {
+	return len(r.Changes) == 0 && len(r.Unmanaged) == 0
+}
+
+// Print writes every change, with any failure, the unmanaged pages and collections, and then a summary line
+func (r Report) Print(w io.Writer) {
+	prefix := ""
+	if r.DryRun {
+		prefix = "[dry run] "
+	}
+	for _, change := range r.Changes {
+		if change.Err != nil {
+			fmt.Fprintf(w, "%s%s: %s\n", prefix, change, change.Err)
+		} else {
+			fmt.Fprintf(w, "%s%s\n", prefix, change)
+		}
+	}
+	for _, path := range r.Unmanaged {
+		fmt.Fprintf(w, "%sunmanaged %s\n", prefix, path)
+	}
+	fmt.Fprintf(w, "%s%d pages created, %d updated, %d deleted, %d unchanged, %d collections created, %d updated, %d deleted, %d reordered, %d unmanaged, %d failed\n",
+		prefix, r.Count(CreatePage), r.Count(UpdatePage), r.Count(DeletePage), r.Unchanged,
+		r.Count(CreateCollection), r.Count(UpdateCollection), r.Count(DeleteCollection), r.Count(ReorderCollections),
+		len(r.Unmanaged), r.Failed)
+}
+
+// Run plans the changes that bring Domo in line with the layout and, unless it is a dry run, applies them
+func Run(ctx context.Context, client *domo.Client, layout *Layout, opts Options) (report Report, err error) {
+	report, err = Plan(ctx, client, layout, opts)
+	if err != nil || opts.DryRun {
+		return
+	}
+	err = Apply(ctx, client, &report)
+	return
+}
+
+// planner what Plan has found so far
+type planner struct {
+	ctx    context.Context
+	client *domo.Client
+	opts   Options
+	users  map[string]int // ids by lower case email
+	groups map[string]int // ids by lower case name
+
+	report      Report
+	pages       []Change
+	creates     []Change
+	updates     []Change
+	deletes     []Change
+	reorders    []Change
+	pageDeletes []Change
+}
+
+// Plan works out the changes that bring Domo in line with the layout, without making any.
+// Pages are matched by name, ignoring case, among the pages with the same parent, and collections by
+// title among those of their page.
+func Plan(ctx context.Context, client *domo.Client, layout *Layout, opts Options) (report Report, err error) {
+	if err = layout.Validate(); err != nil {
+		return
+	}
+	tree, err := client.Page.Tree(ctx)
+	if err != nil {
+		return
+	}
+
+	p := &planner{ctx: ctx, client: client, opts: opts, users: make(map[string]int), groups: make(map[string]int)}
+	p.report.DryRun = opts.DryRun
+	if err = p.planPages(layout.Pages, tree.Roots, "", 0); err != nil {
+		return
+	}
+
+	report = p.report
+	for _, changes := range [][]Change{p.pages, p.creates, p.updates, p.deletes, p.reorders, p.pageDeletes} {
+		report.Changes = append(report.Changes, changes...)
+	}
+	return
+}
+
+// planPages plans the pages of a layout below the page at parent, whose id is parentID if it exists.
+// existing are the pages already there.
+func (p *planner) planPages(specs []PageSpec, existing []*domo.PageNode, parent string, parentID int) error {
+	named := make(map[string][]*domo.PageNode)
+	for _, node := range existing {
+		named[key(node.Name)] = append(named[key(node.Name)], node)
+	}
+
+	for i := range specs {
+		spec := &specs[i]
+		path := joinPath(parent, spec.Name)
+		matches := named[key(spec.Name)]
+		delete(named, key(spec.Name))
+		if len(matches) > 1 {
+			var candidates []domo.Candidate
+			for _, node := range matches {
+				candidates = append(candidates, domo.Candidate{ID: node.ID, Name: node.Path()})
+			}
+			return &domo.AmbiguousError{Kind: "page", Field: "path", Value: path, Candidates: candidates}
+		}
+
+		visibility, err := p.visibility(spec)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			if err := p.planCreate(spec, path, parent, parentID, visibility); err != nil {
+				return err
+			}
+			continue
+		}
+
+		node := matches[0]
+		if err := p.planUpdate(spec, node, path, visibility); err != nil {
+			return err
+		}
+		if err := p.planCollections(spec, node, path); err != nil {
+			return err
+		}
+		if err := p.planPages(spec.Subpages, node.Subpages, path, node.ID); err != nil {
+			return err
+		}
+	}
+
+	if parent == "" {
+		return nil // Top level pages the layout doesn't name aren't managed
+	}
+	for _, node := range existing {
+		if named[key(node.Name)] != nil {
+			p.planDelete(node)
+		}
+	}
+	return nil
+}
+
+// planCreate plans a new page, with its collections and everything below it
+func (p *planner) planCreate(spec *PageSpec, path string, parent string, parentID int, visibility *domo.Visibility) error {
+	p.pages = append(p.pages, Change{Action: CreatePage, Path: path, parent: parent, pageCreate: domo.PageCreate{
+		Name:       spec.Name,
+		ParentID:   parentID,
+		Locked:     spec.Locked != nil && *spec.Locked,
+		CardIDs:    spec.Cards,
+		Visibility: visibility,
+	}})
+	for _, collection := range spec.Collections {
+		p.creates = append(p.creates, Change{Action: CreateCollection, Path: path, Collection: collection.Title,
+			collectionCreate: domo.PageCollectionCreate{Title: collection.Title, Description: collection.Description, CardIDs: collection.Cards}})
+	}
+	for i := range spec.Subpages {
+		subpage := &spec.Subpages[i]
+		subVisibility, err := p.visibility(subpage)
+		if err != nil {
+			return err
+		}
+		if err := p.planCreate(subpage, joinPath(path, subpage.Name), path, 0, subVisibility); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planUpdate plans the changes to the fields of an existing page that has drifted from the layout
+func (p *planner) planUpdate(spec *PageSpec, node *domo.PageNode, path string, visibility *domo.Visibility) error {
+	change := Change{Action: UpdatePage, Path: path, PageID: node.ID}
+	if node.Name != spec.Name {
+		change.Fields = append(change.Fields, "name")
+		change.pagePatch.Name = domo.String(spec.Name)
+	}
+	if spec.Locked != nil && node.Locked != *spec.Locked {
+		change.Fields = append(change.Fields, "locked")
+		change.pagePatch.Locked = spec.Locked
+	}
+	if spec.Cards != nil && !sameOrder(node.CardIds, spec.Cards) {
+		change.Fields = append(change.Fields, "cards")
+		change.pagePatch.CardIDs = domo.IDs(spec.Cards...)
+	}
+	if visibility != nil && !sameVisibility(node.Visibility, *visibility, node.OwnerID) {
+		change.Fields = append(change.Fields, "visibility")
+		change.pagePatch.Visibility = visibility
+	}
+	if len(change.Fields) == 0 {
+		p.report.Unchanged++
+		return nil
+	}
+	p.pages = append(p.pages, change)
+	return nil
+}
+
+// planCollections plans the collections of an existing page: those to create, update and delete, and
+// whether they then need putting in the layout's order
+func (p *planner) planCollections(spec *PageSpec, node *domo.PageNode, path string) error {
+	current, err := p.client.Page.ListCollections(p.ctx, node.ID)
+	if err != nil {
+		return err
+	}
+	titled := make(map[string][]domo.PageCollection)
+	for _, collection := range current {
+		titled[key(collection.Title)] = append(titled[key(collection.Title)], collection)
+	}
+
+	var wanted, projected []collectionRef
+	kept := make(map[int]bool)
+	var created []collectionRef
+	for _, collection := range spec.Collections {
+		matches := titled[key(collection.Title)]
+		delete(titled, key(collection.Title))
+		if len(matches) > 1 {
+			var candidates []domo.Candidate
+			for _, match := range matches {
+				candidates = append(candidates, domo.Candidate{ID: match.ID, Name: match.Title})
+			}
+			return &domo.AmbiguousError{Kind: "collection", Field: "title", Value: path + " / " + collection.Title, Candidates: candidates}
+		}
+		if len(matches) == 0 {
+			p.creates = append(p.creates, Change{Action: CreateCollection, Path: path, PageID: node.ID, Collection: collection.Title,
+				collectionCreate: domo.PageCollectionCreate{Title: collection.Title, Description: collection.Description, CardIDs: collection.Cards}})
+			ref := collectionRef{title: collection.Title}
+			wanted = append(wanted, ref)
+			created = append(created, ref)
+			continue
+		}
+
+		existing := matches[0]
+		kept[existing.ID] = true
+		wanted = append(wanted, collectionRef{id: existing.ID})
+		change := Change{Action: UpdateCollection, Path: path, PageID: node.ID, Collection: existing.Title, CollectionID: existing.ID}
+		if existing.Title != collection.Title {
+			change.Fields = append(change.Fields, "title")
+			change.collectionPatch.Title = domo.String(collection.Title)
+		}
+		if existing.Description != collection.Description {
+			change.Fields = append(change.Fields, "description")
+			change.collectionPatch.Description = domo.String(collection.Description)
+		}
+		if collection.Cards != nil && !sameOrder(existing.CardIDs, collection.Cards) {
+			change.Fields = append(change.Fields, "cards")
+			change.collectionPatch.CardIDs = domo.IDs(collection.Cards...)
+		}
+		if len(change.Fields) > 0 {
+			p.updates = append(p.updates, change)
+		}
+	}
+
+	for _, collection := range current {
+		if kept[collection.ID] {
+			projected = append(projected, collectionRef{id: collection.ID})
+			continue
+		}
+		if p.opts.Prune {
+			p.deletes = append(p.deletes, Change{Action: DeleteCollection, Path: path, PageID: node.ID, Collection: collection.Title, CollectionID: collection.ID})
+			continue
+		}
+		p.report.Unmanaged = append(p.report.Unmanaged, fmt.Sprintf("collection %s on %s", collection.Title, path))
+		projected = append(projected, collectionRef{id: collection.ID})
+		wanted = append(wanted, collectionRef{id: collection.ID})
+	}
+	projected = append(projected, created...)
+
+	if !sameRefs(projected, wanted) {
+		p.reorders = append(p.reorders, Change{Action: ReorderCollections, Path: path, PageID: node.ID, order: wanted})
+	}
+	return nil
+}
+
+// planDelete plans deleting a page the layout doesn't name, and the pages below it first.
+// Without Prune it is only reported as unmanaged.
+func (p *planner) planDelete(node *domo.PageNode) {
+	if !p.opts.Prune {
+		p.report.Unmanaged = append(p.report.Unmanaged, "page "+node.Path())
+		return
+	}
+	var below []Change
+	node.Walk(func(n *domo.PageNode) error {
+		below = append(below, Change{Action: DeletePage, Path: n.Path(), PageID: n.ID})
+		return nil
+	})
+	for i := len(below) - 1; i >= 0; i-- {
+		p.pageDeletes = append(p.pageDeletes, below[i])
+	}
+}
+
+// visibility the users and groups who should see a page, nil if the layout leaves it alone.
+// Domo gives whoever can see a subpage access to the pages above it, so those are included too.
+func (p *planner) visibility(spec *PageSpec) (*domo.Visibility, error) {
+	if spec.Visibility == nil {
+		return nil, nil
+	}
+	visibility := &domo.Visibility{UserIds: []int{}, GroupIds: []int{}}
+	var add func(spec *PageSpec) error
+	add = func(spec *PageSpec) error {
+		if spec.Visibility != nil {
+			users, groups, err := p.resolve(spec.Visibility)
+			if err != nil {
+				return err
+			}
+			visibility.UserIds = union(visibility.UserIds, users)
+			visibility.GroupIds = union(visibility.GroupIds, groups)
+		}
+		for i := range spec.Subpages {
+			if err := add(&spec.Subpages[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := add(spec); err != nil {
+		return nil, err
+	}
+	return visibility, nil
+}
+
+// resolve the ids of the users and groups of a VisibilitySpec, looking up emails and names once each
+func (p *planner) resolve(spec *VisibilitySpec) (users []int, groups []int, err error) {
+	users = append(users, spec.UserIDs...)
+	for _, email := range spec.Users {
+		id, ok := p.users[key(email)]
+		if !ok {
+			user, err := p.client.User.FindByEmail(p.ctx, email)
+			if err != nil {
+				return nil, nil, err
+			}
+			id = user.ID
+			p.users[key(email)] = id
+		}
+		users = append(users, id)
+	}
+
+	groups = append(groups, spec.GroupIDs...)
+	for _, name := range spec.Groups {
+		id, ok := p.groups[key(name)]
+		if !ok {
+			if id, err = p.client.Group.Find(name); err != nil {
+				return nil, nil, err
+			}
+			p.groups[key(name)] = id
+		}
+		groups = append(groups, id)
+	}
+	return
+}
+
+// Apply makes the planned changes in order. A change that fails is recorded in the report and the rest
+// are still tried, apart from those that need a page or collection that couldn't be created.
+func Apply(ctx context.Context, client *domo.Client, report *Report) error {
+	report.DryRun = false
+	pages := make(map[string]int)       // ids of new pages by path
+	collections := make(map[string]int) // ids of new collections by path and lower case title
+
+	for i := range report.Changes {
+		change := &report.Changes[i]
+		if change.PageID == 0 && change.Action != CreatePage {
+			change.PageID = pages[change.Path]
+		}
+
+		switch {
+		case change.Action == CreatePage:
+			if change.pageCreate.ParentID == 0 && change.parent != "" {
+				if change.pageCreate.ParentID = pages[change.parent]; change.pageCreate.ParentID == 0 {
+					change.Err = errors.New("the parent page wasn't created")
+					break
+				}
+			}
+			var page *domo.Page
+			if page, change.Err = client.Page.Create(ctx, change.pageCreate); change.Err == nil {
+				change.PageID = page.ID
+				pages[change.Path] = page.ID
+			}
+		case change.PageID == 0:
+			change.Err = errors.New("the page wasn't created")
+		case change.Action == UpdatePage:
+			_, change.Err = client.Page.Update(ctx, change.PageID, change.pagePatch)
+		case change.Action == CreateCollection:
+			var collection *domo.PageCollection
+			if collection, change.Err = client.Page.CreateCollection(ctx, change.PageID, change.collectionCreate); change.Err == nil {
+				change.CollectionID = collection.ID
+				collections[joinPath(change.Path, key(change.Collection))] = collection.ID
+			}
+		case change.Action == UpdateCollection:
+			change.Err = client.Page.UpdateCollection(ctx, change.PageID, change.CollectionID, change.collectionPatch)
+		case change.Action == DeleteCollection:
+			change.Err = client.Page.DeleteCollection(ctx, change.PageID, change.CollectionID)
+		case change.Action == ReorderCollections:
+			var ids []int
+			for _, ref := range change.order {
+				if ref.id == 0 {
+					if ref.id = collections[joinPath(change.Path, key(ref.title))]; ref.id == 0 {
+						change.Err = fmt.Errorf("collection %s wasn't created", ref.title)
+						break
+					}
+				}
+				ids = append(ids, ref.id)
+			}
+			if change.Err == nil {
+				change.Err = client.Page.ReorderCollections(ctx, change.PageID, ids)
+			}
+		case change.Action == DeletePage:
+			change.Err = client.Page.Delete(ctx, change.PageID)
+		}
+
+		if change.Err != nil {
+			report.Failed++
+		}
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d changes failed", report.Failed, len(report.Changes))
+	}
+	return nil
+}
+
+// sameOrder whether two lists hold the same ids in the same order, a nil list being empty
+func sameOrder(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameRefs whether two lists name the same collections in the same order
+func sameRefs(a []collectionRef, b []collectionRef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].id != b[i].id || key(a[i].title) != key(b[i].title) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameVisibility whether a page is visible to the wanted users and groups, in any order.
+// Domo may list the owner, who can always see the page, so they are ignored unless wanted.
+func sameVisibility(current domo.Visibility, wanted domo.Visibility, ownerID int) bool {
+	users := sorted(current.UserIds, ownerID)
+	for _, id := range wanted.UserIds {
+		if id == ownerID {
+			users = sorted(current.UserIds, 0)
+		}
+	}
+	return sameOrder(users, sorted(wanted.UserIds, 0)) && sameOrder(sorted(current.GroupIds, 0), sorted(wanted.GroupIds, 0))
+}
+
+// sorted a sorted copy of ids, leaving out skip
+func sorted(ids []int, skip int) (result []int) {
+	for _, id := range ids {
+		if id != skip || skip == 0 {
+			result = append(result, id)
+		}
+	}
+	sort.Ints(result)
+	return
+}
+
+// union the ids in either list, in the order they were first seen
+func union(a []int, b []int) []int {
+	seen := make(map[int]bool)
+	result := []int{}
+	for _, id := range append(append([]int(nil), a...), b...) {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,159 @@
+package pagelayout
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/davecb/domoStreamApi/domotest"
+	"github.com/stretchr/testify/assert"
+)
+
+// testLayout a region's sales pages: a locked pipeline with two collections and a new forecast page
+const testLayout = `{"pages": [{"name": "Sales ${region}", "visibility": {"groups": ["Sales ${region}"]}, "subpages": [
+	{"name": "Pipeline", "locked": true, "cards": [1, 2], "collections": [
+		{"title": "Next quarter", "cards": [2]},
+		{"title": "This quarter", "cards": [1]}
+	]},
+	{"name": "Forecast", "collections": [{"title": "Summary"}]}
+]}]}`
+
+// testInstance Sales EMEA as it was built by hand, with a page and a collection the layout drops,
+// and a Marketing page the layout doesn't manage
+func testInstance(t *testing.T) (server *domotest.Server, group domo.Group) {
+	server = domotest.NewServer()
+	group = server.AddGroup(domo.Group{Name: "Sales EMEA"})
+	sales := server.AddPage(domotest.Page{Name: "Sales EMEA"})
+	pipeline := server.AddPage(domotest.Page{Name: "pipeline", ParentID: sales.ID, CardIDs: []int{1}})
+	server.AddPage(domotest.Page{Name: "Old", ParentID: sales.ID})
+	server.AddPage(domotest.Page{Name: "Marketing"})
+	for _, title := range []string{"Stale", "This quarter"} {
+		_, err := server.Client().Page.CreateCollection(context.Background(), pipeline.ID, domo.PageCollectionCreate{Title: title, CardIDs: []int{1, 2}})
+		assert.Equal(t, nil, err, "Bad collection")
+	}
+	return
+}
+
+func testExpanded(t *testing.T) *Layout {
+	layout, err := Parse([]byte(testLayout), ".json")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, nil, layout.Expand(map[string]string{"region": "EMEA"}), "Bad expand")
+	return layout
+}
+
+func TestRun(t *testing.T) {
+	server, group := testInstance(t)
+	defer server.Close()
+	client := server.Client()
+	layout := testExpanded(t)
+
+	report, err := Run(context.Background(), client, layout, Options{Prune: true, DryRun: true})
+	assert.Equal(t, nil, err, "Bad error code")
+	var changes []string
+	for _, change := range report.Changes {
+		changes = append(changes, change.String())
+	}
+	assert.Equal(t, []string{
+		"update page Sales EMEA (visibility)",
+		"update page Sales EMEA / Pipeline (name, locked, cards)",
+		"create page Sales EMEA / Forecast",
+		"create collection Next quarter on Sales EMEA / Pipeline",
+		"create collection Summary on Sales EMEA / Forecast",
+		"update collection This quarter on Sales EMEA / Pipeline (cards)",
+		"delete collection Stale on Sales EMEA / Pipeline",
+		"reorder collections of Sales EMEA / Pipeline",
+		"delete page Sales EMEA / Old",
+	}, changes, "Bad plan")
+	assert.Equal(t, 6, len(report.Drift()), "Bad drift")
+	assert.Equal(t, 4, len(server.Pages()), "Dry run changed something")
+
+	var out bytes.Buffer
+	report.Print(&out)
+	assert.Equal(t, true, strings.HasPrefix(out.String(), "[dry run] update page Sales EMEA (visibility)\n"), "Bad dry run output")
+
+	report, err = Run(context.Background(), client, layout, Options{Prune: true})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 0, report.Failed, "Bad failures")
+
+	report, err = Plan(context.Background(), client, layout, Options{Prune: true})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, true, report.InSync(), "Not in sync after apply")
+	assert.Equal(t, 3, report.Unchanged, "Bad unchanged")
+
+	var pipeline, forecast domotest.Page
+	for _, page := range server.Pages() {
+		switch page.Name {
+		case "Pipeline":
+			pipeline = page
+		case "Forecast":
+			forecast = page
+		case "Marketing":
+		case "Sales EMEA":
+			assert.Equal(t, []int{group.ID}, page.Visibility.GroupIDs, "Bad visibility")
+		default:
+			t.Errorf("Page %s not deleted", page.Name)
+		}
+	}
+	assert.Equal(t, true, pipeline.Locked, "Pipeline not locked")
+	assert.Equal(t, []int{1, 2}, pipeline.CardIDs, "Bad cards")
+	collections, _ := client.Page.ListCollections(context.Background(), pipeline.ID)
+	assert.Equal(t, 2, len(collections), "Bad collections")
+	assert.Equal(t, "Next quarter", collections[0].Title, "Collections not reordered")
+	assert.Equal(t, []int{1}, collections[1].CardIDs, "Collection not updated")
+	collections, _ = client.Page.ListCollections(context.Background(), forecast.ID)
+	assert.Equal(t, "Summary", collections[0].Title, "Collection of new page not created")
+}
+
+func TestPlan_unmanaged(t *testing.T) {
+	server, _ := testInstance(t)
+	defer server.Close()
+
+	report, err := Plan(context.Background(), server.Client(), testExpanded(t), Options{})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, []string{"collection Stale on Sales EMEA / Pipeline", "page Sales EMEA / Old"}, report.Unmanaged, "Bad unmanaged")
+	assert.Equal(t, 0, report.Count(DeletePage)+report.Count(DeleteCollection), "Deleted without prune")
+	assert.Equal(t, false, report.InSync(), "Drift not found")
+}
+
+func TestPlan_omitted(t *testing.T) {
+	server := domotest.NewServer()
+	defer server.Close()
+	sales := server.AddPage(domotest.Page{Name: "Sales"})
+	server.AddPage(domotest.Page{Name: "Pipeline", ParentID: sales.ID, Locked: true, CardIDs: []int{1, 2}})
+
+	// Without cards or locked the page keeps its own
+	layout, err := Parse([]byte(`{"pages": [{"name": "Sales", "subpages": [{"name": "Pipeline"}]}]}`), ".json")
+	assert.Equal(t, nil, err, "Bad error code")
+	report, err := Plan(context.Background(), server.Client(), layout, Options{})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, true, report.InSync(), "Omitted fields planned as changes")
+
+	// An empty list of cards takes them all off, and locked false unlocks
+	layout, err = Parse([]byte(`{"pages": [{"name": "Sales", "subpages": [{"name": "Pipeline", "cards": [], "locked": false}]}]}`), ".json")
+	assert.Equal(t, nil, err, "Bad error code")
+	report, err = Run(context.Background(), server.Client(), layout, Options{})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, "update page Sales / Pipeline (locked, cards)", report.Changes[0].String(), "Bad plan")
+	for _, page := range server.Pages() {
+		if page.Name == "Pipeline" {
+			assert.Equal(t, false, page.Locked, "Page not unlocked")
+			assert.Equal(t, 0, len(page.CardIDs), "Cards not taken off")
+		}
+	}
+}
+
+func TestApply_failure(t *testing.T) {
+	server := domotest.NewServer()
+	defer server.Close()
+	server.Inject(domotest.Fault{Method: "POST", Path: "/v1/pages", Status: 500, Times: 1})
+	layout, _ := Parse([]byte(`{"pages": [{"name": "Sales", "subpages": [{"name": "EMEA", "collections": [{"title": "Q1"}]}]}]}`), ".json")
+
+	report, err := Run(context.Background(), server.Client(), layout, Options{})
+	assert.NotEqual(t, nil, err, "Failure not reported")
+	assert.Equal(t, 3, report.Failed, "Bad failures")
+	assert.Equal(t, "the parent page wasn't created", report.Changes[1].Err.Error(), "Bad failure")
+	assert.Equal(t, "the page wasn't created", report.Changes[2].Err.Error(), "Bad failure")
+	assert.Equal(t, 0, len(server.Pages()), "Subpage created without its parent")
+}
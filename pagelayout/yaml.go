@@ -0,0 +1,22 @@
+//go:build yaml
+// +build yaml
+
+package pagelayout
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	decoders[".yaml"] = decodeYAML
+	decoders[".yml"] = decodeYAML
+}
+
+// decodeYAML unmarshals a YAML layout, refusing fields it doesn't know so a misspelt one isn't ignored
+func decodeYAML(data []byte, layout *Layout) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	return dec.Decode(layout)
+}
@@ -0,0 +1,30 @@
+//go:build yaml
+// +build yaml
+
+package pagelayout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse_yaml(t *testing.T) {
+	layout, err := Parse([]byte(`
+pages:
+  - name: Sales
+    subpages:
+      - name: Pipeline
+        locked: true
+        cards: []
+      - name: Forecast
+`), ".yaml")
+	assert.Equal(t, nil, err, "Bad error code")
+	pipeline, forecast := layout.Pages[0].Subpages[0], layout.Pages[0].Subpages[1]
+	assert.Equal(t, true, *pipeline.Locked, "Bad locked")
+	assert.Equal(t, true, pipeline.Cards != nil && len(pipeline.Cards) == 0, "Empty cards read as missing")
+	assert.Equal(t, true, forecast.Cards == nil && forecast.Locked == nil, "Missing fields read as set")
+
+	_, err = Parse([]byte("pages:\n  - name: Sales\n    lokced: true\n"), ".yml")
+	assert.NotEqual(t, nil, err, "Unknown field accepted")
+}
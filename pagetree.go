@@ -0,0 +1,331 @@
+package domo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// PageNode a page in a PageTree, with the pages below it
+type PageNode struct {
+	Page
+	Parent   *PageNode   `json:"-"`        // The page above, nil for a top level page
+	Subpages []*PageNode `json:"subpages"` // The pages directly below, in the order Domo lists them
+	Depth    int         `json:"depth"`    // 0 for a top level page
+}
+
+// Path the names of the pages from the top level down to this one, separated by " / "
+func (n *PageNode) Path() string {
+	if n.Parent == nil {
+		return n.Name
+	}
+	return n.Parent.Path() + " / " + n.Name
+}
+
+// SkipSubpages returned by a PageWalkFunc skips the pages below the one it was called with
+var SkipSubpages = errors.New("skip subpages")
+
+// PageWalkFunc is called for each page by Walk. An error other than SkipSubpages stops the walk.
+type PageWalkFunc func(node *PageNode) error
+
+// Walk calls fn for the page and then for every page below it, depth first
+func (n *PageNode) Walk(fn PageWalkFunc) error {
+	err := fn(n)
+	if err == SkipSubpages {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, sub := range n.Subpages {
+		if err = sub.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PageTree every page of an instance, in its hierarchy
+type PageTree struct {
+	Roots []*PageNode // The top level pages
+	nodes map[int]*PageNode
+}
+
+// Node the page with the id, nil if it isn't in the tree
+func (t *PageTree) Node(pageID int) *PageNode {
+	return t.nodes[pageID]
+}
+
+// Len the number of pages in the tree
+func (t *PageTree) Len() int {
+	return len(t.nodes)
+}
+
+// Walk calls fn for every page, each top level page followed by the pages below it, depth first
+func (t *PageTree) Walk(fn PageWalkFunc) error {
+	for _, root := range t.Roots {
+		if err := root.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tree Builds the full page hierarchy.
+// The page list only reaches one level down, so every page is retrieved to find the pages below it.
+// Definition
+// GET https://api.domo.com/v1/pages
+// GET https://api.domo.com/v1/pages/{PAGE_ID}
+// Returns
+// Returns the tree of every page.
+func (p *PageService) Tree(ctx context.Context) (tree *PageTree, err error) {
+	bodyBytes, err := p.client.sendJSON(ctx, "dashboard", "GET", fmt.Sprintf("%s/v1/pages", p.client.baseURL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list pages : %s", err)
+	}
+	top, err := bytesToPages(bodyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal pages %s", err)
+	}
+
+	tree = &PageTree{nodes: make(map[int]*PageNode)}
+	var order []int
+	var next []int
+	for _, page := range top {
+		next = append(next, page.ID)
+	}
+	for len(next) > 0 {
+		id := next[0]
+		next = next[1:]
+		if tree.nodes[id] != nil {
+			continue
+		}
+
+		page, err := p.retrieve(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		tree.nodes[id] = &PageNode{Page: page}
+		order = append(order, id)
+		for _, child := range page.Children {
+			next = append(next, child.ID)
+		}
+	}
+
+	for _, id := range order {
+		node := tree.nodes[id]
+		parent := tree.nodes[node.ParentID]
+		if parent == nil || parent == node {
+			tree.Roots = append(tree.Roots, node)
+			continue
+		}
+		node.Parent = parent
+		parent.Subpages = append(parent.Subpages, node)
+	}
+	tree.Walk(func(node *PageNode) error {
+		if node.Parent != nil {
+			node.Depth = node.Parent.Depth + 1
+		}
+		return nil
+	})
+	logger(fmt.Sprintf("[PageService] Tree : %d pages found", len(order)))
+	return tree, nil
+}
+
+// retrieve a page, with the pages directly below it
+func (p *PageService) retrieve(ctx context.Context, pageID int) (page Page, err error) {
+	bodyBytes, err := p.client.sendJSON(ctx, "dashboard", "GET", fmt.Sprintf("%s/v1/pages/%d", p.client.baseURL, pageID), nil)
+	if err != nil {
+		return page, fmt.Errorf("Failed to retrieve page %d : %s", pageID, err)
+	}
+	if page, err = bytesToPage(bodyBytes); err != nil {
+		err = fmt.Errorf("Unable to unmarshal page %d %s", pageID, err)
+	}
+	return
+}
+
+// VisibilityStep the change to one page of a VisibilityPlan
+type VisibilityStep struct {
+	PageID     int
+	Path       string     // The names of the pages down to this one
+	UserIDs    []int      // The users who gain or lose access
+	GroupIDs   []int      // The groups that gain or lose access
+	Visibility Visibility // Who can see the page afterwards
+	SideEffect bool       // Domo makes this change itself, as the page is above one that is granted
+	Done       bool
+	Err        error
+}
+
+func (s VisibilityStep) String() string {
+	note := ""
+	if s.SideEffect {
+		note = " (parent page, done by Domo)"
+	}
+	return fmt.Sprintf("%s (%d): %s%s", s.Path, s.PageID, describeWho(s.UserIDs, s.GroupIDs), note)
+}
+
+// describeWho lists users and groups as "user 7, group 9"
+func describeWho(userIDs []int, groupIDs []int) string {
+	var who []string
+	for _, id := range userIDs {
+		who = append(who, fmt.Sprintf("user %d", id))
+	}
+	for _, id := range groupIDs {
+		who = append(who, fmt.Sprintf("group %d", id))
+	}
+	return strings.Join(who, ", ")
+}
+
+// VisibilityPlan the pages a bulk grant or revoke changes, worked out before anything is changed
+type VisibilityPlan struct {
+	Grant   bool             // Whether access is given or taken away
+	Who     Visibility       // The users and groups
+	Steps   []VisibilityStep // The pages of the subtree that change, top down
+	Parents []VisibilityStep // The pages above the subtree that Domo also grants access to
+}
+
+// Print writes the pages the plan changes, the parent pages Domo changes as well, and how many there are
+func (plan *VisibilityPlan) Print(w io.Writer) {
+	verb := "revoke"
+	if plan.Grant {
+		verb = "grant"
+	}
+	for _, step := range append(append([]VisibilityStep(nil), plan.Parents...), plan.Steps...) {
+		status := ""
+		if step.Err != nil {
+			status = " failed: " + step.Err.Error()
+		}
+		fmt.Fprintf(w, "%s %s%s\n", verb, step, status)
+	}
+	fmt.Fprintf(w, "%d pages, %d parent pages\n", len(plan.Steps), len(plan.Parents))
+}
+
+// PlanVisibility Works out what giving (grant) or taking away access to a page and every page below it changes.
+// Domo gives whoever can see a subpage access to its parent pages too, and those pages are reported in Parents.
+// Revoking leaves the pages above the subtree alone.
+func (p *PageService) PlanVisibility(ctx context.Context, pageID int, who Visibility, grant bool) (plan *VisibilityPlan, err error) {
+	if len(who.UserIds) == 0 && len(who.GroupIds) == 0 {
+		return nil, errors.New("No users or groups to change the visibility of")
+	}
+	tree, err := p.Tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	root := tree.Node(pageID)
+	if root == nil {
+		return nil, &NotFoundError{Kind: "page", Field: "id", Value: fmt.Sprint(pageID)}
+	}
+
+	plan = &VisibilityPlan{Grant: grant, Who: who}
+	root.Walk(func(node *PageNode) error {
+		if step, changed := visibilityStep(node, who, grant); changed {
+			plan.Steps = append(plan.Steps, step)
+		}
+		return nil
+	})
+	if grant && len(plan.Steps) > 0 {
+		for parent := root.Parent; parent != nil; parent = parent.Parent {
+			if step, changed := visibilityStep(parent, who, true); changed {
+				step.SideEffect = true
+				plan.Parents = append([]VisibilityStep{step}, plan.Parents...)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// visibilityStep the change to the page's visibility, and whether there is one
+func visibilityStep(node *PageNode, who Visibility, grant bool) (step VisibilityStep, changed bool) {
+	step = VisibilityStep{PageID: node.ID, Path: node.Path()}
+	step.Visibility.UserIds, step.UserIDs = changeIDs(node.Visibility.UserIds, who.UserIds, grant)
+	step.Visibility.GroupIds, step.GroupIDs = changeIDs(node.Visibility.GroupIds, who.GroupIds, grant)
+	return step, len(step.UserIDs) > 0 || len(step.GroupIDs) > 0
+}
+
+// changeIDs adds or removes ids from current, returning the result and the ids that made a difference
+func changeIDs(current []int, ids []int, add bool) (result []int, changed []int) {
+	has := make(map[int]bool)
+	for _, id := range current {
+		has[id] = true
+	}
+	for _, id := range ids {
+		if has[id] != add {
+			changed = append(changed, id)
+			has[id] = add
+		}
+	}
+	result = []int{}
+	for _, id := range current {
+		if has[id] {
+			result = append(result, id)
+		}
+	}
+	if add {
+		result = append(result, changed...)
+	}
+	sort.Ints(changed)
+	return
+}
+
+// ApplyVisibility Makes the changes of a plan, one page at a time, carrying on past failures.
+// Each page is read again just before it is changed and only the plan's users and groups are granted
+// or revoked, so changes made to its visibility since planning are kept. The parent pages are read
+// afterwards, and are only Done if Domo has given every user and group access to them.
+// Returns
+// Returns an error counting the pages that failed and the parent pages still without access, whose steps hold the reason
+func (p *PageService) ApplyVisibility(ctx context.Context, plan *VisibilityPlan) error {
+	failed := 0
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		step.Err = p.changeVisibility(ctx, step, plan.Grant)
+		step.Done = step.Err == nil
+		if step.Err != nil {
+			failed++
+		}
+	}
+
+	missing := 0
+	for i := range plan.Parents {
+		step := &plan.Parents[i]
+		page, err := p.retrieve(ctx, step.PageID)
+		if err == nil {
+			lacking, _ := visibilityStep(&PageNode{Page: page}, Visibility{UserIds: step.UserIDs, GroupIds: step.GroupIDs}, true)
+			if len(lacking.UserIDs) > 0 || len(lacking.GroupIDs) > 0 {
+				err = fmt.Errorf("Domo has not given access to %s", describeWho(lacking.UserIDs, lacking.GroupIDs))
+			}
+		}
+		step.Done, step.Err = err == nil, err
+		if err != nil {
+			missing++
+		}
+	}
+
+	switch {
+	case missing > 0:
+		return fmt.Errorf("Unable to change the visibility of %d of %d pages, and %d of %d parent pages are without access",
+			failed, len(plan.Steps), missing, len(plan.Parents))
+	case failed > 0:
+		return fmt.Errorf("Unable to change the visibility of %d of %d pages", failed, len(plan.Steps))
+	}
+	return nil
+}
+
+// changeVisibility reads the step's page and grants or revokes the step's users and groups, leaving
+// the rest of its visibility as it is now. Nothing is sent if the page already has the change.
+func (p *PageService) changeVisibility(ctx context.Context, step *VisibilityStep, grant bool) error {
+	page, err := p.retrieve(ctx, step.PageID)
+	if err != nil {
+		return err
+	}
+	fresh, changed := visibilityStep(&PageNode{Page: page}, Visibility{UserIds: step.UserIDs, GroupIds: step.GroupIDs}, grant)
+	step.Visibility = fresh.Visibility
+	if !changed {
+		return nil
+	}
+	visibility := fresh.Visibility
+	_, err = p.Update(ctx, step.PageID, PagePatch{Visibility: &visibility})
+	return err
+}
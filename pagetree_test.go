@@ -0,0 +1,106 @@
+package domo
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pageTreeTestDoer Home > Sales > EMEA > UK, and Finance, where group 9 can already see UK and Finance
+func pageTreeTestDoer() *routeDoer {
+	return &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"GET /v1/pages":    {responseCode: 200, response: `[{"id": 1, "name": "Home", "children": [{"id": 2, "name": "Sales"}]}, {"id": 5, "name": "Finance", "children": []}]`},
+		"GET /v1/pages/1":  {responseCode: 200, response: `{"id": 1, "name": "Home", "visibility": {"userIds": [7], "groupIds": []}, "children": [{"id": 2, "name": "Sales"}]}`},
+		"GET /v1/pages/2":  {responseCode: 200, response: `{"id": 2, "name": "Sales", "parentId": 1, "visibility": {"userIds": [7], "groupIds": []}, "children": [{"id": 3, "name": "EMEA"}]}`},
+		"GET /v1/pages/3":  {responseCode: 200, response: `{"id": 3, "name": "EMEA", "parentId": 2, "visibility": {"userIds": [7], "groupIds": []}, "children": [{"id": 4, "name": "UK"}]}`},
+		"GET /v1/pages/4":  {responseCode: 200, response: `{"id": 4, "name": "UK", "parentId": 3, "visibility": {"userIds": [7], "groupIds": [9]}, "children": []}`},
+		"GET /v1/pages/5":  {responseCode: 200, response: `{"id": 5, "name": "Finance", "visibility": {"userIds": [], "groupIds": [9]}, "children": []}`},
+		"PUT /v1/pages/3":  {responseCode: 200, response: `{"id": 3, "name": "EMEA"}`},
+		"PUT /v1/pages/4":  {responseCode: 200, response: `{"id": 4, "name": "UK"}`},
+	}}
+}
+
+func TestPageService_Tree(t *testing.T) {
+	p := CreateTestClient(pageTreeTestDoer())
+
+	tree, err := p.Page.Tree(context.Background())
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 5, tree.Len(), "Bad page count")
+	assert.Equal(t, 2, len(tree.Roots), "Bad top level pages")
+	uk := tree.Node(4)
+	assert.Equal(t, "Home / Sales / EMEA / UK", uk.Path(), "Bad path")
+	assert.Equal(t, 3, uk.Depth, "Bad depth")
+
+	var walked []int
+	tree.Walk(func(node *PageNode) error {
+		walked = append(walked, node.ID)
+		if node.Name == "EMEA" {
+			return SkipSubpages
+		}
+		return nil
+	})
+	assert.Equal(t, []int{1, 2, 3, 5}, walked, "Bad walk")
+}
+
+func TestPageService_PlanVisibility(t *testing.T) {
+	doer := pageTreeTestDoer()
+	p := CreateTestClient(doer)
+
+	plan, err := p.Page.PlanVisibility(context.Background(), 3, Visibility{GroupIds: []int{9}}, true)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 1, len(plan.Steps), "Page that already has access changed")
+	assert.Equal(t, 3, plan.Steps[0].PageID, "Bad step")
+	assert.Equal(t, []int{1, 2}, []int{plan.Parents[0].PageID, plan.Parents[1].PageID}, "Bad parent pages")
+
+	var out bytes.Buffer
+	plan.Print(&out)
+	assert.Equal(t, "grant Home (1): group 9 (parent page, done by Domo)\n"+
+		"grant Home / Sales (2): group 9 (parent page, done by Domo)\n"+
+		"grant Home / Sales / EMEA (3): group 9\n"+
+		"1 pages, 2 parent pages\n", out.String(), "Bad plan output")
+	assert.Equal(t, 0, doer.count("PUT *"), "Planning changed something")
+
+	// The pages are read back as they were, so Domo seems not to have granted the parents
+	err = p.Page.ApplyVisibility(context.Background(), plan)
+	assert.NotEqual(t, nil, err, "Parent pages without access not reported")
+	assert.Equal(t, true, plan.Steps[0].Done, "Bad grant")
+	assert.Equal(t, `{"visibility":{"userIds":[7],"groupIds":[9]}}`, doer.bodies["PUT /v1/pages/3"], "Bad grant")
+	assert.Equal(t, false, plan.Parents[0].Done, "Parent page done without checking")
+	assert.Equal(t, "Domo has not given access to group 9", plan.Parents[0].Err.Error(), "Bad parent error")
+
+	plan, err = p.Page.PlanVisibility(context.Background(), 2, Visibility{UserIds: []int{7}, GroupIds: []int{9}}, false)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 3, len(plan.Steps), "Bad revoke")
+	assert.Equal(t, 0, len(plan.Parents), "Revoke changed parent pages")
+	assert.Equal(t, []int{9}, plan.Steps[2].GroupIDs, "Bad revoke of UK")
+	err = p.Page.ApplyVisibility(context.Background(), plan)
+	assert.NotEqual(t, nil, err, "Failure not reported")
+	assert.NotEqual(t, nil, plan.Steps[0].Err, "Bad failed step")
+	assert.Equal(t, true, plan.Steps[2].Done, "Stopped at the first failure")
+	assert.Equal(t, `{"visibility":{"userIds":[],"groupIds":[]}}`, doer.bodies["PUT /v1/pages/4"], "Bad revoke body")
+
+	_, err = p.Page.PlanVisibility(context.Background(), 99, Visibility{UserIds: []int{7}}, true)
+	assert.NotEqual(t, nil, err, "Missing page accepted")
+}
+
+func TestPageService_ApplyVisibility_changed(t *testing.T) {
+	doer := pageTreeTestDoer()
+	p := CreateTestClient(doer)
+
+	plan, err := p.Page.PlanVisibility(context.Background(), 4, Visibility{UserIds: []int{8}}, true)
+	assert.Equal(t, nil, err, "Bad error code")
+	// UK gains user 9 and loses group 9 after planning
+	doer.routes["GET /v1/pages/4"] = testDoer{responseCode: 200, response: `{"id": 4, "name": "UK", "parentId": 3, "visibility": {"userIds": [7, 9], "groupIds": []}, "children": []}`}
+	p.Page.ApplyVisibility(context.Background(), plan)
+	assert.Equal(t, `{"visibility":{"userIds":[7,9,8],"groupIds":[]}}`, doer.bodies["PUT /v1/pages/4"], "Change made since planning lost")
+
+	plan, err = p.Page.PlanVisibility(context.Background(), 4, Visibility{UserIds: []int{9}}, false)
+	assert.Equal(t, nil, err, "Bad error code")
+	doer.routes["GET /v1/pages/4"] = testDoer{responseCode: 200, response: `{"id": 4, "name": "UK", "parentId": 3, "visibility": {"userIds": [7], "groupIds": []}, "children": []}`}
+	assert.Equal(t, nil, p.Page.ApplyVisibility(context.Background(), plan), "Bad error code")
+	assert.Equal(t, 1, doer.count("PUT /v1/pages/4"), "Revoked again after someone else revoked it")
+	assert.Equal(t, true, plan.Steps[0].Done, "Bad step")
+}
@@ -0,0 +1,117 @@
+// Package prometheus counts the requests, token refreshes and uploads a domo.Client makes as Prometheus metrics.
+//
+//	metrics := prometheus.NewMetrics("domo")
+//	registry.MustRegister(metrics)
+//	client.SetMetrics(metrics)
+package prometheus
+
+import (
+	"strconv"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics a domo.Metrics, and a prometheus.Collector, holding:
+//
+//	<namespace>_requests_total{service,method,status}           requests by status class, 2xx, 4xx, error...
+//	<namespace>_request_duration_seconds{service,method}        latency of requests
+//	<namespace>_token_refreshes_total{scope}                    access tokens asked for
+//	<namespace>_token_refresh_failures_total{scope}             access tokens that couldn't be had
+//	<namespace>_part_retries_total{stream}                      part uploads tried again
+//	<namespace>_stream_parts_uploaded_total{stream}             parts accepted
+//	<namespace>_stream_rows_uploaded_total{stream}              rows in accepted parts
+//	<namespace>_stream_bytes_uploaded_total{stream}             bytes in accepted parts
+//	<namespace>_stream_last_commit_timestamp_seconds{stream}    when the stream last committed, for staleness alerts
+type Metrics struct {
+	requests        *prom.CounterVec
+	latency         *prom.HistogramVec
+	refreshes       *prom.CounterVec
+	refreshFailures *prom.CounterVec
+	retries         *prom.CounterVec
+	parts           *prom.CounterVec
+	rows            *prom.CounterVec
+	bytes           *prom.CounterVec
+	lastCommit      *prom.GaugeVec
+}
+
+// NewMetrics creates the metrics with names starting namespace_. They count nothing until
+// the Metrics is passed to Client.SetMetrics, and aren't exported until it is registered.
+func NewMetrics(namespace string) *Metrics {
+	counter := func(name string, help string, labels ...string) *prom.CounterVec {
+		return prom.NewCounterVec(prom.CounterOpts{Namespace: namespace, Name: name, Help: help}, labels)
+	}
+
+	return &Metrics{
+		requests: counter("requests_total", "Requests made to the Domo API.", "service", "method", "status"),
+		latency: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time taken by requests to the Domo API.",
+			Buckets:   prom.DefBuckets,
+		}, []string{"service", "method"}),
+		refreshes:       counter("token_refreshes_total", "Access tokens asked for.", "scope"),
+		refreshFailures: counter("token_refresh_failures_total", "Access tokens that could not be had.", "scope"),
+		retries:         counter("part_retries_total", "Stream part uploads tried again.", "stream"),
+		parts:           counter("stream_parts_uploaded_total", "Stream parts accepted.", "stream"),
+		rows:            counter("stream_rows_uploaded_total", "Rows in accepted stream parts.", "stream"),
+		bytes:           counter("stream_bytes_uploaded_total", "Bytes in accepted stream parts.", "stream"),
+		lastCommit: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      "stream_last_commit_timestamp_seconds",
+			Help:      "Unix time of the last committed stream execution.",
+		}, []string{"stream"}),
+	}
+}
+
+// collectors every metric, in the order they are described
+func (m *Metrics) collectors() []prom.Collector {
+	return []prom.Collector{m.requests, m.latency, m.refreshes, m.refreshFailures, m.retries, m.parts, m.rows, m.bytes, m.lastCommit}
+}
+
+// Describe sends the description of every metric to ch
+func (m *Metrics) Describe(ch chan<- *prom.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect sends the value of every metric to ch
+func (m *Metrics) Collect(ch chan<- prom.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// ObserveRequest counts the request and records how long it took
+func (m *Metrics) ObserveRequest(service string, method string, statusCode int, elapsed time.Duration) {
+	m.requests.WithLabelValues(service, method, domo.StatusClass(statusCode)).Inc()
+	m.latency.WithLabelValues(service, method).Observe(elapsed.Seconds())
+}
+
+// ObserveTokenRefresh counts the refresh, and the failure if there was one
+func (m *Metrics) ObserveTokenRefresh(scope string, err error) {
+	m.refreshes.WithLabelValues(scope).Inc()
+	if err != nil {
+		m.refreshFailures.WithLabelValues(scope).Inc()
+	}
+}
+
+// ObserveRetry counts the retry
+func (m *Metrics) ObserveRetry(streamID int) {
+	m.retries.WithLabelValues(strconv.Itoa(streamID)).Inc()
+}
+
+// ObservePart counts the part, its rows and its bytes
+func (m *Metrics) ObservePart(streamID int, rows int, bytes int) {
+	stream := strconv.Itoa(streamID)
+	m.parts.WithLabelValues(stream).Inc()
+	m.rows.WithLabelValues(stream).Add(float64(rows))
+	m.bytes.WithLabelValues(stream).Add(float64(bytes))
+}
+
+// ObserveCommit records the time of the commit
+func (m *Metrics) ObserveCommit(streamID int) {
+	m.lastCommit.WithLabelValues(strconv.Itoa(streamID)).SetToCurrentTime()
+}
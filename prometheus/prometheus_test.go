@@ -0,0 +1,79 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics("domo")
+	registry := prom.NewRegistry()
+	assert.Equal(t, nil, registry.Register(metrics), "Bad register")
+
+	metrics.ObserveRequest("streams", "PUT", 503, time.Second)
+	metrics.ObserveRequest("streams", "PUT", 200, time.Second)
+	metrics.ObserveTokenRefresh("data", nil)
+	metrics.ObserveTokenRefresh("data", errors.New("401 Unauthorized"))
+	metrics.ObserveRetry(7)
+	metrics.ObservePart(7, 2, 8)
+	metrics.ObservePart(7, 3, 12)
+	metrics.ObserveCommit(7)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("streams", "PUT", "5xx")), "Bad 5xx count")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("streams", "PUT", "2xx")), "Bad 2xx count")
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.latency), "Bad latency series")
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.refreshes.WithLabelValues("data")), "Bad refresh count")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.refreshFailures.WithLabelValues("data")), "Bad refresh failure count")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.retries.WithLabelValues("7")), "Bad retry count")
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.parts.WithLabelValues("7")), "Bad part count")
+	assert.Equal(t, 5.0, testutil.ToFloat64(metrics.rows.WithLabelValues("7")), "Bad row count")
+	assert.Equal(t, 20.0, testutil.ToFloat64(metrics.bytes.WithLabelValues("7")), "Bad byte count")
+	assert.Equal(t, true, testutil.ToFloat64(metrics.lastCommit.WithLabelValues("7")) > 0, "Commit time not set")
+
+	families, err := registry.Gather()
+	assert.Equal(t, nil, err, "Bad gather")
+	assert.Equal(t, 9, len(families), "Bad metric count")
+	for _, family := range families {
+		assert.Equal(t, true, strings.HasPrefix(family.GetName(), "domo_"), "Bad namespace")
+	}
+}
+
+func TestMetrics_upload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/oauth/token":
+			fmt.Fprint(w, `{"access_token": "token", "expires_in": 3600}`)
+		case r.URL.Path == "/v1/streams/7/executions":
+			w.WriteHeader(201)
+			fmt.Fprint(w, `{"id": 4, "currentState": "ACTIVE"}`)
+		case strings.HasPrefix(r.URL.Path, "/v1/streams/7/executions/4/"):
+			fmt.Fprint(w, `{"id": 4, "currentState": "SUCCESS"}`)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("domo")
+	client := domo.New("<clientID>", "<secret>")
+	client.SetBaseURL(server.URL)
+	client.SetMetrics(metrics)
+
+	_, err := client.Stream.UploadParts(7, []string{"a,1\n", "b,2\nc,3\n"}, domo.UploadOptions{})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.refreshes.WithLabelValues("data")), "Bad refresh count")
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.parts.WithLabelValues("7")), "Bad part count")
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.rows.WithLabelValues("7")), "Bad row count")
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.requests.WithLabelValues("streams", "PUT", "2xx")), "Bad PUT count, two parts and the commit")
+	assert.Equal(t, true, testutil.ToFloat64(metrics.lastCommit.WithLabelValues("7")) > 0, "Commit not recorded")
+}
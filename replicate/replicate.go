@@ -0,0 +1,352 @@
+// Package replicate copies the result of a SQL query into a Domo stream.
+//
+// Any database/sql driver can be used. The Domo schema is inferred from the column types of the query,
+// the stream is found by name or created, and the rows are uploaded as CSV parts in one execution.
+// With a watermark column only the rows added since the last run are sent, and they are appended.
+package replicate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// Options what to replicate and where to
+type Options struct {
+	Query       string             // The SQL query, which takes the last watermark as its only argument when Watermark is set
+	Stream      string             // Name of the stream's DataSet, created if it doesn't exist
+	Description string             // Description given to a new DataSet
+	Watermark   string             // Optional column that only grows, for incremental replication
+	Start       string             // Watermark passed to the query before one has been saved
+	StateFile   string             // File that keeps the last watermark between runs
+	Upload      domo.UploadOptions // How the rows are split up and sent
+}
+
+// Result what a replication did
+type Result struct {
+	StreamID  int                // The stream replicated to
+	Created   bool               // Whether the stream was created by this run
+	Schema    domo.Schema        // The schema inferred from the query
+	Watermark string             // The highest watermark sent, or the previous one when there were no rows
+	Summary   domo.UploadSummary // The upload, empty when there were no rows
+}
+
+// state what is kept in the state file
+type state struct {
+	Watermark string    `json:"watermark"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Run queries db and uploads the rows to the stream named in opts.
+// Without a watermark the rows replace the DataSet, so a query that finds none leaves it empty.
+func Run(ctx context.Context, client *domo.Client, db *sql.DB, opts Options) (result Result, err error) {
+	if opts.Query == "" || opts.Stream == "" {
+		return result, errors.New("replicate needs a query and a stream name")
+	}
+
+	var args []interface{}
+	result.Watermark = opts.Start
+	if opts.Watermark != "" {
+		if result.Watermark, err = loadWatermark(opts.StateFile, opts.Start); err != nil {
+			return
+		}
+		args = append(args, result.Watermark)
+	}
+
+	rows, err := db.QueryContext(ctx, opts.Query, args...)
+	if err != nil {
+		return result, fmt.Errorf("Unable to run query %s", err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return result, fmt.Errorf("Unable to read column types %s", err)
+	}
+	result.Schema = InferSchema(columnTypes)
+
+	watermark := -1
+	if opts.Watermark != "" {
+		for i, column := range result.Schema.Columns {
+			if strings.EqualFold(column.Name, opts.Watermark) {
+				watermark = i
+			}
+		}
+		if watermark < 0 {
+			return result, fmt.Errorf("Watermark column '%s' is not in the query", opts.Watermark)
+		}
+	}
+
+	result.StreamID, result.Created, err = findOrCreate(client, opts, result.Schema)
+	if err != nil {
+		return
+	}
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil || opts.Watermark != "" {
+			return
+		}
+		// A replace with no rows still has to empty the DataSet
+		result.Summary, err = client.Stream.UploadReaderContext(ctx, result.StreamID, strings.NewReader(""), opts.Upload)
+		return
+	}
+
+	reader, writer := io.Pipe()
+	highest := make(chan interface{}, 1)
+	go func() {
+		high, werr := writeRows(rows, result.Schema, watermark, writer)
+		highest <- high
+		writer.CloseWithError(werr)
+	}()
+
+	result.Summary, err = client.Stream.UploadReaderContext(ctx, result.StreamID, reader, opts.Upload)
+	reader.CloseWithError(errors.New("upload finished"))
+	high := <-highest
+	if err != nil {
+		return
+	}
+
+	if opts.Watermark != "" && high != nil {
+		result.Watermark = formatWatermark(high, result.Schema.Columns[watermark].Type)
+		err = saveWatermark(opts.StateFile, result.Watermark)
+	}
+	return
+}
+
+// findOrCreate finds the stream whose DataSet has the name in opts, or creates it.
+// A stream found must have the update method the options call for: APPEND with a watermark, as
+// only new rows are sent, and REPLACE without one. Any other would lose or repeat rows.
+func findOrCreate(client *domo.Client, opts Options, schema domo.Schema) (streamID int, created bool, err error) {
+	updateMethod := domo.UpdateMethodReplace
+	if opts.Watermark != "" {
+		updateMethod = domo.UpdateMethodAppend
+	}
+
+	list, err := client.Stream.Get(opts.Stream)
+	if err != nil {
+		return
+	}
+	if list != nil {
+		for _, stream := range *list {
+			if stream.DataSet.Name != opts.Stream {
+				continue
+			}
+			if stream.UpdateMethod != updateMethod {
+				return 0, false, fmt.Errorf("Stream %d for '%s' has update method %s, replicating with these options needs %s", stream.ID, opts.Stream, stream.UpdateMethod, updateMethod)
+			}
+			return stream.ID, false, nil
+		}
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"dataSet": map[string]interface{}{
+			"name":        opts.Stream,
+			"description": opts.Description,
+			"rows":        0,
+			"schema":      schema,
+		},
+		"updateMethod": updateMethod,
+	})
+	if err != nil {
+		return
+	}
+
+	stream, err := client.Stream.Create(string(payload))
+	if err != nil {
+		return
+	}
+	if stream == nil || stream.ID == 0 {
+		return 0, false, fmt.Errorf("Failed to create stream '%s'", opts.Stream)
+	}
+	return stream.ID, true, nil
+}
+
+// InferSchema maps the column types of a query onto Domo column types
+func InferSchema(columnTypes []*sql.ColumnType) (schema domo.Schema) {
+	schema.Columns = make(domo.Columns, len(columnTypes))
+	for i, columnType := range columnTypes {
+		schema.Columns[i].Name = columnType.Name()
+		schema.Columns[i].Type = domoType(columnType)
+	}
+	return
+}
+
+// domoType the Domo type for a column, from its database type name or else the Go type it scans into
+func domoType(columnType *sql.ColumnType) string {
+	name := strings.ToUpper(columnType.DatabaseTypeName())
+	if i := strings.IndexAny(name, "( "); i > 0 {
+		name = name[:i]
+	}
+
+	if name != "" {
+		switch name {
+		case "INT", "INTEGER", "SMALLINT", "TINYINT", "MEDIUMINT", "BIGINT", "INT2", "INT4", "INT8", "SERIAL", "BIGSERIAL":
+			return domo.ColumnLong
+		case "FLOAT", "DOUBLE", "REAL", "FLOAT4", "FLOAT8":
+			return domo.ColumnDouble
+		case "DECIMAL", "NUMERIC", "MONEY":
+			return domo.ColumnDecimal
+		case "DATE":
+			return domo.ColumnDate
+		case "DATETIME", "TIMESTAMP", "TIMESTAMPTZ":
+			return domo.ColumnDateTime
+		default:
+			return domo.ColumnString
+		}
+	}
+
+	scanType := columnType.ScanType()
+	if scanType == nil {
+		return domo.ColumnString
+	}
+	switch scanType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return domo.ColumnLong
+	case reflect.Float32, reflect.Float64:
+		return domo.ColumnDouble
+	}
+	if scanType == reflect.TypeOf(time.Time{}) {
+		return domo.ColumnDateTime
+	}
+	return domo.ColumnString
+}
+
+// writeRows writes the current row and the rest as CSV, returning the highest watermark seen
+func writeRows(rows *sql.Rows, schema domo.Schema, watermark int, w io.Writer) (highest interface{}, err error) {
+	out := csv.NewWriter(w)
+	values := make([]interface{}, len(schema.Columns))
+	pointers := make([]interface{}, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	record := make([]string, len(values))
+
+	for {
+		if err = rows.Scan(pointers...); err != nil {
+			return highest, fmt.Errorf("Unable to scan row %s", err)
+		}
+		for i, value := range values {
+			record[i] = formatValue(value, schema.Columns[i].Type)
+		}
+		if watermark >= 0 && values[watermark] != nil && greater(values[watermark], highest) {
+			highest = values[watermark]
+		}
+		if err = out.Write(record); err != nil {
+			return
+		}
+		if !rows.Next() {
+			break
+		}
+	}
+
+	out.Flush()
+	if err = out.Error(); err != nil {
+		return
+	}
+	return highest, rows.Err()
+}
+
+// formatValue the CSV text Domo expects for a value of a column type
+func formatValue(value interface{}, columnType string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		if columnType == domo.ColumnDate {
+			return v.Format("2006-01-02")
+		}
+		return v.UTC().Format("2006-01-02T15:04:05Z")
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatWatermark the text saved for a watermark, which the next run passes back to the query.
+// Times keep every fraction of a second, so rows a moment after the last one sent aren't skipped.
+func formatWatermark(value interface{}, columnType string) string {
+	if v, ok := value.(time.Time); ok && columnType != domo.ColumnDate {
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return formatValue(value, columnType)
+}
+
+// greater whether a watermark value is past the highest one seen so far.
+// Text that holds numbers, as drivers return for DECIMAL columns, is compared as numbers.
+func greater(value interface{}, highest interface{}) bool {
+	if highest == nil {
+		return true
+	}
+	switch v := value.(type) {
+	case int64:
+		h, ok := highest.(int64)
+		return ok && v > h
+	case float64:
+		h, ok := highest.(float64)
+		return ok && v > h
+	case time.Time:
+		h, ok := highest.(time.Time)
+		return ok && v.After(h)
+	}
+
+	a, b := formatValue(value, ""), formatValue(highest, "")
+	af, aerr := strconv.ParseFloat(a, 64)
+	bf, berr := strconv.ParseFloat(b, 64)
+	if aerr == nil && berr == nil {
+		return af > bf
+	}
+	return a > b
+}
+
+// loadWatermark the watermark saved by the last run, or start
+func loadWatermark(path string, start string) (string, error) {
+	if path == "" {
+		return start, nil
+	}
+	bodyBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return start, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Unable to read state %s", err)
+	}
+
+	var saved state
+	if err = json.Unmarshal(bodyBytes, &saved); err != nil {
+		return "", fmt.Errorf("Unable to unmarshal state %s : %s", path, err)
+	}
+	return saved.Watermark, nil
+}
+
+// saveWatermark records the watermark for the next run
+func saveWatermark(path string, watermark string) error {
+	if path == "" {
+		return nil
+	}
+	bodyBytes, err := json.MarshalIndent(state{Watermark: watermark, UpdatedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = domo.ReplaceFile(path, bodyBytes); err != nil {
+		return fmt.Errorf("Unable to save state %s", err)
+	}
+	return nil
+}
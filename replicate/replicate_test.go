@@ -0,0 +1,230 @@
+package replicate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDriver serves the rows of the table whose id is greater than the query's argument
+type fakeDriver struct{}
+
+type fakeConn struct{}
+
+type fakeStmt struct {
+	query string
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+var fakeColumns = []string{"id", "name", "amount", "created"}
+var fakeTypes = []string{"BIGINT", "VARCHAR(20)", "NUMERIC(10,2)", "TIMESTAMP"}
+var fakeTable = [][]driver.Value{
+	{int64(1), "alpha", []byte("1.50"), time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)},
+	{int64(2), "beta, with comma", nil, time.Date(2020, 3, 2, 10, 0, 0, 0, time.UTC)},
+	{int64(3), "gamma", []byte("3.25"), time.Date(2020, 3, 3, 10, 0, 0, 500000000, time.UTC)},
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error)   { return fakeConn{}, nil }
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, fmt.Errorf("no transactions") }
+func (fakeStmt) Close() error                              { return nil }
+func (fakeStmt) NumInput() int                             { return -1 }
+func (fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("read only")
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	var after int64
+	if len(args) > 0 {
+		fmt.Sscan(fmt.Sprint(args[0]), &after)
+	}
+	rows := &fakeRows{}
+	for _, row := range fakeTable {
+		if row[0].(int64) > after && !strings.Contains(s.query, "WHERE false") {
+			rows.rows = append(rows.rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func (r *fakeRows) Columns() []string                       { return fakeColumns }
+func (r *fakeRows) ColumnTypeDatabaseTypeName(i int) string { return fakeTypes[i] }
+func (r *fakeRows) Close() error                            { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("replicate-fake", fakeDriver{})
+}
+
+// fakeDomo a stream API that knows one stream, with the update method in existing if it is set,
+// and records the parts uploaded
+type fakeDomo struct {
+	sync.Mutex
+	existing string
+	created  string
+	parts    []string
+	commits  int
+}
+
+func (f *fakeDomo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch {
+	case r.URL.Path == "/oauth/token":
+		fmt.Fprint(w, `{"access_token": "token", "expires_in": 3600}`)
+	case r.URL.Path == "/v1/streams/search":
+		if f.existing != "" {
+			fmt.Fprintf(w, `[{"id": 9, "dataSet": {"name": "orders"}, "updateMethod": "%s"}]`, f.existing)
+		} else {
+			fmt.Fprint(w, `[]`)
+		}
+	case r.Method == "POST" && r.URL.Path == "/v1/streams":
+		f.created = string(body)
+		w.WriteHeader(201)
+		fmt.Fprint(w, `{"id": 9}`)
+	case r.Method == "POST" && r.URL.Path == "/v1/streams/9/executions":
+		w.WriteHeader(201)
+		fmt.Fprint(w, `{"id": 1, "currentState": "ACTIVE"}`)
+	case strings.HasPrefix(r.URL.Path, "/v1/streams/9/executions/1/part/"):
+		f.parts = append(f.parts, string(body))
+		fmt.Fprint(w, `{"id": 1}`)
+	case r.URL.Path == "/v1/streams/9/executions/1/commit":
+		f.commits++
+		fmt.Fprint(w, `{"id": 1, "currentState": "SUCCESS"}`)
+	default:
+		w.WriteHeader(404)
+		fmt.Fprint(w, `{"status": 404, "statusReason": "Not Found"}`)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replicate")
+	assert.Equal(t, nil, err, "Bad temp dir")
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name          string
+		existing      string
+		opts          Options
+		wantErr       bool
+		wantCreated   bool
+		wantMethod    string
+		wantRows      string
+		wantCommits   int
+		wantWatermark string
+	}{
+		{
+			name:        "full copy creates the stream",
+			opts:        Options{Query: "SELECT * FROM orders", Stream: "orders", Upload: domo.UploadOptions{RowsPerPart: 2, Parallel: 2}},
+			wantCreated: true,
+			wantMethod:  `"updateMethod":"REPLACE"`,
+			wantRows:    "1,alpha,1.50,2020-03-01T10:00:00Z\n2,\"beta, with comma\",,2020-03-02T10:00:00Z\n3,gamma,3.25,2020-03-03T10:00:00Z\n",
+			wantCommits: 1,
+		},
+		{
+			name:        "full copy of no rows empties the stream",
+			existing:    "REPLACE",
+			opts:        Options{Query: "SELECT * FROM orders WHERE false", Stream: "orders"},
+			wantCommits: 1,
+		},
+		{
+			name:          "incremental copy to an existing stream",
+			existing:      "APPEND",
+			opts:          Options{Query: "SELECT * FROM orders WHERE id > ?", Stream: "orders", Watermark: "id", Start: "1", StateFile: filepath.Join(dir, "state.json")},
+			wantRows:      "2,\"beta, with comma\",,2020-03-02T10:00:00Z\n3,gamma,3.25,2020-03-03T10:00:00Z\n",
+			wantCommits:   1,
+			wantWatermark: "3",
+		},
+		{
+			name:          "nothing new since the saved watermark",
+			existing:      "APPEND",
+			opts:          Options{Query: "SELECT * FROM orders WHERE id > ?", Stream: "orders", Watermark: "id", Start: "1", StateFile: filepath.Join(dir, "state.json")},
+			wantWatermark: "3",
+		},
+		{
+			name:          "time watermark keeps fractions of a second",
+			existing:      "APPEND",
+			opts:          Options{Query: "SELECT * FROM orders WHERE created > ?", Stream: "orders", Watermark: "created", StateFile: filepath.Join(dir, "created.json")},
+			wantRows:      "1,alpha,1.50,2020-03-01T10:00:00Z\n2,\"beta, with comma\",,2020-03-02T10:00:00Z\n3,gamma,3.25,2020-03-03T10:00:00Z\n",
+			wantCommits:   1,
+			wantWatermark: "2020-03-03T10:00:00.5Z",
+		},
+		{
+			name:     "incremental copy to a stream that replaces",
+			existing: "REPLACE",
+			opts:     Options{Query: "SELECT * FROM orders WHERE id > ?", Stream: "orders", Watermark: "id", Start: "1", StateFile: filepath.Join(dir, "replace.json")},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeDomo{existing: tt.existing}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			client := domo.New("<clientID>", "<secret>")
+			client.SetBaseURL(server.URL)
+			db, _ := sql.Open("replicate-fake", "")
+			defer db.Close()
+
+			result, err := Run(context.Background(), client, db, tt.opts)
+			assert.Equal(t, tt.wantErr, err != nil, "Bad error code")
+			if tt.wantErr {
+				assert.Equal(t, 0, len(fake.parts), "Rows sent to the wrong kind of stream")
+				return
+			}
+			assert.Equal(t, 9, result.StreamID, "Bad stream")
+			assert.Equal(t, tt.wantCreated, result.Created, "Bad created")
+			assert.Equal(t, true, strings.Contains(fake.created, tt.wantMethod), "Bad update method")
+			got := strings.SplitAfter(strings.Join(fake.parts, ""), "\n")
+			sort.Strings(got)
+			assert.Equal(t, tt.wantRows, strings.Join(got, ""), "Bad rows")
+			assert.Equal(t, tt.wantCommits, fake.commits, "Bad commit count")
+			assert.Equal(t, tt.wantWatermark, result.Watermark, "Bad watermark")
+		})
+	}
+}
+
+func TestInferSchema(t *testing.T) {
+	db, _ := sql.Open("replicate-fake", "")
+	defer db.Close()
+	rows, err := db.Query("SELECT * FROM orders")
+	assert.Equal(t, nil, err, "Bad query")
+	defer rows.Close()
+	columnTypes, _ := rows.ColumnTypes()
+
+	schema := InferSchema(columnTypes)
+	var got []string
+	for _, column := range schema.Columns {
+		got = append(got, column.Name+" "+column.Type)
+	}
+	assert.Equal(t, []string{"id LONG", "name STRING", "amount DECIMAL", "created DATETIME"}, got, "Bad schema")
+}
@@ -0,0 +1,130 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RoleParallel the number of users Assign changes at the same time
+const RoleParallel = 4
+
+// Role the name of a role a user has
+type Role string
+
+// The default roles of a Domo instance. Custom roles are named by id, see RoleService.
+const (
+	RoleAdmin       Role = "Admin"
+	RolePrivileged  Role = "Privileged"
+	RoleParticipant Role = "Participant"
+)
+
+// Valid whether the role is one of the defaults
+func (r Role) Valid() bool {
+	return CheckRole(string(r))
+}
+
+// RoleService Role API service
+type RoleService service
+
+// List Get a list of all roles, default and custom, in your Domo instance.
+// Definition
+// GET https://api.domo.com/v1/roles
+// Returns
+// Returns every role with the number of users that have it.
+func (r *RoleService) List(ctx context.Context) (roles []RoleDetail, err error) {
+	r.client.getAccessToken("user")
+	url := fmt.Sprintf("%s/v1/roles", r.client.baseURL)
+	bodyBytes, statusCode, err := r.client.genericRequestContext(ctx, url, "GET", nil, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get roles from dom API %s", err)
+	}
+
+	if statusCode != 200 {
+		message, _ := bytesToErrorMessage(bodyBytes)
+		return nil, fmt.Errorf("Failed to list roles : %d %s", statusCode, message.StatusReason)
+	}
+
+	err = json.Unmarshal(bodyBytes, &roles)
+	if err != nil {
+		err = fmt.Errorf("Failed Unmarshal Roles %s JSON string : %s", err, bodyBytes)
+	}
+	return
+}
+
+// Find locates a role by name, ignoring case, from your Domo instance.
+// Returns
+// Returns the role, or an error if there is no role of that name
+func (r *RoleService) Find(ctx context.Context, name Role) (role RoleDetail, err error) {
+	roles, err := r.List(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, role := range roles {
+		if strings.EqualFold(string(role.Name), string(name)) {
+			return role, nil
+		}
+	}
+	return role, fmt.Errorf("No role named %s", name)
+}
+
+// Assign Gives each of the users the role with id roleID, which may be a default or a custom role.
+// Domo has no bulk endpoint, so RoleParallel users are changed at a time, each with a full user
+// update, and every user is tried even when some fail.
+// Definition
+// GET and PUT https://api.domo.com/v1/users/{id} for each user
+// Returns
+// Returns the updated users, in the order given, and an error naming the users that couldn't be changed.
+func (r *RoleService) Assign(ctx context.Context, roleID int, userIDs []int) (users []User, err error) {
+	if roleID < 1 {
+		return nil, fmt.Errorf("%d is not a valid role id", roleID)
+	}
+
+	updated := make([]User, len(userIDs))
+	errs := make([]error, len(userIDs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < RoleParallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				updated[i], errs[i] = r.client.User.Update(ctx, userIDs[i], UserPatch{RoleID: Int(roleID)})
+			}
+		}()
+	}
+	for i := range userIDs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	failed := make(map[int]error)
+	for i, uerr := range errs {
+		if uerr != nil {
+			failed[userIDs[i]] = uerr
+			continue
+		}
+		users = append(users, updated[i])
+	}
+
+	if len(failed) > 0 {
+		ids := make([]int, 0, len(failed))
+		for id := range failed {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		var reasons []string
+		for _, id := range ids {
+			reasons = append(reasons, fmt.Sprintf("%d (%s)", id, failed[id]))
+		}
+		err = fmt.Errorf("Failed to give role %d to %d of %d users : %s", roleID, len(failed), len(userIDs), strings.Join(reasons, ", "))
+	}
+	logger(fmt.Sprintf("[RoleService] Assign : role %d given to %d users", roleID, len(users)))
+	return
+}
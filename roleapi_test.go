@@ -0,0 +1,80 @@
+package domo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRole_Valid(t *testing.T) {
+	assert.Equal(t, true, RoleAdmin.Valid(), "Bad Admin")
+	assert.Equal(t, true, RoleParticipant.Valid(), "Bad Participant")
+	assert.Equal(t, false, Role("Boss").Valid(), "Bad Boss")
+}
+
+func TestRoleService_List(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"GET /v1/roles":    {responseCode: 200, response: `[{"id": 1, "name": "Admin", "userCount": 2}, {"id": 810, "name": "Auditor", "description": "Read only", "userCount": 0}]`},
+	}}
+	d := CreateTestClient(doer)
+
+	roles, err := d.Role.List(context.Background())
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, []RoleDetail{
+		{ID: 1, Name: RoleAdmin, UserCount: 2},
+		{ID: 810, Name: "Auditor", Description: "Read only"},
+	}, roles, "Bad roles")
+
+	role, err := d.Role.Find(context.Background(), "auditor")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 810, role.ID, "Bad role")
+
+	_, err = d.Role.Find(context.Background(), "Boss")
+	assert.NotEqual(t, nil, err, "Found a missing role")
+}
+
+func TestRoleService_Assign(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"GET /v1/users/31": {responseCode: 200, response: `{"id": 31, "name": "Dan", "email": "dan@example.com", "roleId": 3, "role": "Participant"}`},
+		"GET /v1/users/33": {responseCode: 200, response: `{"id": 33, "name": "Eve", "email": "eve@example.com", "roleId": 3, "role": "Participant"}`},
+		"PUT /v1/users/31": {responseCode: 200, response: `{"id": 31, "name": "Dan", "roleId": 810, "role": "Auditor"}`},
+		"PUT /v1/users/33": {responseCode: 200, response: `{"id": 33, "name": "Eve", "roleId": 810, "role": "Auditor"}`},
+		"PUT /v1/users/32": {responseCode: 404, response: `{"status": 404, "statusReason": "Not Found"}`},
+	}}
+	d := CreateTestClient(doer)
+
+	users, err := d.Role.Assign(context.Background(), 810, []int{31, 32, 33})
+	assert.NotEqual(t, nil, err, "Missing user not reported")
+	assert.Equal(t, 2, len(users), "Bad user count")
+	assert.Equal(t, 31, users[0].ID, "Bad order")
+	assert.Equal(t, 33, users[1].ID, "Bad order")
+	assert.Equal(t, Role("Auditor"), users[1].Role, "Bad role")
+	assert.Equal(t, 0, doer.count("PUT /v1/users/32"), "Missing user sent an update")
+	assert.Equal(t, `{"name":"Eve","email":"eve@example.com","roleId":810,"title":"","alternateEmail":"","phone":"","location":"","employeeNumber":0}`,
+		doer.bodies["PUT /v1/users/33"], "Bad update")
+
+	_, err = d.Role.Assign(context.Background(), 0, []int{31})
+	assert.NotEqual(t, nil, err, "Bad role id accepted")
+}
+
+func TestUserService_Create_roles(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"POST /v1/users":   {responseCode: 201, response: `{"id": 31, "name": "Ann", "role": "Admin"}`},
+	}}
+	d := CreateTestClient(doer)
+
+	user, err := d.User.Create(context.Background(), UserCreate{Name: "Ann", Email: "ann@example.com", Role: RoleAdmin})
+	assert.Equal(t, nil, err, "Admin refused")
+	assert.Equal(t, RoleAdmin, user.Role, "Bad role")
+
+	_, err = d.User.Create(context.Background(), UserCreate{Name: "Ann", Email: "ann@example.com", RoleID: 810})
+	assert.Equal(t, nil, err, "Custom role refused")
+	assert.Equal(t, `{"name":"Ann","email":"ann@example.com","roleId":810}`, doer.bodies["POST /v1/users"], "Bad body")
+
+	_, err = d.User.Create(context.Background(), UserCreate{Name: "Ann", Email: "ann@example.com"})
+	assert.NotEqual(t, nil, err, "Missing role accepted")
+}
@@ -2,6 +2,7 @@ package domo
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -32,7 +33,7 @@ func (s *StreamService) Retrieve(streamID int) (stream Stream, err error) {
 // if the Stream ID is related to a DataSet that has been deleted,
 // a subset of the Stream's information will be returned, including a deleted property, which will be true.
 func (s *StreamService) retrieve(streamID int) (data Stream, err error) {
-	url := fmt.Sprintf("%s/v1/streams/%d", baseURL, streamID)
+	url := fmt.Sprintf("%s/v1/streams/%d", s.client.baseURL, streamID)
 	bodyBytes, _, err := s.client.genericGET(url, nil)
 
 	if err != nil {
@@ -79,7 +80,7 @@ func (s *StreamService) Create(dataset string) (data *Stream, err error) {
 // the information that was provided when DataSet was created from the Stream created.
 func (s *StreamService) create(dataSet string) (data *Stream, err error) {
 
-	url := fmt.Sprintf("%s/v1/streams", baseURL)
+	url := fmt.Sprintf("%s/v1/streams", s.client.baseURL)
 
 	header := make(map[string]string)
 	header["Content-Type"] = "application/json"
@@ -108,13 +109,59 @@ func (s *StreamService) create(dataSet string) (data *Stream, err error) {
 	return
 }
 
-/* ------------  TODO ----------------*/
-// Delete
-// Updates the specified Stream’s metadata by providing values to parameters passed.
+// Update Updates the specified Stream’s update method, and the key columns used when it is UPSERT.
+// Key columns are only sent for UPSERT streams.
+//
+// Returns the updated Stream.
+func (s *StreamService) Update(streamID int, updateMethod string, keyColumns []string) (stream *Stream, err error) {
+	s.client.getAccessToken("data")
+	return s.update(streamID, updateMethod, keyColumns)
+}
+
+// update Updates the specified Stream’s metadata by providing values to parameters passed.
 // Definition
 // PATCH https://api.domo.com/v1/streams/{STREAM_ID}
 // Returns
 // Returns a full DataSet object of the Stream.
+func (s *StreamService) update(streamID int, updateMethod string, keyColumns []string) (data *Stream, err error) {
+	if !CheckUpdateMethod(updateMethod) {
+		return nil, fmt.Errorf("%s is not a valid update method, (available methods are: 'APPEND', 'REPLACE', 'UPSERT')", updateMethod)
+	}
+	if updateMethod == UpdateMethodUpsert && len(keyColumns) == 0 {
+		return nil, errors.New("UPSERT streams need at least one key column")
+	}
+	if updateMethod != UpdateMethodUpsert {
+		keyColumns = nil
+	}
+
+	payload, err := json.Marshal(streamRequest{UpdateMethod: updateMethod, KeyColumnNames: keyColumns})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to marshal stream update %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/streams/%d", s.client.baseURL, streamID)
+	header := map[string]string{"Content-Type": "application/json"}
+	bodyBytes, statusCode, err := s.client.genericPATCH(url, bytes.NewReader(payload), header)
+
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Unable to update stream from Domo API %s",
+			err,
+		)
+	}
+
+	if statusCode >= 300 {
+		message, _ := bytesToErrorMessage(bodyBytes)
+		return nil, fmt.Errorf("Failed to update stream %d : %d %s", streamID, statusCode, message.StatusReason)
+	}
+
+	err = json.Unmarshal(bodyBytes, &data)
+	if err != nil {
+		err = fmt.Errorf("Unable to unmarshal update response %s", err)
+	}
+
+	return
+}
 
 // Delete Deletes a Stream from your Domo instance. This does not a delete the associated DataSet.
 //
@@ -132,7 +179,7 @@ func (s *StreamService) Delete(streamID int) error {
 // Returns a Stream object and parameter of success or error based on whether the Stream ID being valid.
 func (s *StreamService) delete(streamID int) error {
 	var err error
-	url := fmt.Sprintf("%s/v1/streams/%d", baseURL, streamID)
+	url := fmt.Sprintf("%s/v1/streams/%d", s.client.baseURL, streamID)
 	statusCode, err := s.client.genericDELETE(url, nil)
 
 	if err != nil {
@@ -154,7 +201,34 @@ func (s *StreamService) delete(streamID int) error {
 // Returns all Stream objects that meet argument criteria from original request.
 func (s *StreamService) List(ownerID int) (streamlist string, err error) {
 	s.client.getAccessToken("data")
-	streamlist, err = s.list(ownerID)
+	bodyBytes, err := s.list(context.Background(), ownerID)
+	if err != nil {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	if err = json.Indent(buf, bodyBytes, "", "  "); err != nil {
+		err = fmt.Errorf("Unable to get list %s", err)
+	}
+	streamlist = buf.String()
+	return
+}
+
+// ListOwned Get the Streams whose DataSets are owned by a user.
+// Definition
+// GET https://api.domo.com/v1/streams/search?q=dataSource.owner.id:{OWNER_ID}
+// Returns
+// Returns the Stream objects, with their DataSets.
+func (s *StreamService) ListOwned(ctx context.Context, ownerID int) (streams StreamList, err error) {
+	s.client.getAccessToken("data")
+	bodyBytes, err := s.list(ctx, ownerID)
+	if err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(bodyBytes, &streams); err != nil {
+		err = fmt.Errorf("Unable to unmarshal streams %s", err)
+	}
 	return
 }
 
@@ -163,33 +237,60 @@ func (s *StreamService) List(ownerID int) (streamlist string, err error) {
 // GET https://api.domo.com/v1/streams
 // Returns
 // Returns all Stream objects that meet argument criteria from original request.
-func (s *StreamService) list(ownerID int) (streamlist string, err error) {
-	url := fmt.Sprintf("%s/v1/streams/search?q=dataSource.owner.id:%d&fields=all", baseURL, ownerID)
-	bodyBytes, _, err := s.client.genericGET(url, nil)
+func (s *StreamService) list(ctx context.Context, ownerID int) (bodyBytes []byte, err error) {
+	url := fmt.Sprintf("%s/v1/streams/search?q=dataSource.owner.id:%d&fields=all", s.client.baseURL, ownerID)
+	bodyBytes, statusCode, err := s.client.genericRequestContext(ctx, url, "GET", nil, nil)
 
 	if err != nil {
-		return streamlist, fmt.Errorf(
+		return bodyBytes, fmt.Errorf(
 			"Unable to list stream from Domo API %s",
 			err,
 		)
 	}
 
-	buf := new(bytes.Buffer)
-	streamlist = fmt.Sprintf("%s", json.Indent(buf, []byte(bodyBytes), "", "  "))
-
-	if err != nil {
-		err = fmt.Errorf("Unable to get list %s", err)
+	if statusCode >= 300 {
+		message, _ := bytesToErrorMessage(bodyBytes)
+		err = fmt.Errorf("Failed to list streams of owner %d : %d %s", ownerID, statusCode, message.StatusReason)
 	}
-
 	return
 }
 
-/* ------------  TODO ----------------*/
-// RetrieveStreamExecution Import data into a DataSet in your Domo instance. This request will replace the data currently in the DataSet.
+// RetrieveExecution Retrieves the details of an execution of a stream.
+//
+// Returns an Execution object, whose CurrentState is ACTIVE while it can still take parts.
+func (s *StreamService) RetrieveExecution(streamID int, executionID int) (execution Execution, err error) {
+	s.client.getAccessToken("data")
+	return s.retrieveStreamExecution(context.Background(), streamID, executionID)
+}
+
+// retrieveStreamExecution Retrieves the details of an execution of a stream.
 // Definition
 // GET https://api.domo.com/v1/streams/{STREAM_ID}/executions/{EXECUTION_ID}
 // Returns
 // Returns a subset fields of a Stream's object.
+func (s *StreamService) retrieveStreamExecution(ctx context.Context, streamID int, executionID int) (data Execution, err error) {
+	url := fmt.Sprintf("%s/v1/streams/%d/executions/%d", s.client.baseURL, streamID, executionID)
+	bodyBytes, statusCode, err := s.client.genericRequestContext(ctx, url, "GET", nil, nil)
+
+	if err != nil {
+		return data, fmt.Errorf(
+			"Unable to retrieve stream execution from Domo API %s",
+			err,
+		)
+	}
+
+	if statusCode != 200 {
+		message, _ := bytesToErrorMessage(bodyBytes)
+		return data, fmt.Errorf("%w stream %d execution %d", &statusError{statusCode: statusCode, reason: message.StatusReason}, streamID, executionID)
+	}
+
+	err = json.Unmarshal(bodyBytes, &data)
+	if err != nil {
+		err = fmt.Errorf("Unable to unmarshal stream execution %s", err)
+	}
+
+	return
+}
 
 // createStreamExecution When you’re ready to upload data to your DataSet via a Stream,
 // you first tell Domo that you’re ready to start sending data by creating an Execution.
@@ -197,10 +298,10 @@ func (s *StreamService) list(ownerID int) (streamlist string, err error) {
 // POST https://api.domo.com/v1/streams/{STREAM_ID}/executions
 // Returns
 // Returns a subset of the stream object.
-func (s *StreamService) createStreamExecution(streamID int) (data Execution, err error) {
+func (s *StreamService) createStreamExecution(ctx context.Context, streamID int) (data Execution, err error) {
 
-	url := fmt.Sprintf("%s/v1/streams/%d/executions", baseURL, streamID)
-	bodyBytes, _, err := s.client.genericPOST(url, nil, nil)
+	url := fmt.Sprintf("%s/v1/streams/%d/executions", s.client.baseURL, streamID)
+	bodyBytes, statusCode, err := s.client.genericRequestContext(ctx, url, "POST", nil, nil)
 
 	if err != nil {
 		return data, fmt.Errorf(
@@ -209,6 +310,11 @@ func (s *StreamService) createStreamExecution(streamID int) (data Execution, err
 		)
 	}
 
+	if statusCode >= 300 {
+		message, _ := bytesToErrorMessage(bodyBytes)
+		return data, fmt.Errorf("Failed to create execution of stream %d : %d %s", streamID, statusCode, message.StatusReason)
+	}
+
 	err = json.Unmarshal(bodyBytes, &data)
 
 	if err != nil {
@@ -234,20 +340,25 @@ func (s *StreamService) createStreamExecution(streamID int) (data Execution, err
 // Returns
 // Returns a subset of a stream object and a parameter of success or error based on whether the data part within
 // the stream execution being successful.
-func (s *StreamService) uploadDataPart(streamID int, payload string, executionID int) error {
+func (s *StreamService) uploadDataPart(ctx context.Context, streamID int, payload string, executionID int, partID int) error {
 	var err error
-	url := fmt.Sprintf("%s/v1/streams/%d/executions/%d/part/1", baseURL, streamID, executionID)
+	url := fmt.Sprintf("%s/v1/streams/%d/executions/%d/part/%d", s.client.baseURL, streamID, executionID, partID)
 
 	header := make(map[string]string)
 	header["Content-Type"] = "text/csv"
 
 	body := strings.NewReader(payload)
-	bodyBytes, _, err := s.client.genericPUT(url, body, header)
+	bodyBytes, statusCode, err := s.client.genericRequestContext(ctx, url, "PUT", body, header)
 
 	if err != nil {
 		return fmt.Errorf("Unable to put uploaddatapart %s", err)
 	}
 
+	if statusCode >= 300 {
+		message, _ := bytesToErrorMessage(bodyBytes)
+		return fmt.Errorf("Failed to upload part %d : %w toe %s", partID, &statusError{statusCode: statusCode, reason: message.StatusReason}, message.Toe)
+	}
+
 	if logging {
 		buf := new(bytes.Buffer)
 		json.Indent(buf, []byte(bodyBytes), "", "  ")
@@ -265,13 +376,18 @@ func (s *StreamService) uploadDataPart(streamID int, payload string, executionID
 // Returns
 // Returns a subset of a stream object and a parameter of success or error based on
 // whether the stream execution successfully committed to Domo.
-func (s *StreamService) commitStreamExecution(streamID int, executionID int) error {
+func (s *StreamService) commitStreamExecution(ctx context.Context, streamID int, executionID int) error {
 	var err error
-	url := fmt.Sprintf("%s/v1/streams/%d/executions/%d/commit", baseURL, streamID, executionID)
-	_, _, err = s.client.genericPUT(url, nil, nil)
+	url := fmt.Sprintf("%s/v1/streams/%d/executions/%d/commit", s.client.baseURL, streamID, executionID)
+	bodyBytes, statusCode, err := s.client.genericRequestContext(ctx, url, "PUT", nil, nil)
 
 	if err != nil {
-		err = fmt.Errorf("Unable to put commitStreamExecution %s", err)
+		return fmt.Errorf("Unable to put commitStreamExecution %s", err)
+	}
+
+	if statusCode >= 300 {
+		message, _ := bytesToErrorMessage(bodyBytes)
+		err = fmt.Errorf("Failed to commit execution %d of stream %d : %d %s", executionID, streamID, statusCode, message.StatusReason)
 	}
 
 	return err
@@ -284,10 +400,10 @@ func (s *StreamService) commitStreamExecution(streamID int, executionID int) err
 // PUT https://api.domo.com/v1/streams/{STREAM_ID}/executions/{EXECUTION_ID}/abort
 // Returns
 // Returns a parameter of success or error based on whether the Stream ID being valid.
-func (s *StreamService) abortStreamExecution(streamID int, executionID int) error {
+func (s *StreamService) abortStreamExecution(ctx context.Context, streamID int, executionID int) error {
 	var err error
-	url := fmt.Sprintf("%s/v1/streams/%d/executions/%d/abort", baseURL, streamID, executionID)
-	_, statusCode, err := s.client.genericPUT(url, nil, nil)
+	url := fmt.Sprintf("%s/v1/streams/%d/executions/%d/abort", s.client.baseURL, streamID, executionID)
+	_, statusCode, err := s.client.genericRequestContext(ctx, url, "PUT", nil, nil)
 
 	if statusCode != 204 {
 		// it failed need to do something
@@ -303,19 +419,20 @@ func (s *StreamService) UploadStringToStream(streamID int, payload string) error
 
 	s.client.getAccessToken("data")
 
-	thisExecutionID, err := s.createStreamExecution(streamID)
+	ctx := context.Background()
+	thisExecutionID, err := s.createStreamExecution(ctx, streamID)
 
 	if err != nil {
 		return err
 	}
 
-	err = s.uploadDataPart(streamID, payload, thisExecutionID.ID)
+	err = s.uploadDataPart(ctx, streamID, payload, thisExecutionID.ID, 1)
 
 	if err != nil {
 		return fmt.Errorf("Failed to upload file")
 	}
 
-	return s.commitStreamExecution(streamID, thisExecutionID.ID)
+	return s.commitStreamExecution(ctx, streamID, thisExecutionID.ID)
 
 }
 
@@ -330,7 +447,7 @@ func (s *StreamService) Get(streamname string) (list *StreamList, err error) {
 // Get a list of all Streams for a specific DataSet.
 func (s *StreamService) get(streamname string) (data *StreamList, err error) {
 
-	url := fmt.Sprintf("%s/v1/streams/search?q=dataSource.name:%s", baseURL, streamname)
+	url := fmt.Sprintf("%s/v1/streams/search?q=dataSource.name:%s", s.client.baseURL, streamname)
 	bodyBytes, status, err := s.client.genericGET(url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to get from domo %s", err)
@@ -0,0 +1,231 @@
+package domo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Checkpoint the state of a resumable stream upload, kept in a local file
+// so that an upload which dies part way through can be picked up again.
+type Checkpoint struct {
+	StreamID    int            `json:"streamId"`    // The stream being uploaded to
+	ExecutionID int            `json:"executionId"` // The open execution the parts belong to
+	Parts       map[int]string `json:"parts"`       // SHA-256 of every part Domo has accepted, by part number
+	CommittedAt time.Time      `json:"committedAt"` // When a StreamWriter last committed an execution of the stream
+	UpdatedAt   time.Time      `json:"updatedAt"`   // When the checkpoint was last saved
+}
+
+// LoadCheckpoint reads a checkpoint file.
+//
+// Returns nil, and no error, when the file does not exist.
+func LoadCheckpoint(path string) (checkpoint *Checkpoint, err error) {
+	bodyBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read checkpoint %s", err)
+	}
+
+	err = json.Unmarshal(bodyBytes, &checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal checkpoint %s : %s", path, err)
+	}
+	if checkpoint.Parts == nil {
+		checkpoint.Parts = make(map[int]string)
+	}
+	return
+}
+
+// Save writes the checkpoint to path, replacing the old file only once the new one is complete.
+func (c *Checkpoint) Save(path string) error {
+	c.UpdatedAt = time.Now()
+	bodyBytes, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to marshal checkpoint %s", err)
+	}
+	if err = ReplaceFile(path, bodyBytes); err != nil {
+		return fmt.Errorf("Unable to save checkpoint %s", err)
+	}
+	return nil
+}
+
+// ReplaceFile writes data to a temporary file beside path and renames it over path once it is on disk,
+// so a crash never leaves path half written
+func ReplaceFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// partChecksum the checksum recorded in a checkpoint for a part
+func partChecksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// UploadResumable sends CSV data to Domo streamAPI like UploadReader, but records every accepted part
+// in the checkpoint file at path. If the file names an execution of this stream that is still ACTIVE,
+// the upload reattaches to it and skips the parts whose checksums match, so the input and
+// opts.RowsPerPart must be the same as on the first run. A part whose checksum differs is uploaded again.
+//
+// On failure the execution is left open for the next attempt. The checkpoint file is removed once the
+// execution has been committed. If the checkpoint names an execution that was committed already, nothing
+// is uploaded; if it names one that was aborted, failed or is gone, the upload starts again in a new one.
+// A checkpoint for another stream is an error and is left alone.
+//
+// Returns a summary of the committed execution.
+func (s *StreamService) UploadResumable(streamID int, r io.Reader, path string, opts UploadOptions) (summary UploadSummary, err error) {
+	return s.UploadResumableContext(context.Background(), streamID, r, path, opts)
+}
+
+// UploadResumableContext is UploadResumable with a context, which can cancel the upload, leaving the
+// checkpoint to resume from, and carries the span the upload's spans hang off
+func (s *StreamService) UploadResumableContext(ctx context.Context, streamID int, r io.Reader, path string, opts UploadOptions) (summary UploadSummary, err error) {
+	ctx, span := s.startUpload(ctx, streamID)
+	defer func() { endUploadSpan(span, summary, err) }()
+
+	s.client.getAccessToken("data")
+
+	checkpoint, committed, err := s.resumeCheckpoint(ctx, streamID, path)
+	if err != nil {
+		return
+	}
+	if committed {
+		summary = UploadSummary{StreamID: streamID, ExecutionID: checkpoint.ExecutionID}
+		removeCheckpoint(path)
+		return
+	}
+	span.SetAttributes(Attribute{AttrExecutionID, checkpoint.ExecutionID})
+
+	var lock sync.Mutex
+	tracker := newUploadTracker(streamID, checkpoint.ExecutionID, opts.Progress)
+	tracker.accepted = func(part streamPart) error {
+		lock.Lock()
+		defer lock.Unlock()
+		checkpoint.Parts[part.ID] = partChecksum(part.Data)
+		return checkpoint.Save(path)
+	}
+
+	err = s.sendParts(ctx, streamID, checkpoint.ExecutionID, func(emit func(streamPart) error) error {
+		return readParts(r, opts.RowsPerPart, 1, tracker.read, func(part streamPart) error {
+			lock.Lock()
+			done := checkpoint.Parts[part.ID] == partChecksum(part.Data)
+			lock.Unlock()
+			if done {
+				tracker.skipped(part)
+				return opts.validate(part)
+			}
+			return emit(part)
+		})
+	}, opts, tracker)
+
+	if err != nil {
+		logger(fmt.Sprintf("[StreamService] UploadResumable : execution %d left open, checkpoint %s", checkpoint.ExecutionID, path))
+		return
+	}
+
+	summary, err = s.commitUpload(ctx, streamID, checkpoint.ExecutionID, opts, tracker)
+	if err != nil {
+		return
+	}
+
+	removeCheckpoint(path)
+	return
+}
+
+// removeCheckpoint deletes the checkpoint of an execution Domo has committed, logging any failure,
+// as a stale checkpoint only costs the next upload a question to Domo
+func removeCheckpoint(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger(fmt.Sprintf("[StreamService] UploadResumable : unable to remove checkpoint %s", err))
+	}
+}
+
+// resumable asks Domo what became of the execution a checkpoint names. An ACTIVE execution can take
+// more parts and a SUCCESS one is already committed. ABORTED and FAILED executions, and those Domo no
+// longer has, which come back with no CurrentState, are started again. Any other state, or an error
+// asking, means the checkpoint naming the execution must be kept rather than replaced.
+func (s *StreamService) resumable(ctx context.Context, streamID int, executionID int) (execution Execution, err error) {
+	execution, err = s.retrieveStreamExecution(ctx, streamID, executionID)
+	var status *statusError
+	if errors.As(err, &status) && status.statusCode == 404 {
+		return Execution{ID: executionID}, nil
+	}
+	if err != nil {
+		return execution, fmt.Errorf("Unable to tell if execution %d can be resumed, keeping its checkpoint %s", executionID, err)
+	}
+	switch execution.CurrentState {
+	case "ACTIVE", "SUCCESS", "ABORTED", "FAILED":
+		return execution, nil
+	}
+	return execution, fmt.Errorf("Execution %d of stream %d is %s, keeping its checkpoint until it can be resumed or started again", executionID, streamID, execution.CurrentState)
+}
+
+// resumeCheckpoint loads the checkpoint at path if its execution can still take parts, or reports it
+// committed if Domo already has it all. Otherwise it creates a new execution and saves a fresh checkpoint for it.
+// If Domo can't say what became of the execution, or the file belongs to another stream, the checkpoint
+// is left alone and an error returned.
+func (s *StreamService) resumeCheckpoint(ctx context.Context, streamID int, path string) (checkpoint *Checkpoint, committed bool, err error) {
+	checkpoint, err = LoadCheckpoint(path)
+	if err != nil {
+		return
+	}
+
+	if checkpoint != nil {
+		if checkpoint.StreamID != streamID {
+			return nil, false, fmt.Errorf("Checkpoint %s belongs to stream %d, not %d", path, checkpoint.StreamID, streamID)
+		}
+		execution, err := s.resumable(ctx, streamID, checkpoint.ExecutionID)
+		if err != nil {
+			return nil, false, err
+		}
+		switch execution.CurrentState {
+		case "ACTIVE":
+			logger(fmt.Sprintf("[StreamService] UploadResumable : resuming execution %d with %d parts done", checkpoint.ExecutionID, len(checkpoint.Parts)))
+			return checkpoint, false, nil
+		case "SUCCESS":
+			logger(fmt.Sprintf("[StreamService] UploadResumable : execution %d was already committed", checkpoint.ExecutionID))
+			return checkpoint, true, nil
+		}
+		logger(fmt.Sprintf("[StreamService] UploadResumable : execution %d can't be resumed, starting again", checkpoint.ExecutionID))
+	}
+
+	execution, err := s.createStreamExecution(ctx, streamID)
+	if err != nil {
+		return
+	}
+
+	checkpoint = &Checkpoint{
+		StreamID:    streamID,
+		ExecutionID: execution.ID,
+		Parts:       make(map[int]string),
+	}
+	err = checkpoint.Save(path)
+	return
+}
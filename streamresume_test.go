@@ -0,0 +1,164 @@
+package domo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamService_UploadResumable(t *testing.T) {
+	tests := []struct {
+		name           string
+		checkpoint     *Checkpoint
+		executionState string
+		executionCode  int
+		failures       map[string]int
+		wantErr        bool
+		wantCreate     int
+		wantParts      int
+		wantSkipped    int
+		wantRows       int64
+		wantCommit     int
+		wantCheckpoint map[int]string
+	}{
+		{
+			name:       "fresh upload",
+			wantCreate: 1,
+			wantParts:  2,
+			wantRows:   3,
+			wantCommit: 1,
+		},
+		{
+			name: "resume open execution",
+			checkpoint: &Checkpoint{
+				StreamID:    7,
+				ExecutionID: 4,
+				Parts:       map[int]string{1: partChecksum("a,1\nb,2\n")},
+			},
+			executionState: "ACTIVE",
+			wantParts:      1,
+			wantSkipped:    1,
+			wantRows:       3,
+			wantCommit:     1,
+		},
+		{
+			name: "checkpoint for a finished execution",
+			checkpoint: &Checkpoint{
+				StreamID:    7,
+				ExecutionID: 4,
+				Parts:       map[int]string{1: partChecksum("a,1\nb,2\n")},
+			},
+			executionState: "SUCCESS",
+		},
+		{
+			name: "checkpoint for an aborted execution",
+			checkpoint: &Checkpoint{
+				StreamID:    7,
+				ExecutionID: 4,
+				Parts:       map[int]string{1: partChecksum("a,1\nb,2\n")},
+			},
+			executionState: "ABORTED",
+			wantCreate:     1,
+			wantParts:      2,
+			wantRows:       3,
+			wantCommit:     1,
+		},
+		{
+			name: "checkpoint for an execution in an unknown state is kept",
+			checkpoint: &Checkpoint{
+				StreamID:    7,
+				ExecutionID: 4,
+				Parts:       map[int]string{1: partChecksum("a,1\nb,2\n")},
+			},
+			executionState: "COMMITTING",
+			wantErr:        true,
+			wantCheckpoint: map[int]string{1: partChecksum("a,1\nb,2\n")},
+		},
+		{
+			name: "checkpoint for another stream is kept",
+			checkpoint: &Checkpoint{
+				StreamID:    8,
+				ExecutionID: 4,
+				Parts:       map[int]string{1: partChecksum("a,1\nb,2\n")},
+			},
+			executionState: "ACTIVE",
+			wantErr:        true,
+			wantCheckpoint: map[int]string{1: partChecksum("a,1\nb,2\n")},
+		},
+		{
+			name: "checkpoint for an execution Domo no longer has",
+			checkpoint: &Checkpoint{
+				StreamID:    7,
+				ExecutionID: 4,
+				Parts:       map[int]string{1: partChecksum("a,1\nb,2\n")},
+			},
+			executionCode: 404,
+			wantCreate:    1,
+			wantParts:     2,
+			wantRows:      3,
+			wantCommit:    1,
+		},
+		{
+			name: "execution state unknown keeps the checkpoint",
+			checkpoint: &Checkpoint{
+				StreamID:    7,
+				ExecutionID: 4,
+				Parts:       map[int]string{1: partChecksum("a,1\nb,2\n")},
+			},
+			executionState: "ACTIVE",
+			failures:       map[string]int{"GET /v1/streams/7/executions/4": 1},
+			wantErr:        true,
+			wantCheckpoint: map[int]string{1: partChecksum("a,1\nb,2\n")},
+		},
+		{
+			name:           "failure keeps the checkpoint",
+			failures:       map[string]int{"PUT /v1/streams/7/executions/4/part/2": 1},
+			wantErr:        true,
+			wantCreate:     1,
+			wantParts:      2,
+			wantCheckpoint: map[int]string{1: partChecksum("a,1\nb,2\n")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "checkpoint")
+			assert.Equal(t, nil, err, "Bad temp dir")
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "upload.json")
+			if tt.checkpoint != nil {
+				assert.Equal(t, nil, tt.checkpoint.Save(path), "Bad checkpoint save")
+			}
+
+			doer := uploadTestDoer()
+			doer.routes["GET /v1/streams/7/executions/4"] = testDoer{responseCode: 200, response: `{"id": 4, "currentState": "` + tt.executionState + `"}`}
+			if tt.executionCode != 0 {
+				doer.routes["GET /v1/streams/7/executions/4"] = testDoer{responseCode: tt.executionCode, response: `{"status":404,"statusReason":"Not Found","toe":"TEST"}`}
+			}
+			doer.failures = tt.failures
+			d := CreateTestClient(doer)
+
+			summary, err := d.Stream.UploadResumable(7, strings.NewReader("a,1\nb,2\nc,3\n"), path, UploadOptions{RowsPerPart: 2})
+			assert.Equal(t, tt.wantErr, err != nil, "Bad error code")
+			assert.Equal(t, tt.wantCreate, doer.count("POST /v1/streams/7/executions"), "Bad create count")
+			assert.Equal(t, tt.wantParts, doer.count("PUT /v1/streams/7/executions/4/part/*"), "Bad part count")
+			assert.Equal(t, tt.wantCommit, doer.count("PUT /v1/streams/7/executions/4/commit"), "Bad commit count")
+			assert.Equal(t, 0, doer.count("PUT /v1/streams/7/executions/4/abort"), "Execution aborted")
+
+			checkpoint, err := LoadCheckpoint(path)
+			assert.Equal(t, nil, err, "Bad checkpoint load")
+			if tt.wantErr {
+				assert.Equal(t, tt.wantCheckpoint, checkpoint.Parts, "Bad checkpoint parts")
+			} else {
+				assert.Equal(t, (*Checkpoint)(nil), checkpoint, "Checkpoint not removed")
+				assert.Equal(t, 4, summary.ExecutionID, "Bad summary execution")
+				assert.Equal(t, tt.wantParts+tt.wantSkipped, summary.Parts, "Bad summary parts")
+				assert.Equal(t, tt.wantSkipped, summary.Skipped, "Bad summary skipped")
+				assert.Equal(t, tt.wantRows, summary.Rows, "Bad summary rows")
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package domo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRowsPerPart rows placed in each data part when UploadOptions doesn't say
+const defaultRowsPerPart = 10000
+
+// errUploadStopped is returned to a part producer once a part has failed for good
+var errUploadStopped = errors.New("upload stopped after a failed part")
+
+// UploadOptions controls how a multi-part stream upload is split up and sent.
+//
+// The zero value uploads 10000 rows per part, one part at a time, without retries.
+type UploadOptions struct {
+	RowsPerPart int                     // Number of CSV rows in each data part
+	Parallel    int                     // Number of parts uploaded at the same time
+	Retries     int                     // Extra attempts made before a failed part aborts the execution
+	RetryWait   time.Duration           // Pause between attempts, defaults to one second
+	Validate    func(int, string) error // Optional check of each part, by part number, before it is uploaded
+	Progress    func(UploadProgress)    // Called after every part is uploaded or retried, one call at a time
+	Complete    func(UploadSummary)     // Called once the execution has been committed
+}
+
+// UploadProgress a snapshot of a running stream upload
+type UploadProgress struct {
+	StreamID      int           // The stream being uploaded to
+	ExecutionID   int           // The execution the parts belong to
+	BytesRead     int64         // Bytes read from the input so far
+	BytesSent     int64         // Bytes in parts Domo has accepted
+	Rows          int64         // Rows in parts Domo has accepted
+	PartsUploaded int           // Parts Domo has accepted
+	PartsRetried  int           // Upload attempts that had to be repeated
+	PartsSkipped  int           // Parts a resumed upload found already accepted
+	Elapsed       time.Duration // Time since the execution was created
+	Throughput    float64       // Bytes accepted per second
+}
+
+// UploadSummary the outcome of a committed stream upload
+type UploadSummary struct {
+	StreamID    int           // The stream uploaded to
+	ExecutionID int           // The committed execution
+	Parts       int           // Number of data parts in the execution
+	Skipped     int           // Parts accepted before a resumed upload started
+	Retries     int           // Upload attempts that had to be repeated
+	Rows        int64         // Rows uploaded
+	Bytes       int64         // Bytes uploaded
+	Duration    time.Duration // Time from creating the execution to its commit
+}
+
+// streamPart one numbered chunk of CSV rows
+type streamPart struct {
+	ID   int
+	Data string
+	Rows int
+}
+
+// UploadReader sends CSV data to Domo streamAPI as a single execution.
+// The rows read from r are split into data parts which are uploaded, and retried, as set out in opts.
+// The execution is committed once every part has been accepted, or aborted if any part fails for good.
+//
+// Returns a summary of the committed execution.
+func (s *StreamService) UploadReader(streamID int, r io.Reader, opts UploadOptions) (summary UploadSummary, err error) {
+	return s.UploadReaderContext(context.Background(), streamID, r, opts)
+}
+
+// UploadReaderContext is UploadReader with a context, which can cancel the upload and carries the
+// span the upload's spans hang off
+func (s *StreamService) UploadReaderContext(ctx context.Context, streamID int, r io.Reader, opts UploadOptions) (summary UploadSummary, err error) {
+	ctx, span := s.startUpload(ctx, streamID)
+	defer func() { endUploadSpan(span, summary, err) }()
+
+	s.client.getAccessToken("data")
+
+	execution, err := s.createStreamExecution(ctx, streamID)
+	if err != nil {
+		return
+	}
+	span.SetAttributes(Attribute{AttrExecutionID, execution.ID})
+
+	tracker := newUploadTracker(streamID, execution.ID, opts.Progress)
+	err = s.sendParts(ctx, streamID, execution.ID, func(emit func(streamPart) error) error {
+		return readParts(r, opts.RowsPerPart, 1, tracker.read, emit)
+	}, opts, tracker)
+
+	if err != nil {
+		s.abortStreamExecution(ctx, streamID, execution.ID)
+		return
+	}
+
+	return s.commitUpload(ctx, streamID, execution.ID, opts, tracker)
+}
+
+// UploadParts sends pre-split CSV parts to Domo streamAPI as a single execution.
+// Each string is uploaded as one data part, numbered in the order given.
+//
+// Returns a summary of the committed execution.
+func (s *StreamService) UploadParts(streamID int, parts []string, opts UploadOptions) (summary UploadSummary, err error) {
+	return s.UploadPartsContext(context.Background(), streamID, parts, opts)
+}
+
+// UploadPartsContext is UploadParts with a context, which can cancel the upload and carries the
+// span the upload's spans hang off
+func (s *StreamService) UploadPartsContext(ctx context.Context, streamID int, parts []string, opts UploadOptions) (summary UploadSummary, err error) {
+	ctx, span := s.startUpload(ctx, streamID)
+	defer func() { endUploadSpan(span, summary, err) }()
+
+	s.client.getAccessToken("data")
+
+	execution, err := s.createStreamExecution(ctx, streamID)
+	if err != nil {
+		return
+	}
+	span.SetAttributes(Attribute{AttrExecutionID, execution.ID})
+
+	tracker := newUploadTracker(streamID, execution.ID, opts.Progress)
+	err = s.sendParts(ctx, streamID, execution.ID, func(emit func(streamPart) error) error {
+		for i, payload := range parts {
+			tracker.read(len(payload))
+			if err := emit(streamPart{ID: i + 1, Data: payload, Rows: countRows(payload)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, opts, tracker)
+
+	if err != nil {
+		s.abortStreamExecution(ctx, streamID, execution.ID)
+		return
+	}
+
+	return s.commitUpload(ctx, streamID, execution.ID, opts, tracker)
+}
+
+// commitUpload commits the execution and reports the summary
+func (s *StreamService) commitUpload(ctx context.Context, streamID int, executionID int, opts UploadOptions, tracker *uploadTracker) (summary UploadSummary, err error) {
+	ctx, span := s.client.startSpan(ctx, "domo commit", func() []Attribute {
+		return []Attribute{{AttrObjectID, strconv.Itoa(streamID)}, {AttrExecutionID, executionID}}
+	})
+	defer func() { endSpan(span, err) }()
+
+	s.client.getAccessToken("data")
+	err = s.commitStreamExecution(ctx, streamID, executionID)
+	if err != nil {
+		return
+	}
+
+	s.client.observe(func(m Metrics) { m.ObserveCommit(streamID) })
+	summary = tracker.summary()
+	logger(fmt.Sprintf("[StreamService] Upload : stream %d execution %d committed %d parts in %s", streamID, executionID, summary.Parts, summary.Duration))
+	if opts.Complete != nil {
+		opts.Complete(summary)
+	}
+	return
+}
+
+// sendParts uploads every part handed to emit by produce, using opts.Parallel workers.
+// Once a part has failed every attempt, emit returns errUploadStopped and the first failure is returned.
+func (s *StreamService) sendParts(ctx context.Context, streamID int, executionID int, produce func(emit func(streamPart) error) error, opts UploadOptions, tracker *uploadTracker) error {
+	workers := opts.Parallel
+	if workers < 1 {
+		workers = 1
+	}
+
+	parts := make(chan streamPart)
+	stop := make(chan struct{})
+	var once sync.Once
+	var failure error
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range parts {
+				select {
+				case <-stop:
+					continue
+				default:
+				}
+				if err := s.sendPart(ctx, streamID, executionID, part, opts, tracker); err != nil {
+					once.Do(func() {
+						failure = err
+						close(stop)
+					})
+				}
+			}
+		}()
+	}
+
+	err := produce(func(part streamPart) error {
+		if err := opts.validate(part); err != nil {
+			return err
+		}
+		select {
+		case parts <- part:
+			return nil
+		case <-stop:
+			return errUploadStopped
+		}
+	})
+	close(parts)
+	wg.Wait()
+
+	if failure != nil {
+		return failure
+	}
+	return err
+}
+
+// validate runs the optional check on a part
+func (opts UploadOptions) validate(part streamPart) error {
+	if opts.Validate == nil {
+		return nil
+	}
+	return opts.Validate(part.ID, part.Data)
+}
+
+// sendPart uploads one part, retrying up to opts.Retries times
+func (s *StreamService) sendPart(ctx context.Context, streamID int, executionID int, part streamPart, opts UploadOptions, tracker *uploadTracker) (err error) {
+	ctx, span := s.client.startSpan(ctx, "domo upload part", func() []Attribute {
+		return []Attribute{{AttrObjectID, strconv.Itoa(streamID)}, {AttrExecutionID, executionID}, {AttrPartID, part.ID}, {AttrRows, part.Rows}, {AttrBytes, len(part.Data)}}
+	})
+	attempts := 0
+	defer func() {
+		span.SetAttributes(Attribute{AttrAttempts, attempts})
+		endSpan(span, err)
+	}()
+
+	wait := opts.RetryWait
+	if wait <= 0 {
+		wait = time.Second
+	}
+
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			if !retryable(err) {
+				break
+			}
+			logger(fmt.Sprintf("[StreamService] Upload : retrying part %d of execution %d after %s", part.ID, executionID, err))
+			tracker.retried()
+			s.client.observe(func(m Metrics) { m.ObserveRetry(streamID) })
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return fmt.Errorf("Failed to upload part %d, stopped %s", part.ID, ctx.Err())
+			}
+		}
+
+		attempts++
+		s.client.getAccessToken("data")
+		err = s.uploadDataPart(ctx, streamID, part.Data, executionID, part.ID)
+		if err == nil {
+			tracker.uploaded(part)
+			s.client.observe(func(m Metrics) { m.ObservePart(streamID, part.Rows, len(part.Data)) })
+			if tracker.accepted != nil {
+				return tracker.accepted(part)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Failed to upload part %d after %d attempts %s", part.ID, attempts, err)
+}
+
+// retryable whether a failed request may work if it is sent again: the network failed, Domo had an
+// error of its own or asked for requests to slow down. Other statuses will never succeed.
+func retryable(err error) bool {
+	var status *statusError
+	if !errors.As(err, &status) {
+		return true
+	}
+	return status.statusCode >= 500 || status.statusCode == 429
+}
+
+// readParts cuts the CSV text in r into parts of at most rows rows, numbered from firstID.
+// A row is never split, even when a quoted field holds a line break, and blank lines are dropped.
+func readParts(r io.Reader, rows int, firstID int, read func(int), emit func(streamPart) error) error {
+	if rows < 1 {
+		rows = defaultRowsPerPart
+	}
+
+	reader := bufio.NewReader(r)
+	var buf strings.Builder
+	count := 0
+	id := firstID
+	quoted := false
+
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		part := streamPart{ID: id, Data: buf.String(), Rows: count}
+		buf.Reset()
+		count = 0
+		id++
+		return emit(part)
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			read(len(line))
+			if quoted || strings.TrimSpace(line) != "" {
+				if !strings.HasSuffix(line, "\n") {
+					line += "\n"
+				}
+				buf.WriteString(line)
+				if strings.Count(line, `"`)%2 == 1 {
+					quoted = !quoted
+				}
+				if !quoted {
+					count++
+				}
+			}
+			if !quoted && count == rows {
+				if ferr := flush(); ferr != nil {
+					return ferr
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to read upload data %s", err)
+		}
+	}
+
+	return flush()
+}
+
+// countRows counts the CSV rows in a payload, ignoring line breaks inside quoted fields
+func countRows(payload string) int {
+	count := 0
+	readParts(strings.NewReader(payload), len(payload)+1, 1, func(int) {}, func(part streamPart) error {
+		count += part.Rows
+		return nil
+	})
+	return count
+}
+
+// uploadTracker collects the progress of one upload, which may be shared by several workers
+type uploadTracker struct {
+	sync.Mutex
+	progress  UploadProgress
+	started   time.Time
+	report    func(UploadProgress)
+	accepted  func(streamPart) error // optional, called once Domo has accepted a part
+	queue     []UploadProgress       // snapshots waiting for the progress callback
+	reporting bool                   // set while one worker hands the queue to the progress callback
+}
+
+func newUploadTracker(streamID int, executionID int, report func(UploadProgress)) *uploadTracker {
+	return &uploadTracker{
+		progress: UploadProgress{StreamID: streamID, ExecutionID: executionID},
+		started:  time.Now(),
+		report:   report,
+	}
+}
+
+// read records bytes taken from the input
+func (t *uploadTracker) read(n int) {
+	t.Lock()
+	defer t.Unlock()
+	t.progress.BytesRead += int64(n)
+}
+
+// uploaded records a part Domo has accepted and reports progress
+func (t *uploadTracker) uploaded(part streamPart) {
+	t.Lock()
+	t.progress.PartsUploaded++
+	t.progress.Rows += int64(part.Rows)
+	t.progress.BytesSent += int64(len(part.Data))
+	t.notify()
+}
+
+// skipped records a part that was accepted before this upload started
+func (t *uploadTracker) skipped(part streamPart) {
+	t.Lock()
+	t.progress.PartsSkipped++
+	t.progress.Rows += int64(part.Rows)
+	t.notify()
+}
+
+// retried records a repeated attempt and reports progress
+func (t *uploadTracker) retried() {
+	t.Lock()
+	t.progress.PartsRetried++
+	t.notify()
+}
+
+// notify queues a snapshot and releases the lock the caller holds. The first worker to find nobody
+// reporting hands the queue to the progress callback, in order and without the lock, while the others
+// go back to their parts, so a slow callback never keeps them or the reader waiting
+func (t *uploadTracker) notify() {
+	t.progress.Elapsed = time.Since(t.started)
+	if seconds := t.progress.Elapsed.Seconds(); seconds > 0 {
+		t.progress.Throughput = float64(t.progress.BytesSent) / seconds
+	}
+	if t.report == nil {
+		t.Unlock()
+		return
+	}
+	t.queue = append(t.queue, t.progress)
+	if t.reporting {
+		t.Unlock()
+		return
+	}
+	t.reporting = true
+	for len(t.queue) > 0 {
+		snapshot := t.queue[0]
+		t.queue = t.queue[1:]
+		t.Unlock()
+		t.report(snapshot)
+		t.Lock()
+	}
+	t.reporting = false
+	t.Unlock()
+}
+
+// summary the totals once every part is in
+func (t *uploadTracker) summary() UploadSummary {
+	t.Lock()
+	defer t.Unlock()
+	return UploadSummary{
+		StreamID:    t.progress.StreamID,
+		ExecutionID: t.progress.ExecutionID,
+		Parts:       t.progress.PartsUploaded + t.progress.PartsSkipped,
+		Skipped:     t.progress.PartsSkipped,
+		Retries:     t.progress.PartsRetried,
+		Rows:        t.progress.Rows,
+		Bytes:       t.progress.BytesSent,
+		Duration:    time.Since(t.started),
+	}
+}
@@ -0,0 +1,231 @@
+package domo
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func uploadTestDoer() *routeDoer {
+	return &routeDoer{
+		routes: map[string]testDoer{
+			"GET /oauth/token":                      tokenRoute,
+			"POST /v1/streams/7/executions":         {responseCode: 201, response: `{"id": 4, "currentState": "ACTIVE"}`},
+			"PUT /v1/streams/7/executions/4/part/*": {responseCode: 200, response: `{"id": 4}`},
+			"PUT /v1/streams/7/executions/4/commit": {responseCode: 200, response: `{"id": 4, "currentState": "SUCCESS"}`},
+			"PUT /v1/streams/7/executions/4/abort":  {responseCode: 204},
+		},
+	}
+}
+
+func Test_readParts(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		rows      int
+		wantParts []streamPart
+	}{
+		{
+			name:      "even split",
+			data:      "a,1\nb,2\nc,3\nd,4\n",
+			rows:      2,
+			wantParts: []streamPart{{ID: 1, Data: "a,1\nb,2\n", Rows: 2}, {ID: 2, Data: "c,3\nd,4\n", Rows: 2}},
+		},
+		{
+			name:      "short last part without newline",
+			data:      "a,1\nb,2\nc,3",
+			rows:      2,
+			wantParts: []streamPart{{ID: 1, Data: "a,1\nb,2\n", Rows: 2}, {ID: 2, Data: "c,3\n", Rows: 1}},
+		},
+		{
+			name:      "quoted line break stays in one row",
+			data:      "\"a\nstill a\",1\nb,2\n\nc,3\n",
+			rows:      1,
+			wantParts: []streamPart{{ID: 1, Data: "\"a\nstill a\",1\n", Rows: 1}, {ID: 2, Data: "b,2\n", Rows: 1}, {ID: 3, Data: "c,3\n", Rows: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []streamPart
+			read := 0
+			err := readParts(strings.NewReader(tt.data), tt.rows, 1, func(n int) { read += n }, func(p streamPart) error {
+				got = append(got, p)
+				return nil
+			})
+			assert.Equal(t, nil, err, "Bad error code")
+			assert.Equal(t, tt.wantParts, got, "Bad parts")
+			assert.Equal(t, len(tt.data), read, "Bad byte count")
+		})
+	}
+}
+
+func TestStreamService_UploadReader(t *testing.T) {
+	doer := uploadTestDoer()
+	doer.failures = map[string]int{"PUT /v1/streams/7/executions/4/part/2": 1}
+	d := CreateTestClient(doer)
+
+	var mu sync.Mutex
+	var progress []UploadProgress
+	var complete UploadSummary
+
+	summary, err := d.Stream.UploadReader(7, strings.NewReader("a,1\nb,2\nc,3\nd,4\ne,5\n"), UploadOptions{
+		RowsPerPart: 2,
+		Parallel:    2,
+		Retries:     1,
+		RetryWait:   1,
+		Progress: func(p UploadProgress) {
+			mu.Lock()
+			progress = append(progress, p)
+			mu.Unlock()
+		},
+		Complete: func(s UploadSummary) { complete = s },
+	})
+
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 4, summary.ExecutionID, "Bad execution")
+	assert.Equal(t, 3, summary.Parts, "Bad part count")
+	assert.Equal(t, 1, summary.Retries, "Bad retry count")
+	assert.Equal(t, int64(5), summary.Rows, "Bad row count")
+	assert.Equal(t, int64(20), summary.Bytes, "Bad byte count")
+	assert.Equal(t, summary, complete, "Complete not called with the summary")
+	assert.Equal(t, 4, len(progress), "Bad progress reports")
+	last := progress[len(progress)-1]
+	assert.Equal(t, int64(20), last.BytesRead, "Bad bytes read")
+	assert.Equal(t, 1, doer.count("PUT /v1/streams/7/executions/4/commit"), "Commit not called once")
+	assert.Equal(t, 0, doer.count("PUT /v1/streams/7/executions/4/abort"), "Abort called")
+}
+
+func TestStreamService_UploadReader_slowProgress(t *testing.T) {
+	doer := uploadTestDoer()
+	d := CreateTestClient(doer)
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var progress []UploadProgress
+	done := make(chan error)
+	go func() {
+		_, err := d.Stream.UploadReader(7, strings.NewReader("a,1\nb,2\nc,3\nd,4\n"), UploadOptions{
+			RowsPerPart: 1,
+			Parallel:    2,
+			Progress: func(p UploadProgress) {
+				if p.PartsUploaded == 1 {
+					<-release
+				}
+				mu.Lock()
+				progress = append(progress, p)
+				mu.Unlock()
+			},
+		})
+		done <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for doer.count("PUT /v1/streams/7/executions/4/part/*") < 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("Slow progress callback holds up the other worker or the reader")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	assert.Equal(t, nil, <-done, "Bad error code")
+	assert.Equal(t, 4, len(progress), "Bad progress reports")
+	for i, p := range progress {
+		assert.Equal(t, i+1, p.PartsUploaded, "Progress reported out of order")
+	}
+	assert.Equal(t, int64(16), progress[3].BytesRead, "Bad bytes read")
+}
+
+func TestStreamService_UploadParts(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   map[string]int
+		retries    int
+		wantErr    bool
+		wantCommit int
+		wantAbort  int
+	}{
+		{
+			name:       "all parts accepted",
+			wantCommit: 1,
+		},
+		{
+			name:      "part fails every attempt",
+			failures:  map[string]int{"PUT /v1/streams/7/executions/4/part/1": 3},
+			retries:   2,
+			wantErr:   true,
+			wantAbort: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doer := uploadTestDoer()
+			doer.failures = tt.failures
+			d := CreateTestClient(doer)
+
+			summary, err := d.Stream.UploadParts(7, []string{"a,1\n", "b,2\nc,3\n"}, UploadOptions{Retries: tt.retries, RetryWait: 1})
+			assert.Equal(t, tt.wantErr, err != nil, "Bad error code")
+			assert.Equal(t, tt.wantCommit, doer.count("PUT /v1/streams/7/executions/4/commit"), "Bad commit count")
+			assert.Equal(t, tt.wantAbort, doer.count("PUT /v1/streams/7/executions/4/abort"), "Bad abort count")
+			if !tt.wantErr {
+				assert.Equal(t, 2, summary.Parts, "Bad part count")
+				assert.Equal(t, int64(3), summary.Rows, "Bad row count")
+			}
+		})
+	}
+}
+
+func TestStreamService_sendPart(t *testing.T) {
+	doer := uploadTestDoer()
+	doer.routes["PUT /v1/streams/7/executions/4/part/1"] = testDoer{responseCode: 400, response: `{"status":400,"statusReason":"Bad Request","toe":"TEST"}`}
+	d := CreateTestClient(doer)
+	part := streamPart{ID: 1, Data: "a,1\n", Rows: 1}
+
+	err := d.Stream.sendPart(context.Background(), 7, 4, part, UploadOptions{Retries: 2, RetryWait: 1}, newUploadTracker(7, 4, nil))
+	assert.NotEqual(t, nil, err, "Bad error code")
+	assert.Equal(t, 1, doer.count("PUT /v1/streams/7/executions/4/part/1"), "Bad request retried")
+
+	doer.routes["PUT /v1/streams/7/executions/4/part/1"] = testDoer{responseCode: 503, response: `{"status":503,"statusReason":"Service Unavailable","toe":"TEST"}`}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	started := time.Now()
+	err = d.Stream.sendPart(ctx, 7, 4, part, UploadOptions{Retries: 5, RetryWait: time.Hour}, newUploadTracker(7, 4, nil))
+	assert.NotEqual(t, nil, err, "Bad error code")
+	assert.Equal(t, true, time.Since(started) < time.Minute, "Retry wait not cut short by the context")
+	assert.Equal(t, 2, doer.count("PUT /v1/streams/7/executions/4/part/1"), "Retried after the context was cancelled")
+}
+
+func TestUploadTracker_progress(t *testing.T) {
+	release := make(chan struct{})
+	tracker := newUploadTracker(7, 4, func(p UploadProgress) {
+		if p.PartsUploaded == 1 {
+			<-release
+		}
+	})
+	go tracker.uploaded(streamPart{ID: 1, Data: "a,1\n", Rows: 1})
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			tracker.Lock()
+			uploaded := tracker.progress.PartsUploaded
+			tracker.Unlock()
+			if uploaded == 1 {
+				tracker.read(4)
+				close(done)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Slow progress callback holds the tracker")
+	}
+	close(release)
+}
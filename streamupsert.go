@@ -0,0 +1,239 @@
+package domo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Stream update methods, the data import behavior of a stream
+const (
+	UpdateMethodAppend  = "APPEND"  // Each execution adds its rows to the DataSet
+	UpdateMethodReplace = "REPLACE" // Each execution replaces the rows in the DataSet
+	UpdateMethodUpsert  = "UPSERT"  // Each execution inserts new rows and updates rows with matching key columns
+)
+
+// CheckUpdateMethod The update method of a stream (available methods are: 'APPEND', 'REPLACE', 'UPSERT')
+// Returns bool
+func CheckUpdateMethod(method string) bool {
+	switch method {
+	case UpdateMethodAppend, UpdateMethodReplace, UpdateMethodUpsert:
+		return true
+	default:
+		return false
+	}
+}
+
+// streamRequest the body of a create or update stream request
+type streamRequest struct {
+	DataSet        *streamDataSetRequest `json:"dataSet,omitempty"`
+	UpdateMethod   string                `json:"updateMethod"`
+	KeyColumnNames []string              `json:"keyColumnNames,omitempty"`
+}
+
+// streamDataSetRequest the DataSet a new stream creates
+type streamDataSetRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Rows        int    `json:"rows"`
+	Schema      Schema `json:"schema"`
+}
+
+// CreateUpsert Creates a stream, and its DataSet, that upserts on the given key columns.
+// Every key column must be in the schema.
+//
+// Returns the new Stream.
+func (s *StreamService) CreateUpsert(name string, description string, schema Schema, keyColumns []string) (stream *Stream, err error) {
+	if _, err = keyIndexes(schema, keyColumns); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(streamRequest{
+		DataSet:        &streamDataSetRequest{Name: name, Description: description, Schema: schema},
+		UpdateMethod:   UpdateMethodUpsert,
+		KeyColumnNames: keyColumns,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to marshal stream %s", err)
+	}
+
+	stream, err = s.Create(string(payload))
+	if err == nil && (stream == nil || stream.ID == 0) {
+		err = fmt.Errorf("Failed to create upsert stream '%s'", name)
+	}
+	return
+}
+
+// keyIndexes the position of each key column in the schema
+func keyIndexes(schema Schema, keyColumns []string) ([]int, error) {
+	if len(keyColumns) == 0 {
+		return nil, errors.New("UPSERT streams need at least one key column")
+	}
+
+	indexes := make([]int, len(keyColumns))
+	for i, key := range keyColumns {
+		indexes[i] = -1
+		for j, column := range schema.Columns {
+			if column.Name == key {
+				indexes[i] = j
+				break
+			}
+		}
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("Key column '%s' is not in the schema", key)
+		}
+	}
+	return indexes, nil
+}
+
+// DuplicateKeyError a key seen twice within one execution of an upsert stream
+type DuplicateKeyError struct {
+	Key       []string // The values of the key columns
+	FirstPart int      // The part the key was first seen in
+	Part      int      // The part it was seen in again
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("Duplicate key %s in part %d, first seen in part %d", strings.Join(e.Key, ","), e.Part, e.FirstPart)
+}
+
+// UpsertKeys checks the parts of one execution of an upsert stream.
+// Every row must have the schema's columns and a value in each key column, and no key may appear twice.
+// Use a new UpsertKeys, or call Reset, for each execution.
+//
+// Set UploadOptions.Validate to the Check method to have parts checked before they are uploaded,
+// or StreamWriterOptions.Keys to have a StreamWriter check each record as it is written and forget
+// the keys of each execution it commits.
+type UpsertKeys struct {
+	sync.Mutex
+	columns []string
+	indexes []int
+	width   int
+	seen    map[string]int
+	parts   map[int][]string
+}
+
+// NewUpsertKeys checks the key columns against the schema
+func NewUpsertKeys(schema Schema, keyColumns []string) (*UpsertKeys, error) {
+	indexes, err := keyIndexes(schema, keyColumns)
+	if err != nil {
+		return nil, err
+	}
+	return &UpsertKeys{
+		columns: keyColumns,
+		indexes: indexes,
+		width:   len(schema.Columns),
+		seen:    make(map[string]int),
+		parts:   make(map[int][]string),
+	}, nil
+}
+
+// Check reads the CSV rows of a part, remembering their keys.
+// A part that fails the check leaves no keys behind, and checking a part again replaces the keys
+// it had, so a part that is retried isn't taken for a duplicate of itself.
+//
+// Returns a *DuplicateKeyError if a key was already seen in this or an earlier part.
+func (k *UpsertKeys) Check(partID int, data string) error {
+	k.Lock()
+	defer k.Unlock()
+
+	ids, err := k.read(partID, data)
+	if err != nil {
+		return err
+	}
+	k.forget(partID)
+	for _, id := range ids {
+		if first, ok := k.seen[id]; ok {
+			return &DuplicateKeyError{Key: strings.Split(id, "\x00"), FirstPart: first, Part: partID}
+		}
+	}
+	for _, id := range ids {
+		k.seen[id] = partID
+	}
+	k.parts[partID] = ids
+	return nil
+}
+
+// add reads the CSV rows of a record or two written to a part that is still being built, adding
+// their keys to the part's. Rows that fail the check leave no keys behind, and the part keeps the
+// keys it had, so a StreamWriter can turn away one bad record without losing the rest.
+func (k *UpsertKeys) add(partID int, data string) error {
+	k.Lock()
+	defer k.Unlock()
+
+	ids, err := k.read(partID, data)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if first, ok := k.seen[id]; ok {
+			return &DuplicateKeyError{Key: strings.Split(id, "\x00"), FirstPart: first, Part: partID}
+		}
+	}
+	for _, id := range ids {
+		k.seen[id] = partID
+	}
+	k.parts[partID] = append(k.parts[partID], ids...)
+	return nil
+}
+
+// read the keys of each row of a part, checking it has a duplicate of none of its own
+func (k *UpsertKeys) read(partID int, data string) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = k.width
+	own := make(map[string]bool)
+	var ids []string
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Part %d row %d : %s", partID, row, err)
+		}
+
+		key := make([]string, len(k.indexes))
+		for i, index := range k.indexes {
+			if record[index] == "" {
+				return nil, fmt.Errorf("Part %d row %d : key column '%s' is empty", partID, row, k.columns[i])
+			}
+			key[i] = record[index]
+		}
+
+		id := strings.Join(key, "\x00")
+		if own[id] {
+			return nil, &DuplicateKeyError{Key: key, FirstPart: partID, Part: partID}
+		}
+		own[id] = true
+		ids = append(ids, id)
+	}
+}
+
+// Forget drops the keys of a part, so they can be sent again after its upload failed
+func (k *UpsertKeys) Forget(partID int) {
+	k.Lock()
+	defer k.Unlock()
+	k.forget(partID)
+}
+
+// forget drops the keys of a part, the caller holds the lock
+func (k *UpsertKeys) forget(partID int) {
+	for _, id := range k.parts[partID] {
+		if k.seen[id] == partID {
+			delete(k.seen, id)
+		}
+	}
+	delete(k.parts, partID)
+}
+
+// Reset forgets the keys seen so far, ready for the next execution
+func (k *UpsertKeys) Reset() {
+	k.Lock()
+	defer k.Unlock()
+	k.seen = make(map[string]int)
+	k.parts = make(map[int][]string)
+}
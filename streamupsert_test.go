@@ -0,0 +1,177 @@
+package domo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func upsertTestSchema() (schema Schema) {
+	json.Unmarshal([]byte(`{"columns": [{"type": "LONG", "name": "id"}, {"type": "STRING", "name": "region"}, {"type": "STRING", "name": "name"}]}`), &schema)
+	return
+}
+
+func TestUpsertKeys_Check(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []string
+		parts      []string
+		wantErr    bool
+		wantDupe   *DuplicateKeyError
+		wantKeyErr bool
+	}{
+		{
+			name:  "unique keys across parts",
+			keys:  []string{"id", "region"},
+			parts: []string{"1,us,a\n1,eu,b\n", "2,us,c\n"},
+		},
+		{
+			name:     "duplicate key in a later part",
+			keys:     []string{"id"},
+			parts:    []string{"1,us,a\n2,eu,b\n", "3,us,c\n1,eu,d\n"},
+			wantErr:  true,
+			wantDupe: &DuplicateKeyError{Key: []string{"1"}, FirstPart: 1, Part: 2},
+		},
+		{
+			name:     "duplicate key in one part",
+			keys:     []string{"id"},
+			parts:    []string{"1,us,a\n1,eu,b\n"},
+			wantErr:  true,
+			wantDupe: &DuplicateKeyError{Key: []string{"1"}, FirstPart: 1, Part: 1},
+		},
+		{
+			name:    "empty key column",
+			keys:    []string{"id"},
+			parts:   []string{",us,a\n"},
+			wantErr: true,
+		},
+		{
+			name:    "missing columns",
+			keys:    []string{"id"},
+			parts:   []string{"1,us\n"},
+			wantErr: true,
+		},
+		{
+			name:       "key not in schema",
+			keys:       []string{"missing"},
+			wantKeyErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := NewUpsertKeys(upsertTestSchema(), tt.keys)
+			assert.Equal(t, tt.wantKeyErr, err != nil, "Bad NewUpsertKeys error")
+			if err != nil {
+				return
+			}
+			for i, part := range tt.parts {
+				if err = keys.Check(i+1, part); err != nil {
+					break
+				}
+			}
+			assert.Equal(t, tt.wantErr, err != nil, "Bad error code")
+			if tt.wantDupe != nil {
+				assert.Equal(t, tt.wantDupe, err, "Bad duplicate")
+			}
+		})
+	}
+}
+
+func TestUpsertKeys_retry(t *testing.T) {
+	keys, _ := NewUpsertKeys(upsertTestSchema(), []string{"id"})
+
+	assert.Equal(t, nil, keys.Check(1, "1,us,a\n2,us,b\n"), "Bad first check")
+	assert.Equal(t, nil, keys.Check(1, "1,us,a\n2,us,b\n3,us,c\n"), "Part checked again taken for a duplicate")
+	assert.NotEqual(t, nil, keys.Check(2, "4,us,d\n3,us,e\n"), "Duplicate of a part checked again")
+	assert.Equal(t, nil, keys.Check(2, "4,us,d\n"), "Keys of a failed check kept")
+
+	keys.Forget(1)
+	assert.Equal(t, nil, keys.Check(3, "1,us,a\n"), "Keys of a forgotten part kept")
+	assert.NotEqual(t, nil, keys.Check(3, "4,us,a\n"), "Keys of another part forgotten")
+
+	keys.Reset()
+	assert.Equal(t, nil, keys.Check(1, "4,us,a\n"), "Keys kept after reset")
+}
+
+func TestStreamWriter_upsert(t *testing.T) {
+	doer := uploadTestDoer()
+	doer.failures = map[string]int{"PUT /v1/streams/7/executions/4/part/1": 1}
+	d := CreateTestClient(doer)
+	keys, _ := NewUpsertKeys(upsertTestSchema(), []string{"id"})
+
+	w, err := d.Stream.NewStreamWriter(7, StreamWriterOptions{
+		UploadOptions:  UploadOptions{RowsPerPart: 2},
+		CommitInterval: time.Millisecond,
+		Keys:           keys,
+	})
+	assert.Equal(t, nil, err, "Bad error code")
+
+	assert.Equal(t, nil, w.WriteRecord([]string{"1", "us", "a"}), "Bad write")
+	assert.NotEqual(t, nil, w.WriteRecord([]string{"2", "us", "b"}), "Failed part not reported")
+	assert.Equal(t, nil, w.Flush(), "Rows of the failed part not kept, or taken for duplicates")
+	assert.Equal(t, true, strings.Contains(doer.bodies["PUT /v1/streams/7/executions/4/part/1"], "1,us,a\n2,us,b\n"), "Bad part")
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, nil, w.WriteRecord([]string{"1", "us", "c"}), "Key of the last execution rejected")
+	assert.Equal(t, nil, w.Close(), "Bad close")
+	assert.Equal(t, 2, doer.count("PUT /v1/streams/7/executions/4/commit"), "Bad commit count")
+}
+
+func TestStreamService_UploadParts_upsert(t *testing.T) {
+	doer := uploadTestDoer()
+	d := CreateTestClient(doer)
+	keys, _ := NewUpsertKeys(upsertTestSchema(), []string{"id"})
+
+	_, err := d.Stream.UploadParts(7, []string{"1,us,a\n", "1,eu,b\n"}, UploadOptions{Validate: keys.Check})
+	_, isDupe := err.(*DuplicateKeyError)
+	assert.Equal(t, true, isDupe, "Duplicate not flagged")
+	assert.Equal(t, 1, doer.count("PUT /v1/streams/7/executions/4/abort"), "Execution not aborted")
+	assert.Equal(t, 0, doer.count("PUT /v1/streams/7/executions/4/commit"), "Execution committed")
+}
+
+func TestStreamService_CreateUpsert(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"POST /v1/streams": {responseCode: 201, response: `{"id": 42, "updateMethod": "UPSERT", "keyColumnNames": ["id"]}`},
+	}}
+	d := CreateTestClient(doer)
+
+	stream, err := d.Stream.CreateUpsert("customers", "slowly changing", upsertTestSchema(), []string{"id"})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 42, stream.ID, "Bad stream")
+	assert.Equal(t, []string{"id"}, stream.KeyColumnNames, "Bad key columns")
+	assert.Equal(t, true, strings.Contains(doer.bodies["POST /v1/streams"], `"keyColumnNames":["id"]`), "Keys not sent")
+
+	_, err = d.Stream.CreateUpsert("customers", "", upsertTestSchema(), []string{"nope"})
+	assert.NotEqual(t, nil, err, "Bad key accepted")
+}
+
+func TestStreamService_Update(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		keys       []string
+		wantErr    bool
+		wantCalled int
+	}{
+		{name: "switch to upsert", method: UpdateMethodUpsert, keys: []string{"id"}, wantCalled: 1},
+		{name: "upsert without keys", method: UpdateMethodUpsert, wantErr: true},
+		{name: "unknown method", method: "MERGE", keys: []string{"id"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doer := &routeDoer{routes: map[string]testDoer{
+				"GET /oauth/token":     tokenRoute,
+				"PATCH /v1/streams/42": {responseCode: 200, response: `{"id": 42, "updateMethod": "UPSERT", "keyColumnNames": ["id"]}`},
+			}}
+			d := CreateTestClient(doer)
+
+			_, err := d.Stream.Update(42, tt.method, tt.keys)
+			assert.Equal(t, tt.wantErr, err != nil, "Bad error code")
+			assert.Equal(t, tt.wantCalled, doer.count("PATCH /v1/streams/42"), "Bad PATCH count")
+		})
+	}
+}
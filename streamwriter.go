@@ -0,0 +1,386 @@
+package domo
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StreamCommitInterval the Stream API only supports a commit every 15 minutes
+const StreamCommitInterval = 15 * time.Minute
+
+// StreamWriterOptions controls how a StreamWriter batches rows into parts and commits.
+type StreamWriterOptions struct {
+	UploadOptions                // RowsPerPart, Retries and RetryWait apply to every part, Complete to every commit, Validate to every record
+	CommitInterval time.Duration // Shortest time between commits, defaults to StreamCommitInterval
+	Checkpoint     string        // Optional file that lets a restarted writer reattach to its open execution
+	Keys           *UpsertKeys   // Optional key check of every record for an upsert stream, forgotten after each commit
+}
+
+// StreamWriter accepts rows continuously and batches them into a stream.
+// Rows are buffered until there are RowsPerPart of them, then uploaded as the next part of the open execution.
+// The execution is committed no more often than CommitInterval, and a new one is opened for the rows that follow.
+// With a Checkpoint file, a writer started after a crash picks up the open execution and the time of the last commit.
+//
+// Every record is checked as it is written, by Validate and Keys, and one that fails is turned away
+// without touching the rows already buffered. Validate and Keys see each record as a one row part,
+// numbered by the batch of RowsPerPart rows it is buffered in, counting from 1.
+//
+// Rows are only safe once they are uploaded in a part: those still buffered, fewer than RowsPerPart
+// written since the last part, are lost if the process dies. Call Flush to bound that loss. Without a
+// Checkpoint file, the parts of an execution that was never committed are lost as well.
+//
+// A StreamWriter is safe for use by several goroutines, which can keep writing while a part is uploaded.
+type StreamWriter struct {
+	sync.Mutex            // guards the buffer
+	sending    sync.Mutex // held while talking to Domo, so parts go up one at a time and in order
+	stream     *StreamService
+	streamID   int
+	opts       StreamWriterOptions
+	buf        bytes.Buffer
+	rows       int
+	batch      int // the batch being buffered, whose rows' keys are recorded under its number
+	err        error
+	closed     bool
+	stop       chan struct{}
+	stopped    chan struct{}
+	pending    []writerPart // batches taken from the buffer and not yet accepted, oldest first, guarded by sending
+	sent       []int        // batches accepted in the open execution, guarded by sending
+	state      *Checkpoint  // guarded by sending
+	nextPart   int          // guarded by sending
+	tracker    *uploadTracker
+	lastCommit time.Time
+}
+
+// writerPart a batch of rows waiting to be uploaded as the next part
+type writerPart struct {
+	batch int
+	data  string
+	rows  int
+}
+
+// NewStreamWriter opens a writer on the stream, reattaching to the execution named in opts.Checkpoint
+// if it is still ACTIVE. No execution is created until the first part is ready.
+func (s *StreamService) NewStreamWriter(streamID int, opts StreamWriterOptions) (w *StreamWriter, err error) {
+	if opts.CommitInterval <= 0 {
+		opts.CommitInterval = StreamCommitInterval
+	}
+	if opts.RowsPerPart < 1 {
+		opts.RowsPerPart = defaultRowsPerPart
+	}
+
+	w = &StreamWriter{
+		stream:   s,
+		streamID: streamID,
+		opts:     opts,
+		batch:    1,
+		state:    &Checkpoint{StreamID: streamID, Parts: make(map[int]string)},
+		nextPart: 1,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	if opts.Checkpoint != "" {
+		err = w.reattach()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// reattach loads the writer's checkpoint, keeping its execution only if Domo still has it open.
+// A checkpoint for another stream is an error, as the writer would otherwise save over it.
+func (w *StreamWriter) reattach() error {
+	checkpoint, err := LoadCheckpoint(w.opts.Checkpoint)
+	if err != nil || checkpoint == nil {
+		return err
+	}
+	if checkpoint.StreamID != w.streamID {
+		return fmt.Errorf("Checkpoint %s belongs to stream %d, not %d", w.opts.Checkpoint, checkpoint.StreamID, w.streamID)
+	}
+
+	w.lastCommit = checkpoint.CommittedAt
+	w.state.CommittedAt = checkpoint.CommittedAt
+	if checkpoint.ExecutionID == 0 {
+		return nil
+	}
+
+	w.stream.client.getAccessToken("data")
+	execution, err := w.stream.resumable(context.Background(), w.streamID, checkpoint.ExecutionID)
+	if err != nil {
+		return err
+	}
+	switch execution.CurrentState {
+	case "ACTIVE":
+	case "SUCCESS":
+		logger(fmt.Sprintf("[StreamWriter] execution %d was already committed", checkpoint.ExecutionID))
+		w.lastCommit = execution.EndedAt
+		if w.lastCommit.IsZero() {
+			w.lastCommit = time.Now()
+		}
+		w.state.CommittedAt = w.lastCommit
+		return w.save()
+	default:
+		logger(fmt.Sprintf("[StreamWriter] execution %d can't be resumed, starting again", checkpoint.ExecutionID))
+		return nil
+	}
+
+	logger(fmt.Sprintf("[StreamWriter] resuming execution %d with %d parts", checkpoint.ExecutionID, len(checkpoint.Parts)))
+	w.state = checkpoint
+	for id := range checkpoint.Parts {
+		if id >= w.nextPart {
+			w.nextPart = id + 1
+		}
+	}
+	w.tracker = newUploadTracker(w.streamID, checkpoint.ExecutionID, w.opts.Progress)
+	return nil
+}
+
+// WriteRecord buffers one CSV row, uploading a part and committing when they are due.
+// A record that fails Validate or Keys is rejected and nothing else is lost.
+// If the part can't be uploaded its rows, this one included, are kept for the next attempt.
+// A failure of the background commit is returned once, by the next WriteRecord, without buffering its row.
+func (w *StreamWriter) WriteRecord(record []string) error {
+	return w.WriteRecordContext(context.Background(), record)
+}
+
+// WriteRecordContext is WriteRecord with a context, which can cancel the upload of a part that is due.
+// The row is buffered either way.
+func (w *StreamWriter) WriteRecordContext(ctx context.Context, record []string) error {
+	w.Lock()
+	if w.closed {
+		w.Unlock()
+		return errors.New("StreamWriter is closed")
+	}
+	if err := w.err; err != nil {
+		w.err = nil
+		w.Unlock()
+		return err
+	}
+
+	var line bytes.Buffer
+	writer := csv.NewWriter(&line)
+	writer.Write(record)
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		w.Unlock()
+		return fmt.Errorf("Unable to write record %s", err)
+	}
+	if err := w.check(line.String()); err != nil {
+		w.Unlock()
+		return fmt.Errorf("Rejected record %s", err)
+	}
+	w.buf.Write(line.Bytes())
+	w.rows++
+	due := w.rows >= w.opts.RowsPerPart
+	w.Unlock()
+
+	if !due {
+		return nil
+	}
+	w.sending.Lock()
+	defer w.sending.Unlock()
+	if err := w.flush(ctx); err != nil {
+		return err
+	}
+	return w.commitIfDue(ctx)
+}
+
+// Flush uploads any buffered rows as a part of the open execution without committing it.
+func (w *StreamWriter) Flush() error {
+	return w.FlushContext(context.Background())
+}
+
+// FlushContext is Flush with a context, which can cancel the upload, leaving the rows for the next attempt.
+func (w *StreamWriter) FlushContext(ctx context.Context) error {
+	w.sending.Lock()
+	defer w.sending.Unlock()
+	return w.flush(ctx)
+}
+
+// Close uploads the buffered rows and commits the open execution.
+// If the last commit was less than CommitInterval ago, Close waits until a commit is allowed.
+// The rows are uploaded and checkpointed before the wait, so a writer killed while waiting loses
+// nothing if it has a Checkpoint file: the next one reattaches and commits the execution.
+func (w *StreamWriter) Close() error {
+	return w.CloseContext(context.Background())
+}
+
+// CloseContext is Close with a context, which can cut short the upload or the wait for the commit.
+// The writer is closed either way, and with a Checkpoint file the next one picks up what was uploaded.
+func (w *StreamWriter) CloseContext(ctx context.Context) error {
+	w.Lock()
+	if w.closed {
+		w.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.Unlock()
+
+	close(w.stop)
+	<-w.stopped
+
+	w.sending.Lock()
+	err := w.flush(ctx)
+	if err != nil || len(w.state.Parts) == 0 {
+		w.sending.Unlock()
+		if err == nil {
+			w.Lock()
+			err = w.err
+			w.Unlock()
+		}
+		return err
+	}
+	wait := w.opts.CommitInterval - time.Since(w.lastCommit)
+	w.sending.Unlock()
+
+	if wait > 0 {
+		logger(fmt.Sprintf("[StreamWriter] waiting %s before the final commit", wait))
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	w.sending.Lock()
+	defer w.sending.Unlock()
+	return w.commit(ctx)
+}
+
+// run commits in the background, so rows are not left waiting when writes stop for a while
+func (w *StreamWriter) run() {
+	defer close(w.stopped)
+
+	timer := time.NewTimer(w.opts.CommitInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-timer.C:
+		}
+
+		w.sending.Lock()
+		err := w.flush(context.Background())
+		if err == nil {
+			err = w.commitIfDue(context.Background())
+		}
+		wait := w.opts.CommitInterval - time.Since(w.lastCommit)
+		w.sending.Unlock()
+		if err != nil {
+			logger(fmt.Sprintf("[StreamWriter] %s", err))
+			w.Lock()
+			w.err = err
+			w.Unlock()
+		}
+
+		if wait <= 0 {
+			wait = w.opts.CommitInterval
+		}
+		timer.Reset(wait)
+	}
+}
+
+// check validates one record and records its keys, if the stream is an upsert, the caller holds the lock
+func (w *StreamWriter) check(line string) error {
+	if err := w.opts.validate(streamPart{ID: w.batch, Data: line, Rows: 1}); err != nil {
+		return err
+	}
+	if w.opts.Keys == nil {
+		return nil
+	}
+	return w.opts.Keys.add(w.batch, line)
+}
+
+// flush takes the buffered rows as the next batch and uploads every batch not yet accepted, in order,
+// the caller holds sending. The buffer's lock is only held to take the rows, so writes carry on meanwhile.
+func (w *StreamWriter) flush(ctx context.Context) error {
+	w.Lock()
+	if w.rows > 0 {
+		w.pending = append(w.pending, writerPart{batch: w.batch, data: w.buf.String(), rows: w.rows})
+		w.buf.Reset()
+		w.rows = 0
+		w.batch++
+	}
+	w.Unlock()
+
+	for len(w.pending) > 0 {
+		if w.state.ExecutionID == 0 {
+			w.stream.client.getAccessToken("data")
+			execution, err := w.stream.createStreamExecution(ctx, w.streamID)
+			if err != nil {
+				return err
+			}
+			w.state.ExecutionID = execution.ID
+			w.tracker = newUploadTracker(w.streamID, execution.ID, w.opts.Progress)
+		}
+
+		next := w.pending[0]
+		part := streamPart{ID: w.nextPart, Data: next.data, Rows: next.rows}
+		w.tracker.read(len(part.Data))
+		err := w.stream.sendPart(ctx, w.streamID, w.state.ExecutionID, part, w.opts.UploadOptions, w.tracker)
+		if err != nil {
+			return err
+		}
+
+		w.pending = w.pending[1:]
+		w.sent = append(w.sent, next.batch)
+		w.nextPart++
+		w.state.Parts[part.ID] = partChecksum(part.Data)
+		if err = w.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitIfDue commits the open execution once CommitInterval has passed, the caller holds sending
+func (w *StreamWriter) commitIfDue(ctx context.Context) error {
+	if len(w.state.Parts) == 0 || time.Since(w.lastCommit) < w.opts.CommitInterval {
+		return nil
+	}
+	return w.commit(ctx)
+}
+
+// commit commits the open execution and forgets it and the keys of its rows, the caller holds sending
+func (w *StreamWriter) commit(ctx context.Context) error {
+	_, err := w.stream.commitUpload(ctx, w.streamID, w.state.ExecutionID, w.opts.UploadOptions, w.tracker)
+	if err != nil {
+		return err
+	}
+
+	w.lastCommit = time.Now()
+	w.state = &Checkpoint{StreamID: w.streamID, Parts: make(map[int]string), CommittedAt: w.lastCommit}
+	w.nextPart = 1
+	w.tracker = nil
+	if w.opts.Keys != nil {
+		for _, batch := range w.sent {
+			w.opts.Keys.Forget(batch)
+		}
+	}
+	w.sent = nil
+	return w.save()
+}
+
+// save records the writer's state in its checkpoint file, if it has one, the caller holds sending
+func (w *StreamWriter) save() error {
+	if w.opts.Checkpoint == "" {
+		return nil
+	}
+	err := w.state.Save(w.opts.Checkpoint)
+	if err != nil {
+		logger(fmt.Sprintf("[StreamWriter] %s", err))
+	}
+	return err
+}
@@ -0,0 +1,220 @@
+package domo
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamWriter(t *testing.T) {
+	interval := 30 * time.Millisecond
+	tests := []struct {
+		name           string
+		checkpoint     *Checkpoint
+		executionState string
+		records        [][]string
+		wantCreate     int
+		wantParts      int
+		wantCommit     int
+	}{
+		{
+			name:       "commit straight away then again on close",
+			records:    [][]string{{"a", "1"}, {"b", "2"}, {"c", "3"}},
+			wantCreate: 2,
+			wantParts:  2,
+			wantCommit: 2,
+		},
+		{
+			name: "reattach after restart",
+			checkpoint: &Checkpoint{
+				StreamID:    7,
+				ExecutionID: 4,
+				Parts:       map[int]string{1: partChecksum("x,0\n")},
+			},
+			executionState: "ACTIVE",
+			records:        [][]string{{"a", "1"}},
+			wantCreate:     0,
+			wantParts:      1,
+			wantCommit:     1,
+		},
+		{
+			name: "restart after a commit that wasn't checkpointed",
+			checkpoint: &Checkpoint{
+				StreamID:    7,
+				ExecutionID: 4,
+				Parts:       map[int]string{1: partChecksum("x,0\n")},
+			},
+			executionState: "SUCCESS",
+			records:        [][]string{{"a", "1"}},
+			wantCreate:     1,
+			wantParts:      1,
+			wantCommit:     1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "writer")
+			assert.Equal(t, nil, err, "Bad temp dir")
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "writer.json")
+			if tt.checkpoint != nil {
+				tt.checkpoint.CommittedAt = time.Now()
+				assert.Equal(t, nil, tt.checkpoint.Save(path), "Bad checkpoint save")
+			}
+
+			doer := uploadTestDoer()
+			doer.routes["GET /v1/streams/7/executions/4"] = testDoer{responseCode: 200, response: `{"id": 4, "currentState": "` + tt.executionState + `"}`}
+			d := CreateTestClient(doer)
+
+			var commits []time.Time
+			w, err := d.Stream.NewStreamWriter(7, StreamWriterOptions{
+				UploadOptions:  UploadOptions{RowsPerPart: 2, Complete: func(UploadSummary) { commits = append(commits, time.Now()) }},
+				CommitInterval: interval,
+				Checkpoint:     path,
+			})
+			assert.Equal(t, nil, err, "Bad error code")
+
+			for _, record := range tt.records {
+				assert.Equal(t, nil, w.WriteRecord(record), "Bad write")
+			}
+			assert.Equal(t, nil, w.Close(), "Bad close")
+			assert.NotEqual(t, nil, w.WriteRecord([]string{"z"}), "Write after close")
+
+			assert.Equal(t, tt.wantCreate, doer.count("POST /v1/streams/7/executions"), "Bad create count")
+			assert.Equal(t, tt.wantParts, doer.count("PUT /v1/streams/7/executions/4/part/*"), "Bad part count")
+			assert.Equal(t, tt.wantCommit, doer.count("PUT /v1/streams/7/executions/4/commit"), "Bad commit count")
+			for i := 1; i < len(commits); i++ {
+				assert.Equal(t, true, commits[i].Sub(commits[i-1]) >= interval, "Commits too close together")
+			}
+
+			checkpoint, err := LoadCheckpoint(path)
+			assert.Equal(t, nil, err, "Bad checkpoint load")
+			assert.Equal(t, 0, checkpoint.ExecutionID, "Execution left open")
+			assert.Equal(t, false, checkpoint.CommittedAt.IsZero(), "Commit time not saved")
+		})
+	}
+}
+
+func TestStreamWriter_Close(t *testing.T) {
+	dir, err := ioutil.TempDir("", "writer")
+	assert.Equal(t, nil, err, "Bad temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "writer.json")
+	assert.Equal(t, nil, (&Checkpoint{StreamID: 7, CommittedAt: time.Now()}).Save(path), "Bad checkpoint save")
+
+	doer := uploadTestDoer()
+	d := CreateTestClient(doer)
+	w, err := d.Stream.NewStreamWriter(7, StreamWriterOptions{
+		UploadOptions:  UploadOptions{RowsPerPart: 10},
+		CommitInterval: 200 * time.Millisecond,
+		Checkpoint:     path,
+	})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, nil, w.WriteRecord([]string{"a", "1"}), "Bad write")
+
+	closed := make(chan error)
+	go func() { closed <- w.Close() }()
+	time.Sleep(50 * time.Millisecond)
+
+	// Close is waiting for the commit interval, with the rows already uploaded and checkpointed
+	flushed := make(chan error)
+	go func() { flushed <- w.Flush() }()
+	select {
+	case err = <-flushed:
+		assert.Equal(t, nil, err, "Bad flush")
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Close holds the lock while it waits")
+	}
+	checkpoint, err := LoadCheckpoint(path)
+	assert.Equal(t, nil, err, "Bad checkpoint load")
+	assert.Equal(t, 4, checkpoint.ExecutionID, "Execution not checkpointed")
+	assert.Equal(t, 1, len(checkpoint.Parts), "Part not checkpointed")
+	assert.Equal(t, 0, doer.count("PUT /v1/streams/7/executions/4/commit"), "Committed too soon")
+
+	assert.Equal(t, nil, <-closed, "Bad close")
+	assert.Equal(t, 1, doer.count("PUT /v1/streams/7/executions/4/commit"), "Bad commit count")
+}
+
+func TestStreamWriter_WriteRecord(t *testing.T) {
+	doer := uploadTestDoer()
+	d := CreateTestClient(doer)
+	keys, _ := NewUpsertKeys(upsertTestSchema(), []string{"id"})
+
+	w, err := d.Stream.NewStreamWriter(7, StreamWriterOptions{
+		UploadOptions: UploadOptions{RowsPerPart: 3, Validate: func(_ int, data string) error {
+			if strings.Contains(data, ",xx,") {
+				return errors.New("unknown region")
+			}
+			return nil
+		}},
+		CommitInterval: time.Hour,
+		Keys:           keys,
+	})
+	assert.Equal(t, nil, err, "Bad error code")
+
+	assert.Equal(t, nil, w.WriteRecord([]string{"1", "us", "a"}), "Bad write")
+	assert.NotEqual(t, nil, w.WriteRecord([]string{"2", "xx", "b"}), "Invalid record accepted")
+	assert.NotEqual(t, nil, w.WriteRecord([]string{"1", "eu", "c"}), "Duplicate key accepted")
+	assert.NotEqual(t, nil, w.WriteRecord([]string{"", "eu", "d"}), "Empty key accepted")
+	assert.Equal(t, nil, w.WriteRecord([]string{"2", "eu", "e"}), "Bad write after a rejected record")
+	assert.Equal(t, nil, w.WriteRecord([]string{"3", "eu", "f"}), "Bad write after a rejected record")
+	assert.Equal(t, "1,us,a\n2,eu,e\n3,eu,f\n", doer.bodies["PUT /v1/streams/7/executions/4/part/1"], "Valid rows lost with the rejected ones")
+	assert.Equal(t, nil, w.Close(), "Bad close")
+}
+
+// gateDoer holds every part upload until the gate is opened
+type gateDoer struct {
+	*routeDoer
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (g gateDoer) Do(req *http.Request) (*http.Response, error) {
+	if strings.Contains(req.URL.Path, "/part/") {
+		select {
+		case g.entered <- struct{}{}:
+		default:
+		}
+		<-g.gate
+	}
+	return g.routeDoer.Do(req)
+}
+
+func TestStreamWriter_writeWhileSending(t *testing.T) {
+	doer := gateDoer{routeDoer: uploadTestDoer(), entered: make(chan struct{}, 1), gate: make(chan struct{})}
+	d := CreateTestClient(doer)
+	w, err := d.Stream.NewStreamWriter(7, StreamWriterOptions{
+		UploadOptions:  UploadOptions{RowsPerPart: 2},
+		CommitInterval: time.Hour,
+	})
+	assert.Equal(t, nil, err, "Bad error code")
+
+	sent := make(chan error)
+	go func() {
+		w.WriteRecord([]string{"a", "1"})
+		sent <- w.WriteRecord([]string{"b", "2"})
+	}()
+	<-doer.entered
+
+	written := make(chan error)
+	go func() { written <- w.WriteRecord([]string{"c", "3"}) }()
+	select {
+	case err = <-written:
+		assert.Equal(t, nil, err, "Bad write")
+	case <-time.After(time.Second):
+		t.Error("Write waits for the upload of a part")
+	}
+
+	close(doer.gate)
+	assert.Equal(t, nil, <-sent, "Bad write")
+	assert.Equal(t, "a,1\nb,2\n", doer.bodies["PUT /v1/streams/7/executions/4/part/1"], "Bad first part")
+	assert.Equal(t, nil, w.Flush(), "Bad flush")
+	assert.Equal(t, "c,3\n", doer.bodies["PUT /v1/streams/7/executions/4/part/1"], "Row written during the upload lost")
+}
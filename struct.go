@@ -71,6 +71,16 @@ type Dataset struct {
 	Policies    Policies  `json:"policies,omitempty"`
 }
 
+// Domo column types, used in a Schema
+const (
+	ColumnString   = "STRING"
+	ColumnLong     = "LONG"
+	ColumnDouble   = "DOUBLE"
+	ColumnDecimal  = "DECIMAL"
+	ColumnDate     = "DATE"
+	ColumnDateTime = "DATETIME"
+)
+
 // Schema ...
 type Schema struct {
 	Columns Columns `json:"columns"`
@@ -115,18 +125,17 @@ type Access struct {
 
 //Page The page object
 type Page struct {
-	Name          string       `json:"name"`          // The name of the page
-	ID            int          `json:"id"`            // The ID of the page
-	ParentID      int          `json:"parentId"`      // The ID of the page that is higher in organizational hierarchy
-	OwnerID       int          `json:"ownerId"`       // The ID of the page owner
-	Locked        bool         `json:"locked"`        // Determines whether users (besides the page owner) can make updates to page or its content - the default value is false
-	CollectionIDs int          `json:"collectionIds"` // The IDs of collections within a page
-	CardIds       []int        `json:"cardIds"`       // The ID of all cards contained within the page
-	Children      ChildrenPage `json:"children"`      // All pages that are considered "sub pages" in organizational hierarchy
-	Visibility    Visibility   `json:"visibility"`    // Determines the access given to both individual users or groups within Domo
-	UserIds       int          `json:"userIds"`       // The ID of the page
-	GroupIDs      int          `json:"groupIds"`      // The ID of the page
-
+	Name          string         `json:"name"`               // The name of the page
+	ID            int            `json:"id"`                 // The ID of the page
+	ParentID      int            `json:"parentId"`           // The ID of the page that is higher in organizational hierarchy
+	OwnerID       int            `json:"ownerId"`            // The ID of the page owner
+	Locked        bool           `json:"locked"`             // Determines whether users (besides the page owner) can make updates to page or its content - the default value is false
+	CollectionIDs []int          `json:"collectionIds"`      // The IDs of collections within a page
+	CardIds       []int          `json:"cardIds"`            // The ID of all cards contained within the page
+	Children      []ChildrenPage `json:"children"`           // All pages that are considered "sub pages" in organizational hierarchy
+	Visibility    Visibility     `json:"visibility"`         // Determines the access given to both individual users or groups within Domo
+	UserIds       []int          `json:"userIds,omitempty"`  // The IDs of the users given access, when sent outside Visibility
+	GroupIDs      []int          `json:"groupIds,omitempty"` // The IDs of the groups given access, when sent outside Visibility
 }
 
 // Pages List of pages
@@ -136,6 +145,14 @@ type Pages []struct {
 	Children []ChildrenPage `json:"children"`
 }
 
+// PageCollection A group of cards on a page, shown under a title
+type PageCollection struct {
+	ID          int    `json:"id"`          // The ID of the collection
+	Title       string `json:"title"`       // The title of the collection
+	Description string `json:"description"` // The description of the collection
+	CardIDs     []int  `json:"cardIds"`     // The cards in the collection, in order
+}
+
 // Visibility Determines the access given to both individual users or groups within Domo
 type Visibility struct {
 	UserIds  []int `json:"userIds"`  // The IDs of the users
@@ -148,6 +165,45 @@ type ChildrenPage struct {
 	ID   int    `json:"id"`   // The ID of the page
 }
 
+// Card A visualization, shown on one or more pages
+type Card struct {
+	ID          int              `json:"id"`          // The ID of the card
+	Title       string           `json:"title"`       // The title of the card
+	Type        string           `json:"type"`        // The kind of card, such as kpi, doc or notebook
+	Description string           `json:"description"` // The description of the card
+	OwnerID     int              `json:"ownerId"`     // The ID of the card owner
+	PageIDs     []int            `json:"pageIds"`     // The IDs of the pages the card is on
+	DataSources []CardDataSource `json:"datasources"` // The DataSets the card is built from
+}
+
+// CardDataSource A DataSet a card is built from, with the columns it uses.
+// Domo's card reference doesn't document these, so the datasources, dataSourceId and columns
+// field names are unverified; a card that decodes with none means they differ on your instance.
+type CardDataSource struct {
+	ID      string   `json:"dataSourceId"`   // The ID of the DataSet
+	Name    string   `json:"dataSourceName"` // The name of the DataSet
+	Columns []string `json:"columns"`        // The columns the card uses, empty when Domo doesn't say
+}
+
+// ActivityEntry An event of the activity log, recording who did what to which object
+type ActivityEntry struct {
+	UserName          string `json:"userName"`          // The user the event is about
+	UserID            int    `json:"userId"`            // The ID of that user
+	UserType          string `json:"userType"`          // The kind of user, such as USER or DEVELOPER_TOKEN
+	ActorName         string `json:"actorName"`         // The user who acted, when acting on behalf of another
+	ActorID           int    `json:"actorId"`           // The ID of the actor
+	ActorType         string `json:"actorType"`         // The kind of actor
+	ObjectName        string `json:"objectName"`        // The name of the object acted on
+	ObjectID          string `json:"objectId"`          // The ID of the object acted on
+	ObjectType        string `json:"objectType"`        // The kind of object, such as DATA_SOURCE, PAGE or CARD
+	AdditionalComment string `json:"additionalComment"` // More about the event
+	Time              int64  `json:"time"`              // When the event happened, in milliseconds since the epoch
+	EventText         string `json:"eventText"`         // The action, such as Viewed or Deleted
+	Device            string `json:"device"`            // The device used
+	BrowserDetails    string `json:"browserDetails"`    // The browser used
+	IPAddress         string `json:"ipAddress"`         // The address the request came from
+}
+
 // Execution ...
 type Execution struct {
 	ID           int       `json:"id"` // ID of the Stream
@@ -161,11 +217,12 @@ type Execution struct {
 
 //Stream ....
 type Stream struct {
-	ID           int           `json:"id"` //ID of the Stream
-	DataSet      StreamDataSet `json:"dataSet"`
-	UpdateMethod string        `json:"updateMethod"` // The data import behavior
-	CreatedAt    time.Time     `json:"createdAt"`
-	ModifiedAt   time.Time     `json:"modifiedAt"`
+	ID             int           `json:"id"` //ID of the Stream
+	DataSet        StreamDataSet `json:"dataSet"`
+	UpdateMethod   string        `json:"updateMethod"`             // The data import behavior
+	KeyColumnNames []string      `json:"keyColumnNames,omitempty"` // The columns that identify a row when UpdateMethod is UPSERT
+	CreatedAt      time.Time     `json:"createdAt"`
+	ModifiedAt     time.Time     `json:"modifiedAt"`
 }
 
 //Owner The owner of the underlying DataSet
@@ -179,6 +236,7 @@ type StreamList []struct {
 	ID                      int           `json:"id"`
 	DataSet                 StreamDataSet `json:"dataSet"`
 	UpdateMethod            string        `json:"updateMethod"`
+	KeyColumnNames          []string      `json:"keyColumnNames,omitempty"`
 	LastExecution           Execution     `json:"lastExecution"`
 	LastSuccessfulExecution Execution     `json:"lastSuccessfulExecution"`
 	CreatedAt               time.Time     `json:"createdAt"`
@@ -190,12 +248,13 @@ type User struct {
 	ID             int       `json:"id"`
 	Title          string    `json:"title,omitempty"` //User's job title
 	Email          string    `json:"email"`           //User's primary email used in profile
-	Role           string    `json:"role"`            //The role of the user created (available roles are: 'Admin', 'Privileged', 'Participant')
+	Role           Role      `json:"role"`            //The role of the user created (available roles are: 'Admin', 'Privileged', 'Participant')
 	Name           string    `json:"name"`            //User's full name
 	RoleID         int       `json:"roleId"`
 	CreatedAt      time.Time `json:"createdAt"`
 	UpdatedAt      time.Time `json:"updatedAt"`
 	Timezone       string    `json:"timezone,omitempty"` //Time zone used to display to user the system times throughout Domo application
+	Locale         string    `json:"locale,omitempty"`   //Locale used to display to user the system settings throughout Domo application
 	Phone          string    `json:"phone,omitempty"`    //Primary phone number of user
 	Location       string    `json:"location,omitempty"` //Free text that can be used to define office location (e.g. City, State, Country)
 	AlternateEmail string    `json:"alternateEmail"`     //User's secondary email in profile
@@ -212,7 +271,7 @@ type Users []struct {
 	ID        int       `json:"id"`
 	Title     string    `json:"title,omitempty"` //User's job title
 	Email     string    `json:"email"`           //User's primary email used in profile
-	Role      string    `json:"role"`            //The role of the user created (available roles are: 'Admin', 'Privileged', 'Participant')
+	Role      Role      `json:"role"`            //The role of the user created (available roles are: 'Admin', 'Privileged', 'Participant')
 	Name      string    `json:"name"`            //User's full name
 	RoleID    int       `json:"roleId"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -222,6 +281,14 @@ type Users []struct {
 	Location  string    `json:"location,omitempty"` //Free text that can be used to define office location (e.g. City, State, Country)
 }
 
+// RoleDetail a role in your Domo instance, one of the defaults or a custom role
+type RoleDetail struct {
+	ID          int    `json:"id"`
+	Name        Role   `json:"name"`
+	Description string `json:"description,omitempty"`
+	UserCount   int    `json:"userCount"` //Number of users with the role
+}
+
 // ErrorMessage domo error reply
 type ErrorMessage struct {
 	Status       int    `json:"status"`
@@ -0,0 +1,167 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Attribute keys set on spans
+const (
+	AttrService     = "domo.service"      // API the request went to, such as streams or users
+	AttrOperation   = "domo.operation"    // Method and path with ids taken out, such as PUT /v1/streams/{id}
+	AttrObjectID    = "domo.object_id"    // First id in the path, such as the stream or user id, always a string
+	AttrStatusCode  = "http.status_code"  // Status of the response
+	AttrToe         = "domo.toe"          // Trace id Domo sends with an error, for Domo support
+	AttrExecutionID = "domo.execution_id" // Stream execution of an upload
+	AttrPartID      = "domo.part_id"      // Data part of an upload
+	AttrRows        = "domo.rows"         // Rows uploaded
+	AttrBytes       = "domo.bytes"        // Bytes uploaded
+	AttrAttempts    = "domo.attempts"     // Tries it took to upload a part
+	AttrParts       = "domo.parts"        // Data parts in an upload
+)
+
+// Tracer starts spans, so requests to Domo show up in a distributed trace.
+// The otel package adapts an OpenTelemetry TracerProvider to it.
+type Tracer interface {
+	// Start begins a span as a child of any span in ctx, and returns a context holding the new one
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span a unit of work started by a Tracer
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute a key and a string, int, int64, bool or float64 value
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// SetTracer traces every request, and every upload with its parts, with the tracer
+//
+// Tracing is off by default, and costs nothing while it is off
+func (d *Client) SetTracer(tracer Tracer) {
+	d.tracer = tracer
+}
+
+// noSpan stands in for a span when there is no tracer
+type noSpan struct{}
+
+func (noSpan) SetAttributes(attrs ...Attribute) {}
+func (noSpan) RecordError(err error)            {}
+func (noSpan) End()                             {}
+
+// startSpan starts a span if there is a tracer. attrs is only called when there is one,
+// so the attributes aren't built for nothing.
+func (d *Client) startSpan(ctx context.Context, name string, attrs func() []Attribute) (context.Context, Span) {
+	if d.tracer == nil {
+		return ctx, noSpan{}
+	}
+	return d.tracer.Start(ctx, name, attrs()...)
+}
+
+// requestAttributes names the service, operation and object of a request from its address
+func requestAttributes(method string, address string) []Attribute {
+	service, operation, objectID, err := describeRequest(method, address)
+	if err != nil {
+		return []Attribute{{AttrOperation, method}}
+	}
+
+	attrs := []Attribute{
+		{AttrService, service},
+		{AttrOperation, operation},
+	}
+	if objectID != "" {
+		attrs = append(attrs, Attribute{AttrObjectID, objectID})
+	}
+	return attrs
+}
+
+// requestService the API a request goes to, such as streams or users
+func requestService(address string) string {
+	service, _, _, _ := describeRequest("", address)
+	return service
+}
+
+// describeRequest works out the service, the operation with its ids taken out and the first id from the address
+func describeRequest(method string, address string) (service string, operation string, objectID string, err error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, segment := range segments {
+		switch {
+		case i == 0 && segment != "v1":
+			service = segment
+		case i == 1 && segments[0] == "v1":
+			service = segment
+		case i > 0 && isID(segment):
+			if objectID == "" {
+				objectID = segment
+			}
+			segments[i] = "{id}"
+		}
+	}
+	operation = method + " /" + strings.Join(segments, "/")
+	return
+}
+
+// isID whether a path segment is a number or a DataSet id rather than a fixed part of the path
+func isID(segment string) bool {
+	if _, err := strconv.ParseInt(segment, 10, 64); err == nil {
+		return true
+	}
+	return len(segment) == 36 && strings.Count(segment, "-") == 4
+}
+
+// endRequestSpan records the outcome of a request on its span and ends it
+func endRequestSpan(span Span, statusCode int, bodyBytes []byte, err error) {
+	if err != nil {
+		span.RecordError(err)
+	} else {
+		span.SetAttributes(Attribute{AttrStatusCode, statusCode})
+		if statusCode >= 400 {
+			message, _ := bytesToErrorMessage(bodyBytes)
+			if message.Toe != "" {
+				span.SetAttributes(Attribute{AttrToe, message.Toe})
+			}
+			span.RecordError(fmt.Errorf("%d %s", statusCode, message.StatusReason))
+		}
+	}
+	span.End()
+}
+
+// endSpan records any error on the span and ends it
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// startUpload starts the span that the execution, part and commit spans of an upload hang off
+func (s *StreamService) startUpload(ctx context.Context, streamID int) (context.Context, Span) {
+	return s.client.startSpan(ctx, "domo upload", func() []Attribute {
+		return []Attribute{{AttrService, "streams"}, {AttrObjectID, strconv.Itoa(streamID)}}
+	})
+}
+
+// endUploadSpan records how much an upload sent on its span and ends it
+func endUploadSpan(span Span, summary UploadSummary, err error) {
+	if err == nil {
+		span.SetAttributes(
+			Attribute{AttrRows, summary.Rows},
+			Attribute{AttrBytes, summary.Bytes},
+			Attribute{AttrParts, summary.Parts},
+		)
+	}
+	endSpan(span, err)
+}
@@ -0,0 +1,126 @@
+package domo
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSpan a span kept by testTracer
+type testSpan struct {
+	tracer *testTracer
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SetAttributes(attrs ...Attribute) {
+	s.tracer.Lock()
+	defer s.tracer.Unlock()
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *testSpan) RecordError(err error) { s.err = err }
+func (s *testSpan) End()                  { s.ended = true }
+
+type spanKey struct{}
+
+// testTracer records every span it starts, with the name of its parent
+type testTracer struct {
+	sync.Mutex
+	spans []*testSpan
+}
+
+func (tt *testTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	tt.Lock()
+	defer tt.Unlock()
+	span := &testSpan{tracer: tt, name: name, attrs: make(map[string]interface{})}
+	if parent, ok := ctx.Value(spanKey{}).(*testSpan); ok {
+		span.parent = parent.name
+	}
+	for _, a := range attrs {
+		span.attrs[a.Key] = a.Value
+	}
+	tt.spans = append(tt.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// named the spans called name
+func (tt *testTracer) named(name string) (spans []*testSpan) {
+	for _, span := range tt.spans {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+	return
+}
+
+func TestClient_SetTracer(t *testing.T) {
+	doer := uploadTestDoer()
+	doer.routes["GET /v1/groups/5"] = testDoer{responseCode: 404, response: `{"status":404,"statusReason":"Not Found","toe":"ABC"}`}
+	d := CreateTestClient(doer)
+	tracer := &testTracer{}
+	d.SetTracer(tracer)
+
+	d.Group.Retrieve(5)
+	spans := tracer.named("domo GET")
+	assert.Equal(t, 2, len(spans), "Bad GET span count")
+	group := spans[len(spans)-1]
+	assert.Equal(t, "groups", group.attrs[AttrService], "Bad service")
+	assert.Equal(t, "GET /v1/groups/{id}", group.attrs[AttrOperation], "Bad operation")
+	assert.Equal(t, "5", group.attrs[AttrObjectID], "Bad object id")
+	assert.Equal(t, 404, group.attrs[AttrStatusCode], "Bad status")
+	assert.Equal(t, "ABC", group.attrs[AttrToe], "Bad toe")
+	assert.NotEqual(t, nil, group.err, "Error not recorded")
+	assert.Equal(t, true, group.ended, "Span not ended")
+
+	ctx, _ := tracer.Start(context.Background(), "caller")
+	summary, err := d.Stream.UploadPartsContext(ctx, 7, []string{"a,1\n", "b,2\n"}, UploadOptions{})
+	assert.Equal(t, nil, err, "Bad error code")
+
+	uploads := tracer.named("domo upload")
+	assert.Equal(t, 1, len(uploads), "Bad upload span count")
+	assert.Equal(t, "caller", uploads[0].parent, "Upload not under the caller's span")
+	assert.Equal(t, "7", uploads[0].attrs[AttrObjectID], "Bad object id")
+	assert.Equal(t, 4, uploads[0].attrs[AttrExecutionID], "Bad execution id")
+	assert.Equal(t, summary.Rows, uploads[0].attrs[AttrRows], "Bad rows")
+
+	parts := tracer.named("domo upload part")
+	assert.Equal(t, 2, len(parts), "Bad part span count")
+	for _, part := range parts {
+		assert.Equal(t, "domo upload", part.parent, "Bad part parent")
+		assert.Equal(t, "7", part.attrs[AttrObjectID], "Bad part object id")
+		assert.Equal(t, 1, part.attrs[AttrAttempts], "Bad attempts")
+		assert.Equal(t, true, part.ended, "Part span not ended")
+	}
+
+	commits := tracer.named("domo commit")
+	assert.Equal(t, 1, len(commits), "Bad commit span count")
+	assert.Equal(t, "domo upload", commits[0].parent, "Bad commit parent")
+
+	for _, put := range tracer.named("domo PUT") {
+		assert.NotEqual(t, "", put.parent, "PUT not under the upload")
+		assert.Equal(t, "streams", put.attrs[AttrService], "Bad service")
+	}
+}
+
+func Test_requestAttributes(t *testing.T) {
+	attrs := requestAttributes("PUT", "https://api.domo.com/v1/datasets/4405ff58-1957-45f0-82bd-914d989a3ea3/data")
+	assert.Equal(t, []Attribute{
+		{AttrService, "datasets"},
+		{AttrOperation, "PUT /v1/datasets/{id}/data"},
+		{AttrObjectID, "4405ff58-1957-45f0-82bd-914d989a3ea3"},
+	}, attrs, "Bad attributes")
+
+	attrs = requestAttributes("GET", "https://api.domo.com/oauth/token?grant_type=client_credentials")
+	assert.Equal(t, []Attribute{
+		{AttrService, "oauth"},
+		{AttrOperation, "GET /oauth/token"},
+	}, attrs, "Bad token attributes")
+}
@@ -1,9 +1,11 @@
 package domo
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
 )
 
 // UserService User API service
@@ -18,7 +20,7 @@ type UserService service
 // a subset of the user information will be returned, including a deleted property, which will be true.
 func (u *UserService) Retrieve(userid int) (user User, err error) {
 	u.client.getAccessToken("user")
-	url := fmt.Sprintf("%s/v1/users/%d", baseURL, userid)
+	url := fmt.Sprintf("%s/v1/users/%d", u.client.baseURL, userid)
 	bodyBytes, _, err := u.client.genericGET(url, nil)
 
 	if err != nil {
@@ -31,6 +33,47 @@ func (u *UserService) Retrieve(userid int) (user User, err error) {
 	return bytesToUser(bodyBytes)
 }
 
+// UserCreate the fields of a new user. Name, Email and one of Role or RoleID are required.
+type UserCreate struct {
+	Name           string `json:"name"`                     //User's full name
+	Email          string `json:"email"`                    //User's primary email used in profile
+	Role           Role   `json:"role,omitempty"`           //The role of the user created (available roles are: 'Admin', 'Privileged', 'Participant')
+	RoleID         int    `json:"roleId,omitempty"`         //Id of a default or custom role, used instead of Role
+	Title          string `json:"title,omitempty"`          //User's job title
+	AlternateEmail string `json:"alternateEmail,omitempty"` //User's secondary email in profile
+	Phone          string `json:"phone,omitempty"`          //Primary phone number of user
+	Location       string `json:"location,omitempty"`       //Free text that can be used to define office location (e.g. City, State, Country)
+	Timezone       string `json:"timezone,omitempty"`       //Time zone used to display to user the system times throughout Domo application
+	Locale         string `json:"locale,omitempty"`         //Locale used to display to user the system settings throughout Domo application
+	EmployeeNumber int    `json:"employeeNumber,omitempty"` //Employee number within company
+	SendInvite     bool   `json:"-"`                        //Send an email invite to the new user
+}
+
+// UserPatch the fields of a user to change. Fields left nil keep their current value.
+type UserPatch struct {
+	Name           *string `json:"name,omitempty"`
+	Email          *string `json:"email,omitempty"`
+	Role           *Role   `json:"role,omitempty"`
+	RoleID         *int    `json:"roleId,omitempty"`
+	Title          *string `json:"title,omitempty"`
+	AlternateEmail *string `json:"alternateEmail,omitempty"`
+	Phone          *string `json:"phone,omitempty"`
+	Location       *string `json:"location,omitempty"`
+	Timezone       *string `json:"timezone,omitempty"`
+	Locale         *string `json:"locale,omitempty"`
+	EmployeeNumber *int    `json:"employeeNumber,omitempty"`
+}
+
+// String a pointer to v, for the fields of a UserPatch
+func String(v string) *string {
+	return &v
+}
+
+// Int a pointer to v, for the fields of a UserPatch
+func Int(v int) *int {
+	return &v
+}
+
 // Create Creates a new user in your Domo instance.
 // Definition
 // POST https://api.domo.com/v1/users
@@ -38,20 +81,26 @@ func (u *UserService) Retrieve(userid int) (user User, err error) {
 // Returns a user object when successful.
 // The returned object will have user attributes based on the information that was provided when user was created.
 // The two exceptions of attributes not returned are the user's timezone and locale.
-func (u *UserService) Create(name string, email string, role string, sendInvite bool) (user User, err error) {
-	u.client.getAccessToken("user")
-	if CheckRole(role) {
-		err = fmt.Errorf("%s is not a valid role , (available roles are: 'Admin', 'Privileged', 'Participant')", role)
+func (u *UserService) Create(ctx context.Context, create UserCreate) (user User, err error) {
+	if create.Name == "" || create.Email == "" {
+		err = errors.New("A new user needs a name and an email")
+		return
+	}
+	if (create.RoleID == 0 || create.Role != "") && !create.Role.Valid() {
+		err = fmt.Errorf("%s is not a valid role , (available roles are: 'Admin', 'Privileged', 'Participant')", create.Role)
 		return
 	}
 
-	url := fmt.Sprintf("%s/v1/users", baseURL)
-	payload := fmt.Sprintf("{\"name\": \"%s\",\"email\": \"%s\",\"role\": \"%s\",\"sendInvite\": %t}", name, email, role, sendInvite)
+	payload, err := json.Marshal(create)
+	if err != nil {
+		err = fmt.Errorf("Unable to marshal user %s", err)
+		return
+	}
 
-	body := strings.NewReader(payload)
-	header := make(map[string]string)
-	header["Content-Type"] = "application/json"
-	bodyBytes, statusCode, err := u.client.genericPOST(url, body, header)
+	u.client.getAccessToken("user")
+	url := fmt.Sprintf("%s/v1/users?sendInvite=%t", u.client.baseURL, create.SendInvite)
+	header := map[string]string{"Content-Type": "application/json"}
+	bodyBytes, statusCode, err := u.client.genericRequestContext(ctx, url, "POST", bytes.NewReader(payload), header)
 
 	if err != nil {
 		err = fmt.Errorf("Unable to create user from domo API %s", err)
@@ -59,8 +108,14 @@ func (u *UserService) Create(name string, email string, role string, sendInvite
 	}
 
 	logger(fmt.Sprintf("createUser Status Code : %d", statusCode))
-	user, err = bytesToUser(bodyBytes)
+	if statusCode >= 300 {
+		message, _ := bytesToErrorMessage(bodyBytes)
+		err = fmt.Errorf("Failed to create user %s : %d %s", create.Email, statusCode, message.StatusReason)
+		return
+	}
 
+	u.client.directory.forget()
+	user, err = bytesToUser(bodyBytes)
 	if err != nil {
 		err = fmt.Errorf("Unable to convert to user %s", err)
 	}
@@ -68,43 +123,101 @@ func (u *UserService) Create(name string, email string, role string, sendInvite
 
 }
 
-// Update Updates the specified user by providing values to parameters passed.
-// Any parameter left out of the request will cause the specific user’s attribute to remain unchanged.
+// Update Updates the specified user with the fields set in patch.
+// Any field left nil will cause the specific user’s attribute to remain unchanged.
 // KNOWN LIMITATION
-// Currently all user fields are required
+// Currently all user fields are required, so the user is retrieved and sent back whole with the
+// patch applied. Domo doesn't return the timezone and locale, which are only sent when patched.
 // Definition
 // PUT https://api.domo.com/v1/users/{id}
 // Returns
-// Returns a 200 response code when successful.
-func (u *UserService) Update(userid int, name string, email string, role string) (err error) {
-	u.client.getAccessToken("user")
-	if CheckRole(role) {
-		return fmt.Errorf(
-			"%s is not a valid role , (available roles are: 'Admin', 'Privileged', 'Participant')",
-			role,
-		)
+// Returns the updated user object when successful.
+func (u *UserService) Update(ctx context.Context, userid int, patch UserPatch) (user User, err error) {
+	if patch.Role != nil && !patch.Role.Valid() {
+		err = fmt.Errorf("%s is not a valid role , (available roles are: 'Admin', 'Privileged', 'Participant')", *patch.Role)
+		return
 	}
 
-	url := fmt.Sprintf("%s/v1/groups/%d", baseURL, userid)
-	payload := fmt.Sprintf(
-		"{\"name\": \"%s\",\"email\": \"%s\",\"role\": \"%s\"}",
-		name,
-		email,
-		role,
-	)
-	body := strings.NewReader(payload)
+	url := fmt.Sprintf("%s/v1/users/%d", u.client.baseURL, userid)
+	bodyBytes, err := u.client.sendJSON(ctx, "user", "GET", url, nil)
+	if err != nil {
+		err = fmt.Errorf("Failed to retrieve user %d to update : %s", userid, err)
+		return
+	}
+	current, err := bytesToUser(bodyBytes)
+	if err != nil {
+		err = fmt.Errorf("Unable to convert to user %s", err)
+		return
+	}
 
-	header := map[string]string{"Content-Type": "application/json"}
-	_, statusCode, err := u.client.genericPUT(url, body, header)
+	bodyBytes, err = u.client.sendJSON(ctx, "user", "PUT", url, patch.apply(current))
+	if err != nil {
+		err = fmt.Errorf("Failed to update user %d : %s", userid, err)
+		return
+	}
 
+	u.client.directory.forget()
+	user, err = bytesToUser(bodyBytes)
 	if err != nil {
-		err = fmt.Errorf("Unable to update user from dom API %s", err)
+		err = fmt.Errorf("Unable to convert to user %s", err)
 	}
+	return
 
-	logger(fmt.Sprintf("updategroup Status Code : %d", statusCode))
+}
 
-	return
+// userUpdate every field of a user, as PUT /v1/users/{id} needs them
+type userUpdate struct {
+	Name           string `json:"name"`
+	Email          string `json:"email"`
+	Role           Role   `json:"role,omitempty"`
+	RoleID         int    `json:"roleId,omitempty"`
+	Title          string `json:"title"`
+	AlternateEmail string `json:"alternateEmail"`
+	Phone          string `json:"phone"`
+	Location       string `json:"location"`
+	Timezone       string `json:"timezone,omitempty"`
+	Locale         string `json:"locale,omitempty"`
+	EmployeeNumber int    `json:"employeeNumber"`
+}
 
+// apply the patch to the user. A new role or role id replaces both, as the old one would contradict it.
+func (p UserPatch) apply(user User) userUpdate {
+	update := userUpdate{
+		Name:           user.Name,
+		Email:          user.Email,
+		Role:           user.Role,
+		RoleID:         user.RoleID,
+		Title:          user.Title,
+		AlternateEmail: user.AlternateEmail,
+		Phone:          user.Phone,
+		Location:       user.Location,
+		Timezone:       user.Timezone,
+		Locale:         user.Locale,
+		EmployeeNumber: user.EmployeeNumber,
+	}
+	set := func(field *string, value *string) {
+		if value != nil {
+			*field = *value
+		}
+	}
+	set(&update.Name, p.Name)
+	set(&update.Email, p.Email)
+	set(&update.Title, p.Title)
+	set(&update.AlternateEmail, p.AlternateEmail)
+	set(&update.Phone, p.Phone)
+	set(&update.Location, p.Location)
+	set(&update.Timezone, p.Timezone)
+	set(&update.Locale, p.Locale)
+	if p.EmployeeNumber != nil {
+		update.EmployeeNumber = *p.EmployeeNumber
+	}
+	if p.Role != nil {
+		update.Role, update.RoleID = *p.Role, 0
+	}
+	if p.RoleID != nil {
+		update.Role, update.RoleID = "", *p.RoleID
+	}
+	return update
 }
 
 // Delete Permanently deletes a user from your Domo instance.
@@ -116,8 +229,9 @@ func (u *UserService) Update(userid int, name string, email string, role string)
 // Returns a 204 response code when successful or error based on whether the user ID being valid.
 func (u *UserService) Delete(userid int) (err error) {
 	u.client.getAccessToken("user")
-	url := fmt.Sprintf("%s/v1/users/%d", baseURL, userid)
+	url := fmt.Sprintf("%s/v1/users/%d", u.client.baseURL, userid)
 	statusCode, err := u.client.genericDELETE(url, nil)
+	u.client.directory.forget()
 
 	if err != nil {
 		err = fmt.Errorf("Unable delete user from dom API %s", err)
@@ -136,7 +250,7 @@ func (u *UserService) Delete(userid int) (err error) {
 // Returns all user objects that meet argument criteria from original request.
 func (u *UserService) List() (users Users, err error) {
 	u.client.getAccessToken("user")
-	url := fmt.Sprintf("%s/v1/users", baseURL)
+	url := fmt.Sprintf("%s/v1/users", u.client.baseURL)
 	bodyBytes, _, err := u.client.genericGET(url, nil)
 
 	if err != nil {
@@ -152,24 +266,66 @@ func (u *UserService) List() (users Users, err error) {
 	return
 }
 
-// Find locates user by name from your Domo instance.
+// userPageSize the most users Domo returns in one page
+const userPageSize = 500
+
+// ListAll Get every user in your Domo instance, a page at a time.
+// Definition
+// GET https://api.domo.com/v1/users?limit={LIMIT}&offset={OFFSET}
 // Returns
-// Returns the ID of the user
-func (u *UserService) Find(name string) (userID int, err error) {
+// Returns all user objects.
+func (u *UserService) ListAll(ctx context.Context) (users []User, err error) {
+	for offset := 0; ; offset += userPageSize {
+		u.client.getAccessToken("user")
+		url := fmt.Sprintf("%s/v1/users?limit=%d&offset=%d", u.client.baseURL, userPageSize, offset)
+		bodyBytes, statusCode, err := u.client.genericRequestContext(ctx, url, "GET", nil, nil)
+
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get users from dom API %s", err)
+		}
 
-	u.client.getAccessToken("user")
-	data, err := u.List()
+		if statusCode != 200 {
+			message, _ := bytesToErrorMessage(bodyBytes)
+			return nil, fmt.Errorf("Failed to list users : %d %s", statusCode, message.StatusReason)
+		}
+
+		var page []User
+		err = json.Unmarshal(bodyBytes, &page)
+		if err != nil {
+			return nil, fmt.Errorf("Failed Unmarshal Users %s JSON string : %s", err, bodyBytes)
+		}
+
+		users = append(users, page...)
+		if len(page) < userPageSize {
+			logger(fmt.Sprintf("[UserService] ListAll : %d users found", len(users)))
+			return users, nil
+		}
+	}
+}
 
+// Find locates user by name, ignoring case, from your Domo instance.
+// Returns
+// Returns the ID of the user, a NotFoundError if no user has the name,
+// or an AmbiguousError listing the users if several have it
+func (u *UserService) Find(name string) (userID int, err error) {
+	directory, err := u.Directory(context.Background())
 	if err != nil {
 		return
 	}
+	user, err := directory.ByName(name)
+	return user.ID, err
+}
 
-	for _, r := range data {
-		if r.Name == name {
-			return r.ID, err
-		}
+// Search finds the users whose names or emails match a pattern, ignoring case.
+// A pattern with * or ? must match the whole name or email, any other pattern matches ones that start with it.
+// Returns
+// Returns the matching users, in the order Domo listed them
+func (u *UserService) Search(ctx context.Context, pattern string) (users []User, err error) {
+	directory, err := u.Directory(ctx)
+	if err != nil {
+		return
 	}
-	return
+	return directory.Search(pattern), nil
 }
 
 // CheckRole The role of the user created (available roles are: 'Admin', 'Privileged', 'Participant')
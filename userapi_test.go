@@ -1,10 +1,14 @@
 package domo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -62,9 +66,19 @@ func ExampleCheckRole() {
 }
 
 func TestClient_List(t *testing.T) {
-
-	var users Users
-	_ = json.Unmarshal([]byte("[{\"id\": 61014150,\"title\": \"Marketing Team\",\"email\": \"marketing@ozlotteries.com\",\"role\": \"Editor\",\"name\": \"Marketing\",\"location\": \"Brisbane\",\"roleId\": 3,\"createdAt\": \"1970-01-18T11:45:43.925Z\",\"updatedAt\": \"2018-02-01T23:42:52.843Z\"}]"), &users)
+	createdAt, _ := time.Parse(time.RFC3339Nano, "1970-01-18T11:45:43.925Z")
+	updatedAt, _ := time.Parse(time.RFC3339Nano, "2018-02-01T23:42:52.843Z")
+	users := Users{{
+		ID:        61014150,
+		Title:     "Marketing Team",
+		Email:     "marketing@ozlotteries.com",
+		Role:      "Editor",
+		Name:      "Marketing",
+		Location:  "Brisbane",
+		RoleID:    3,
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}}
 
 	type fields struct {
 		myDoer Doer
@@ -76,10 +90,8 @@ func TestClient_List(t *testing.T) {
 		wantErr   bool
 	}{
 		{
-			name: "Basic Test",
-			fields: fields{
-				testDoer{responseCode: 200, response: "[{\"id\": 61014150,\"title\": \"Marketing Team\",\"email\": \"marketing@ozlotteries.com\",\"role\": \"Editor\",\"name\": \"Marketing\",\"location\": \"Brisbane\",\"roleId\": 3,\"createdAt\": \"1970-01-18T11:45:43.925Z\",\"updatedAt\": \"2018-02-01T23:42:52.843Z\"}]"},
-			},
+			name:      "Basic Test",
+			fields:    fields{fixtureDoer(t, "users.json")},
 			wantUsers: users,
 			wantErr:   false,
 		},
@@ -100,8 +112,23 @@ func TestClient_List(t *testing.T) {
 }
 
 func TestClient_Retrieve(t *testing.T) {
-	var user User
-	_ = json.Unmarshal([]byte("{\"id\": 27,\"email\": \"support@domo.com\",\"role\": \"Admin\",\"name\": \"DomoSupport\",\"timezone\": \"America/Denver\",\"roleId\": 1,\"createdAt\": \"1970-01-17T14:46:50.566Z\",\"updatedAt\": \"2016-11-01T04:35:37.243Z\",\"image\": \"https://jumbointeractive.domo.com/avatar/thumb/domo/27\",\"groups\": [{\"id\": 180920085,\"name\": \"Content\"},{\"id\": 1324037627,\"name\": \"Default\"}]}"), &user)
+	createdAt, _ := time.Parse(time.RFC3339Nano, "1970-01-17T14:46:50.566Z")
+	updatedAt, _ := time.Parse(time.RFC3339Nano, "2016-11-01T04:35:37.243Z")
+	user := User{
+		ID:        27,
+		Email:     "support@domo.com",
+		Role:      "Admin",
+		Name:      "DomoSupport",
+		Timezone:  "America/Denver",
+		RoleID:    1,
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+		Image:     "https://jumbointeractive.domo.com/avatar/thumb/domo/27",
+		Groups: []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		}{{ID: 180920085, Name: "Content"}, {ID: 1324037627, Name: "Default"}},
+	}
 
 	type fields struct {
 		myDoer Doer
@@ -117,9 +144,8 @@ func TestClient_Retrieve(t *testing.T) {
 		wantErr  bool
 	}{
 		{
-			name: "Basic Test",
-			fields: fields{
-				testDoer{responseCode: 200, response: "{\"id\": 27,\"email\": \"support@domo.com\",\"role\": \"Admin\",\"name\": \"DomoSupport\",\"timezone\": \"America/Denver\",\"roleId\": 1,\"createdAt\": \"1970-01-17T14:46:50.566Z\",\"updatedAt\": \"2016-11-01T04:35:37.243Z\",\"image\": \"https://jumbointeractive.domo.com/avatar/thumb/domo/27\",\"groups\": [{\"id\": 180920085,\"name\": \"Content\"},{\"id\": 1324037627,\"name\": \"Default\"}]}"}},
+			name:     "Basic Test",
+			fields:   fields{fixtureDoer(t, "users.json")},
 			args:     args{userid: 27},
 			wantUser: user,
 			wantErr:  false,
@@ -155,61 +181,18 @@ func TestUserService_Find(t *testing.T) {
 		wantErr    bool
 	}{
 		{
-			name: "Paul Particpant",
-			fields: fields{
-				testDoer{
-					responseCode: 200,
-					response: `[
-						{  
-						   "id":388908335,
-						   "title":"Chief Marketing Officer",
-						   "email":"paul.participant@domosoftware.net",
-						   "alternateEmail":"paul@email.com",
-						   "role":"Participant",
-						   "phone":"431.654.6548",
-						   "name":"Paul Particpant",
-						   "roleId":4,
-						   "employeeNumber":1354,
-						   "createdAt":"1970-01-18T13:19:16.479Z",
-						   "updatedAt":"2018-01-24T01:34:25.531Z"
-						},
-						{  
-						   "id":804729568,
-						   "email":"tech_support@ozlotteries.com",
-						   "role":"Admin",
-						   "name":"Tech Support",
-						   "roleId":1,
-						   "createdAt":"1970-01-18T13:28:10.683Z",
-						   "updatedAt":"2018-02-08T05:46:23.169Z"
-						}
-					 ]`,
-				},
-			},
+			name:       "Paul Particpant",
+			fields:     fields{fixtureDoer(t, "users_directory.json")},
 			args:       args{name: "Paul Particpant"},
 			wantUserID: 388908335,
 			wantErr:    false,
 		},
 		{
-			name: "Missing Mike",
-			fields: fields{
-				testDoer{
-					responseCode: 200,
-					response: `[
-						{  
-						   "id":804729568,
-						   "email":"tech_support@ozlotteries.com",
-						   "role":"Admin",
-						   "name":"Tech Support",
-						   "roleId":1,
-						   "createdAt":"1970-01-18T13:28:10.683Z",
-						   "updatedAt":"2018-02-08T05:46:23.169Z"
-						}
-					 ]`,
-				},
-			},
+			name:       "Missing Mike",
+			fields:     fields{fixtureDoer(t, "users_directory.json")},
 			args:       args{name: "Missing Mike"},
 			wantUserID: 0,
-			wantErr:    false,
+			wantErr:    true,
 		},
 	}
 	for _, tt := range tests {
@@ -227,3 +210,91 @@ func TestUserService_Find(t *testing.T) {
 		})
 	}
 }
+
+func TestUserService_Create(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"POST /v1/users":   {responseCode: 201, response: `{"id": 31, "name": "Dan \"The Man\" O'Neil", "email": "dan@example.com", "role": "Participant", "title": "Analyst"}`},
+	}}
+	d := CreateTestClient(doer)
+
+	user, err := d.User.Create(context.Background(), UserCreate{
+		Name:           `Dan "The Man" O'Neil`,
+		Email:          "dan@example.com",
+		Role:           "Participant",
+		Title:          "Analyst",
+		Locale:         "en-US",
+		EmployeeNumber: 42,
+	})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 31, user.ID, "Bad id")
+	assert.Equal(t, `Dan "The Man" O'Neil`, user.Name, "Bad name")
+
+	var sent map[string]interface{}
+	err = json.Unmarshal([]byte(doer.bodies["POST /v1/users"]), &sent)
+	assert.Equal(t, nil, err, "Bad request body")
+	assert.Equal(t, `Dan "The Man" O'Neil`, sent["name"], "Bad name sent")
+	assert.Equal(t, "en-US", sent["locale"], "Bad locale sent")
+	assert.Equal(t, float64(42), sent["employeeNumber"], "Bad employee number sent")
+	assert.Equal(t, nil, sent["phone"], "Empty phone sent")
+
+	_, err = d.User.Create(context.Background(), UserCreate{Name: "Dan", Email: "dan@example.com", Role: "Boss"})
+	assert.NotEqual(t, nil, err, "Bad role accepted")
+}
+
+func TestUserService_Update(t *testing.T) {
+	doer := &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"GET /v1/users/31": {responseCode: 200, response: `{"id": 31, "name": "Dan", "email": "dan@example.com", "role": "Participant", "roleId": 3, "title": "Buyer", "employeeNumber": 7}`},
+		"PUT /v1/users/31": {responseCode: 200, response: `{"id": 31, "name": "Dan", "email": "dan@example.com", "role": "Participant", "phone": "555-0100"}`},
+		"PUT /v1/users/32": {responseCode: 200, response: `{"id": 32}`},
+	}}
+	d := CreateTestClient(doer)
+
+	user, err := d.User.Update(context.Background(), 31, UserPatch{Phone: String("555-0100"), EmployeeNumber: Int(0)})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, "555-0100", user.Phone, "Bad phone")
+	assert.Equal(t, `{"name":"Dan","email":"dan@example.com","role":"Participant","roleId":3,"title":"Buyer","alternateEmail":"","phone":"555-0100","location":"","employeeNumber":0}`,
+		doer.bodies["PUT /v1/users/31"], "Bad update, every field is required")
+	assert.Equal(t, 0, doer.count("PUT /v1/groups/31"), "Updated a group")
+
+	_, err = d.User.Update(context.Background(), 31, UserPatch{RoleID: Int(12)})
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, true, strings.Contains(doer.bodies["PUT /v1/users/31"], `"roleId":12`), "Role id not sent")
+	assert.Equal(t, false, strings.Contains(doer.bodies["PUT /v1/users/31"], `"role":`), "Old role sent with a new role id")
+
+	_, err = d.User.Update(context.Background(), 32, UserPatch{Name: String("Nobody")})
+	assert.NotEqual(t, nil, err, "Missing user updated")
+	assert.Equal(t, 0, doer.count("PUT /v1/users/32"), "Missing user sent an update")
+}
+
+func TestUserService_ListAll(t *testing.T) {
+	page := make([]string, userPageSize)
+	for i := range page {
+		page[i] = fmt.Sprintf(`{"id": %d}`, i+1)
+	}
+	doer := &pageDoer{pages: []string{"[" + strings.Join(page, ",") + "]", `[{"id": 501, "name": "Last"}]`}}
+	d := CreateTestClient(doer)
+
+	users, err := d.User.ListAll(context.Background())
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 501, len(users), "Bad user count")
+	assert.Equal(t, "Last", users[500].Name, "Bad last user")
+	assert.Equal(t, []string{"limit=500&offset=0", "limit=500&offset=500"}, doer.queries, "Bad pages")
+}
+
+// pageDoer answers each user list request with the next page
+type pageDoer struct {
+	pages   []string
+	queries []string
+}
+
+func (pd *pageDoer) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/oauth/token" {
+		return tokenRoute.Do(req)
+	}
+	pd.queries = append(pd.queries, req.URL.RawQuery)
+	page := pd.pages[0]
+	pd.pages = pd.pages[1:]
+	return testDoer{responseCode: 200, response: page}.Do(req)
+}
@@ -0,0 +1,179 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDirectoryTTL how long a snapshot of the users is used for lookups before it is read again
+const DefaultDirectoryTTL = 5 * time.Minute
+
+// UserDirectory a snapshot of every user, indexed for lookups.
+// The Domo API can't filter users, so lookups by email or employee number are answered from one.
+// Nothing stops two users sharing an alternate email or employee number, so each index keeps
+// every user with a value, and a lookup that finds more than one is an AmbiguousError.
+type UserDirectory struct {
+	Users    []User    // Every user, in the order Domo listed them
+	Loaded   time.Time // When the snapshot was taken
+	email    map[string][]int
+	alt      map[string][]int
+	employee map[int][]int
+}
+
+// NewUserDirectory indexes the users
+func NewUserDirectory(users []User) *UserDirectory {
+	d := &UserDirectory{
+		Users:    users,
+		Loaded:   time.Now(),
+		email:    make(map[string][]int),
+		alt:      make(map[string][]int),
+		employee: make(map[int][]int),
+	}
+	for i, user := range users {
+		if user.Email != "" {
+			key := strings.ToLower(user.Email)
+			d.email[key] = append(d.email[key], i)
+		}
+		if user.AlternateEmail != "" {
+			key := strings.ToLower(user.AlternateEmail)
+			d.alt[key] = append(d.alt[key], i)
+		}
+		if user.EmployeeNumber != 0 {
+			d.employee[user.EmployeeNumber] = append(d.employee[user.EmployeeNumber], i)
+		}
+	}
+	return d
+}
+
+// ByEmail the user whose primary email is email, ignoring case
+func (d *UserDirectory) ByEmail(email string) (User, error) {
+	return d.lookup(d.email[strings.ToLower(strings.TrimSpace(email))], "email", email)
+}
+
+// ByAlternateEmail the only user whose secondary email is email, ignoring case, or an AmbiguousError listing them all
+func (d *UserDirectory) ByAlternateEmail(email string) (User, error) {
+	return d.lookup(d.alt[strings.ToLower(strings.TrimSpace(email))], "alternate email", email)
+}
+
+// ByEmployeeNumber the only user with the employee number, or an AmbiguousError listing them all
+func (d *UserDirectory) ByEmployeeNumber(number int) (User, error) {
+	return d.lookup(d.employee[number], "employee number", fmt.Sprint(number))
+}
+
+// ByName the only user with the name, ignoring case and surrounding spaces, or an AmbiguousError listing them all
+func (d *UserDirectory) ByName(name string) (User, error) {
+	var candidates []Candidate
+	found := make(map[int]User)
+	for _, user := range d.Users {
+		if strings.EqualFold(strings.TrimSpace(user.Name), strings.TrimSpace(name)) {
+			candidates = append(candidates, Candidate{ID: user.ID, Name: user.Email})
+			found[user.ID] = user
+		}
+	}
+	id, err := lookupOne("user", "name", name, candidates)
+	return found[id], err
+}
+
+// Search the users whose names or emails match a pattern, see UserService.Search
+func (d *UserDirectory) Search(pattern string) (users []User) {
+	match := matcher(pattern)
+	for _, user := range d.Users {
+		if match(user.Name) || match(user.Email) {
+			users = append(users, user)
+		}
+	}
+	return
+}
+
+// lookup the only user of the matches, which are indexes into Users
+func (d *UserDirectory) lookup(matches []int, field string, value string) (User, error) {
+	candidates := make([]Candidate, len(matches))
+	for i, match := range matches {
+		candidates[i] = Candidate{ID: d.Users[match].ID, Name: d.Users[match].Email}
+	}
+	if _, err := lookupOne("user", field, value, candidates); err != nil {
+		return User{}, err
+	}
+	return d.Users[matches[0]], nil
+}
+
+// directoryCache the snapshot lookups share, read again once it is older than ttl
+type directoryCache struct {
+	sync.Mutex
+	directory *UserDirectory
+	ttl       time.Duration
+}
+
+// SetDirectoryTTL sets how long the snapshot behind the user lookups is kept, 0 reads every time
+//
+// The snapshot is kept for DefaultDirectoryTTL by default, and dropped whenever this client changes a user
+func (d *Client) SetDirectoryTTL(ttl time.Duration) {
+	d.directory.Lock()
+	defer d.directory.Unlock()
+	d.directory.ttl = ttl
+	d.directory.directory = nil
+}
+
+// Directory Get a snapshot of every user, indexed by email, alternate email and employee number.
+// The snapshot is cached, see Client.SetDirectoryTTL.
+func (u *UserService) Directory(ctx context.Context) (*UserDirectory, error) {
+	cache := &u.client.directory
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.directory != nil && time.Since(cache.directory.Loaded) < cache.ttl {
+		return cache.directory, nil
+	}
+
+	users, err := u.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache.directory = NewUserDirectory(users)
+	return cache.directory, nil
+}
+
+// forget drops the cached snapshot after a user has been changed
+func (c *directoryCache) forget() {
+	c.Lock()
+	defer c.Unlock()
+	c.directory = nil
+}
+
+// FindByEmail locates a user by primary email, ignoring case, from your Domo instance.
+// Returns
+// Returns the user, or a NotFoundError if no user has the email
+func (u *UserService) FindByEmail(ctx context.Context, email string) (user User, err error) {
+	directory, err := u.Directory(ctx)
+	if err != nil {
+		return
+	}
+	return directory.ByEmail(email)
+}
+
+// FindByAlternateEmail locates a user by secondary email, ignoring case, from your Domo instance.
+// Returns
+// Returns the user, a NotFoundError if no user has the email,
+// or an AmbiguousError listing the users if several have it
+func (u *UserService) FindByAlternateEmail(ctx context.Context, email string) (user User, err error) {
+	directory, err := u.Directory(ctx)
+	if err != nil {
+		return
+	}
+	return directory.ByAlternateEmail(email)
+}
+
+// FindByEmployeeNumber locates a user by employee number from your Domo instance.
+// Returns
+// Returns the user, a NotFoundError if no user has the number,
+// or an AmbiguousError listing the users if several have it
+func (u *UserService) FindByEmployeeNumber(ctx context.Context, number int) (user User, err error) {
+	directory, err := u.Directory(ctx)
+	if err != nil {
+		return
+	}
+	return directory.ByEmployeeNumber(number)
+}
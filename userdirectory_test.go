@@ -0,0 +1,85 @@
+package domo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func directoryTestDoer() *routeDoer {
+	return &routeDoer{routes: map[string]testDoer{
+		"GET /oauth/token": tokenRoute,
+		"GET /v1/users": {responseCode: 200, response: `[
+			{"id": 1, "name": "Ann", "email": "Ann@Example.com", "alternateEmail": "ann@old.example.com", "employeeNumber": 1001},
+			{"id": 2, "name": "Bob", "email": "bob@example.com"}
+		]`},
+		"GET /v1/users/2": {responseCode: 200, response: `{"id": 2, "name": "Bob", "email": "bob@example.com", "role": "Participant"}`},
+		"PUT /v1/users/2": {responseCode: 200, response: `{"id": 2, "name": "Bob", "email": "bob@example.com", "employeeNumber": 1002}`},
+	}}
+}
+
+func TestUserService_FindByEmail(t *testing.T) {
+	doer := directoryTestDoer()
+	d := CreateTestClient(doer)
+	ctx := context.Background()
+
+	user, err := d.User.FindByEmail(ctx, " ann@EXAMPLE.com")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 1, user.ID, "Bad user by email")
+
+	user, err = d.User.FindByAlternateEmail(ctx, "ANN@old.example.com")
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 1, user.ID, "Bad user by alternate email")
+
+	user, err = d.User.FindByEmployeeNumber(ctx, 1001)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 1, user.ID, "Bad user by employee number")
+
+	_, err = d.User.FindByEmail(ctx, "cat@example.com")
+	assert.Equal(t, true, errors.Is(err, ErrNotFound), "Bad not found")
+	assert.Equal(t, "No user with email cat@example.com", err.Error(), "Bad message")
+	_, err = d.User.FindByEmployeeNumber(ctx, 1002)
+	var notFound *NotFoundError
+	assert.Equal(t, true, errors.As(err, &notFound), "Bad error type")
+	assert.Equal(t, "employee number", notFound.Field, "Bad field")
+
+	assert.Equal(t, 1, doer.count("GET /v1/users"), "Snapshot not cached")
+
+	_, err = d.User.Update(ctx, 2, UserPatch{EmployeeNumber: Int(1002)})
+	assert.Equal(t, nil, err, "Bad error code")
+	d.User.FindByEmail(ctx, "bob@example.com")
+	assert.Equal(t, 2, doer.count("GET /v1/users"), "Snapshot kept after an update")
+
+	d.SetDirectoryTTL(0)
+	d.User.FindByEmail(ctx, "bob@example.com")
+	d.User.FindByEmail(ctx, "bob@example.com")
+	assert.Equal(t, 4, doer.count("GET /v1/users"), "Snapshot cached with no TTL")
+
+	d.SetDirectoryTTL(time.Hour)
+	directory, err := d.User.Directory(ctx)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 2, len(directory.Users), "Bad user count")
+}
+
+func TestUserDirectory_ambiguous(t *testing.T) {
+	directory := NewUserDirectory([]User{
+		{ID: 1, Email: "ann@example.com", AlternateEmail: "shared@example.com", EmployeeNumber: 1001},
+		{ID: 2, Email: "bob@example.com", AlternateEmail: "Shared@example.com", EmployeeNumber: 1001},
+		{ID: 3, Email: "cy@example.com", EmployeeNumber: 1003},
+	})
+
+	_, err := directory.ByAlternateEmail("shared@example.com")
+	var ambiguous *AmbiguousError
+	assert.Equal(t, true, errors.As(err, &ambiguous), "Bad error type")
+	assert.Equal(t, 2, len(ambiguous.Candidates), "Bad candidates")
+	_, err = directory.ByEmployeeNumber(1001)
+	assert.Equal(t, true, errors.Is(err, ErrAmbiguous), "Shared employee number not reported")
+	assert.Equal(t, "2 users have employee number 1001: ann@example.com (1), bob@example.com (2)", err.Error(), "Bad message")
+
+	user, err := directory.ByEmployeeNumber(1003)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 3, user.ID, "Bad user by employee number")
+}
@@ -0,0 +1,381 @@
+package usersync
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// csvColumns the columns Export writes and Import reads, in order. Import only needs email and name.
+var csvColumns = []string{"id", "email", "name", "role", "title", "phone", "location", "alternate_email", "employee_number", "groups"}
+
+// groupSeparator separates the group names in the groups column
+const groupSeparator = ";"
+
+// Row a user read from a CSV file, with its line number
+type Row struct {
+	Line int
+	User User
+	Err  error // Why the row is invalid
+}
+
+// ReadCSV reads users from CSV with a header row naming the columns, in any order and any case.
+// Groups are separated by semicolons. Every row is checked, and the invalid ones are returned with
+// the reason, so the whole file can be fixed at once. Roles other than the defaults are custom roles,
+// which Import looks up by name.
+func ReadCSV(r io.Reader) (rows []Row, err error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the CSV header %s", err)
+	}
+
+	column := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known(name) {
+			return nil, fmt.Errorf("Unknown column %s", name)
+		}
+		column[name] = i
+	}
+	if _, ok := column["email"]; !ok {
+		return nil, fmt.Errorf("The CSV needs an email column")
+	}
+
+	seen := make(map[string]int)
+	for line := 2; ; line++ {
+		record, rerr := reader.Read()
+		if rerr == io.EOF {
+			return rows, nil
+		}
+		if rerr != nil {
+			return rows, fmt.Errorf("Unable to read line %d %s", line, rerr)
+		}
+
+		row := Row{Line: line}
+		field := func(name string) string {
+			if i, ok := column[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row.User = User{
+			Email:          field("email"),
+			Name:           field("name"),
+			Role:           domo.Role(field("role")),
+			Title:          field("title"),
+			Phone:          field("phone"),
+			Location:       field("location"),
+			AlternateEmail: field("alternate_email"),
+		}
+		for _, group := range strings.Split(field("groups"), groupSeparator) {
+			if group = strings.TrimSpace(group); group != "" {
+				row.User.Groups = append(row.User.Groups, group)
+			}
+		}
+		if number := field("employee_number"); number != "" {
+			row.User.EmployeeNumber, rerr = strconv.Atoi(number)
+			if rerr != nil {
+				row.Err = fmt.Errorf("employee number %s is not a number", number)
+			}
+		}
+
+		key := strings.ToLower(row.User.Email)
+		switch {
+		case row.Err != nil:
+		case row.User.Email == "" || !strings.Contains(row.User.Email, "@"):
+			row.Err = fmt.Errorf("email %q is not valid", row.User.Email)
+		case row.User.Name == "":
+			row.Err = fmt.Errorf("name is missing")
+		case seen[key] != 0:
+			row.Err = fmt.Errorf("%s is also on line %d", row.User.Email, seen[key])
+		}
+		if seen[key] == 0 {
+			seen[key] = line
+		}
+		rows = append(rows, row)
+	}
+}
+
+// known whether a column is one Import reads
+func known(name string) bool {
+	for _, column := range csvColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportOptions controls a bulk import
+type ImportOptions struct {
+	Parallel     int       // Number of users created or updated at the same time, 4 by default
+	DefaultRole  domo.Role // Role of new users without one, Participant when empty
+	CreateGroups bool      // Create groups that don't exist, rather than failing the rows that name them
+	SendInvite   bool      // Email an invite to new users
+}
+
+// Result what happened to one row of an import
+type Result struct {
+	Line   int
+	Email  string
+	Action string   // created, updated, unchanged, invalid or failed
+	UserID int      // The Domo id of the user
+	Fields []string // The fields an update changed
+	Groups []string // The groups the user was added to
+	Err    error
+}
+
+// Import creates the users in rows that don't exist and updates the ones that do, matching on email,
+// then adds each to its groups. Groups are only ever added to, never taken away. Invalid rows are
+// reported and skipped. The results are in the order of the rows.
+func Import(ctx context.Context, client *domo.Client, rows []Row, opts ImportOptions) (results []Result, err error) {
+	directory, err := client.User.Directory(ctx)
+	if err != nil {
+		return
+	}
+	groups, err := importGroups(ctx, client, rows, opts.CreateGroups)
+	if err != nil {
+		return
+	}
+	roles, err := importRoles(ctx, client, rows)
+	if err != nil {
+		return
+	}
+
+	workers := opts.Parallel
+	if workers < 1 {
+		workers = 4
+	}
+	results = make([]Result, len(rows))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = importRow(ctx, client, directory, groups, roles, rows[i], opts)
+			}
+		}()
+	}
+	for i := range rows {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		err = fmt.Errorf("%d of %d rows failed", failed, len(rows))
+	}
+	return
+}
+
+// importGroups every group with each name, by lower case name, creating missing ones the valid rows
+// name if asked. Domo lets groups share a name, so a name can have more than one.
+func importGroups(ctx context.Context, client *domo.Client, rows []Row, create bool) (map[string][]domo.Candidate, error) {
+	groups := make(map[string][]domo.Candidate)
+	found, err := client.Group.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list groups %s", err)
+	}
+	for _, group := range found {
+		key := strings.ToLower(group.Name)
+		groups[key] = append(groups[key], domo.Candidate{ID: group.ID, Name: group.Name})
+	}
+	if !create {
+		return groups, nil
+	}
+
+	for _, row := range rows {
+		if row.Err != nil {
+			continue
+		}
+		for _, name := range row.User.Groups {
+			if len(groups[strings.ToLower(name)]) > 0 {
+				continue
+			}
+			group, err := client.Group.Create(ctx, domo.GroupCreate{Name: name})
+			if err != nil {
+				return nil, err
+			}
+			groups[strings.ToLower(name)] = []domo.Candidate{{ID: group.ID, Name: group.Name}}
+		}
+	}
+	return groups, nil
+}
+
+// groupID the id of the only group with the name, or the error saying why there isn't one
+func groupID(groups map[string][]domo.Candidate, name string) (int, error) {
+	candidates := groups[strings.ToLower(name)]
+	switch len(candidates) {
+	case 0:
+		return 0, fmt.Errorf("there is no group %s", name)
+	case 1:
+		return candidates[0].ID, nil
+	}
+	return 0, &domo.AmbiguousError{Kind: "group", Field: "name", Value: name, Candidates: candidates}
+}
+
+// importRoles the ids of the custom roles the valid rows name, by lower case name. Domo is only asked
+// for its roles when a row names one that isn't a default.
+func importRoles(ctx context.Context, client *domo.Client, rows []Row) (map[string]int, error) {
+	roles := make(map[string]int)
+	custom := false
+	for _, row := range rows {
+		custom = custom || (row.Err == nil && row.User.Role != "" && !row.User.Role.Valid())
+	}
+	if !custom {
+		return roles, nil
+	}
+
+	found, err := client.Role.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range found {
+		roles[strings.ToLower(string(role.Name))] = role.ID
+	}
+	return roles, nil
+}
+
+// importRow creates or updates the row's user and adds it to its groups
+func importRow(ctx context.Context, client *domo.Client, directory *domo.UserDirectory, groups map[string][]domo.Candidate, roles map[string]int, row Row, opts ImportOptions) (result Result) {
+	result = Result{Line: row.Line, Email: row.User.Email}
+	if row.Err != nil {
+		result.Action, result.Err = "invalid", row.Err
+		return
+	}
+	groupIDs := make([]int, len(row.User.Groups))
+	for i, name := range row.User.Groups {
+		if groupIDs[i], result.Err = groupID(groups, name); result.Err != nil {
+			result.Action = "invalid"
+			return
+		}
+	}
+
+	// A custom role is set by id, so the user is compared and created without its name
+	user, roleID := row.User, 0
+	if user.Role != "" && !user.Role.Valid() {
+		if roleID = roles[strings.ToLower(string(user.Role))]; roleID == 0 {
+			result.Action, result.Err = "invalid", fmt.Errorf("there is no role %s", user.Role)
+			return
+		}
+		user.Role = ""
+	}
+
+	syncOpts := Options{DefaultRole: opts.DefaultRole, SendInvite: opts.SendInvite}
+	existing, err := directory.ByEmail(row.User.Email)
+	switch {
+	case errors.Is(err, domo.ErrNotFound):
+		create := newUser(user, syncOpts)
+		if roleID != 0 {
+			create.Role, create.RoleID = "", roleID
+		}
+		var created domo.User
+		created, err = client.User.Create(ctx, create)
+		result.Action, result.UserID = "created", created.ID
+	case err == nil:
+		result.Action, result.UserID = "unchanged", existing.ID
+		change, changed := compare(existing, user, syncOpts)
+		if roleID != 0 && roleID != existing.RoleID {
+			change.patch.RoleID = domo.Int(roleID)
+			change.Fields = append(change.Fields, "role")
+			changed = true
+		}
+		if changed {
+			_, err = client.User.Update(ctx, existing.ID, change.patch)
+			result.Action, result.Fields = "updated", change.Fields
+		}
+	}
+	if err != nil {
+		result.Action, result.Err = "failed", err
+		return
+	}
+
+	for i, name := range row.User.Groups {
+		if err = client.Group.AddUsers(ctx, groupIDs[i], []int{result.UserID}); err != nil {
+			result.Action, result.Err = "failed", fmt.Errorf("unable to add to %s %s", name, err)
+			return
+		}
+		result.Groups = append(result.Groups, name)
+	}
+	return
+}
+
+// WriteResults writes the results of an import as CSV, one line per row
+func WriteResults(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"line", "email", "result", "id", "changed", "groups", "error"})
+	for _, result := range results {
+		id, reason := "", ""
+		if result.UserID != 0 {
+			id = strconv.Itoa(result.UserID)
+		}
+		if result.Err != nil {
+			reason = result.Err.Error()
+		}
+		writer.Write([]string{
+			strconv.Itoa(result.Line), result.Email, result.Action, id,
+			strings.Join(result.Fields, groupSeparator), strings.Join(result.Groups, groupSeparator), reason,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Export writes every user and the groups they are in as CSV, in the columns ReadCSV reads.
+// Custom roles are written by name, as Import looks them up.
+func Export(ctx context.Context, client *domo.Client, w io.Writer) error {
+	users, err := client.User.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	groups, err := client.Group.ListAll(ctx)
+	if err != nil {
+		return fmt.Errorf("Unable to list groups %s", err)
+	}
+	memberOf := make(map[int][]string)
+	for _, group := range groups {
+		ids, err := client.Group.ListAllUsers(ctx, group.ID)
+		if err != nil {
+			return fmt.Errorf("Unable to list members of %s %s", group.Name, err)
+		}
+		for _, id := range ids {
+			memberOf[id] = append(memberOf[id], group.Name)
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write(csvColumns)
+	for _, user := range users {
+		number := ""
+		if user.EmployeeNumber != 0 {
+			number = strconv.Itoa(user.EmployeeNumber)
+		}
+		names := memberOf[user.ID]
+		sort.Strings(names)
+		writer.Write([]string{
+			strconv.Itoa(user.ID), user.Email, user.Name, string(user.Role), user.Title, user.Phone,
+			user.Location, user.AlternateEmail, number, strings.Join(names, groupSeparator),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
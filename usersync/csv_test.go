@@ -0,0 +1,142 @@
+package usersync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/davecb/domoStreamApi/domotest"
+	"github.com/stretchr/testify/assert"
+)
+
+const importCSV = `Email,Name,Role,Title,Groups,Employee_Number
+ann@example.com,Ann,,Lead Analyst,Finance;Sales,
+dan@example.com,"O'Neil, Dan",Privileged,,Sales,1004
+bad-email,Eve,,,,
+fay@example.com,Fay,Boss,,,
+gus@example.com,Gus,,,,12x
+DAN@example.com,Dan Again,,,,
+hal@example.com,Hal,,,Nowhere,
+`
+
+func TestReadCSV(t *testing.T) {
+	rows, err := ReadCSV(strings.NewReader(importCSV))
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 7, len(rows), "Bad row count")
+	assert.Equal(t, []string{"Finance", "Sales"}, rows[0].User.Groups, "Bad groups")
+	assert.Equal(t, "O'Neil, Dan", rows[1].User.Name, "Bad quoted name")
+	assert.Equal(t, 1004, rows[1].User.EmployeeNumber, "Bad employee number")
+
+	var invalid []int
+	for _, row := range rows {
+		if row.Err != nil {
+			invalid = append(invalid, row.Line)
+		}
+	}
+	assert.Equal(t, []int{4, 6, 7}, invalid, "Bad invalid rows")
+	assert.Equal(t, domo.Role("Boss"), rows[3].User.Role, "Custom role refused")
+	assert.Equal(t, "DAN@example.com is also on line 3", rows[5].Err.Error(), "Bad duplicate")
+
+	_, err = ReadCSV(strings.NewReader("email,shoe size\n"))
+	assert.NotEqual(t, nil, err, "Unknown column accepted")
+	_, err = ReadCSV(strings.NewReader("name\nAnn\n"))
+	assert.NotEqual(t, nil, err, "Missing email column accepted")
+}
+
+func TestImportExport(t *testing.T) {
+	server := domotest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	ann := server.AddUser(domo.User{Name: "Ann", Email: "Ann@Example.com", Role: domo.RoleParticipant, Title: "Analyst"})
+	server.AddGroup(domo.Group{Name: "Sales"})
+
+	rows, err := ReadCSV(strings.NewReader(importCSV))
+	assert.Equal(t, nil, err, "Bad error code")
+	results, err := Import(context.Background(), client, rows, ImportOptions{Parallel: 3, CreateGroups: true})
+	assert.NotEqual(t, nil, err, "Invalid rows not reported")
+
+	var actions []string
+	for _, result := range results {
+		actions = append(actions, result.Action)
+	}
+	assert.Equal(t, []string{"updated", "created", "invalid", "invalid", "invalid", "invalid", "created"}, actions, "Bad actions")
+	assert.Equal(t, ann.ID, results[0].UserID, "Bad id for Ann")
+	assert.Equal(t, []string{"title"}, results[0].Fields, "Bad fields for Ann")
+	assert.Equal(t, []string{"Finance", "Sales"}, results[0].Groups, "Bad groups for Ann")
+
+	var report bytes.Buffer
+	assert.Equal(t, nil, WriteResults(&report, results), "Bad error code")
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	assert.Equal(t, 8, len(lines), "Bad report length")
+	assert.Equal(t, "line,email,result,id,changed,groups,error", lines[0], "Bad report header")
+	assert.Equal(t, `4,bad-email,invalid,,,,"email ""bad-email"" is not valid"`, lines[3], "Bad invalid line")
+
+	var out bytes.Buffer
+	assert.Equal(t, nil, Export(context.Background(), client, &out), "Bad error code")
+	exported := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, "id,email,name,role,title,phone,location,alternate_email,employee_number,groups", exported[0], "Bad export header")
+	assert.Equal(t, 4, len(exported), "Bad export length")
+	assert.Equal(t, true, strings.HasSuffix(exported[1], ",Ann@Example.com,Ann,Participant,Lead Analyst,,,,,Finance;Sales"), "Bad export of Ann")
+
+	rows, err = ReadCSV(strings.NewReader(out.String()))
+	assert.Equal(t, nil, err, "Export not readable")
+	results, err = Import(context.Background(), client, rows, ImportOptions{})
+	assert.Equal(t, nil, err, "Bad error code")
+	for _, result := range results {
+		assert.Equal(t, "unchanged", result.Action, "Round trip changed "+result.Email)
+	}
+}
+
+func TestImport_customRole(t *testing.T) {
+	server := domotest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	auditor := server.AddRole(domo.RoleDetail{Name: "Auditor"})
+	ann := server.AddUser(domo.User{Name: "Ann", Email: "ann@example.com", Role: domo.RoleParticipant})
+
+	csv := "email,name,role\nann@example.com,Ann,auditor\nbob@example.com,Bob,Auditor\ncy@example.com,Cy,Boss\n"
+	rows, err := ReadCSV(strings.NewReader(csv))
+	assert.Equal(t, nil, err, "Bad error code")
+	results, err := Import(context.Background(), client, rows, ImportOptions{})
+	assert.NotEqual(t, nil, err, "Unknown role not reported")
+	assert.Equal(t, "updated", results[0].Action, "Bad action for Ann")
+	assert.Equal(t, []string{"role"}, results[0].Fields, "Bad fields for Ann")
+	assert.Equal(t, "created", results[1].Action, "Bad action for Bob")
+	assert.Equal(t, "invalid", results[2].Action, "Bad action for Cy")
+	assert.Equal(t, "there is no role Boss", results[2].Err.Error(), "Bad error for Cy")
+
+	user, _ := client.User.Retrieve(ann.ID)
+	assert.Equal(t, auditor.ID, user.RoleID, "Bad role for Ann")
+
+	var out bytes.Buffer
+	assert.Equal(t, nil, Export(context.Background(), client, &out), "Bad error code")
+	assert.Equal(t, true, strings.Contains(out.String(), ",ann@example.com,Ann,Auditor,"), "Custom role not exported by name")
+	rows, err = ReadCSV(strings.NewReader(out.String()))
+	assert.Equal(t, nil, err, "Export not readable")
+	results, err = Import(context.Background(), client, rows, ImportOptions{})
+	assert.Equal(t, nil, err, "Bad error code")
+	for _, result := range results {
+		assert.Equal(t, "unchanged", result.Action, "Round trip changed "+result.Email)
+	}
+}
+
+func TestImport_ambiguousGroup(t *testing.T) {
+	server := domotest.NewServer()
+	defer server.Close()
+	server.AddGroup(domo.Group{Name: "Sales"})
+	server.AddGroup(domo.Group{Name: "sales"})
+	server.AddGroup(domo.Group{Name: "Finance"})
+
+	rows, err := ReadCSV(strings.NewReader("email,name,groups\nann@example.com,Ann,Sales\nbob@example.com,Bob,Finance\n"))
+	assert.Equal(t, nil, err, "Bad error code")
+	results, err := Import(context.Background(), server.Client(), rows, ImportOptions{CreateGroups: true})
+	assert.NotEqual(t, nil, err, "Shared group name not reported")
+	assert.Equal(t, "invalid", results[0].Action, "Row naming a shared group imported")
+	assert.Equal(t, true, errors.Is(results[0].Err, domo.ErrAmbiguous), "Bad error for Ann")
+	assert.Equal(t, "created", results[1].Action, "Bad action for Bob")
+	groups, _ := server.Client().Group.ListAll(context.Background())
+	assert.Equal(t, 3, len(groups), "Shared group name created again")
+}
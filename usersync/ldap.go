@@ -0,0 +1,157 @@
+//go:build ldap
+// +build ldap
+
+package usersync
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPAttributes the directory attributes each user field is read from
+type LDAPAttributes struct {
+	Email          string // mail by default
+	Name           string // displayName by default, falling back to cn
+	Title          string // title by default
+	Phone          string // telephoneNumber by default
+	Location       string // l by default
+	EmployeeNumber string // employeeNumber by default
+	MemberOf       string // memberOf by default, holding the DNs of the user's groups
+}
+
+// LDAP a Source that reads users from an LDAP or Active Directory server.
+// A user's groups are the common names of the groups in their memberOf attribute.
+type LDAP struct {
+	URL        string               // Server address such as ldaps://dc.example.com:636
+	BindDN     string               // DN to bind as, an anonymous bind when empty
+	Password   string               // Password for BindDN
+	BaseDN     string               // Where to search for users
+	Filter     string               // Search filter, (&(objectClass=person)(mail=*)) by default
+	Attributes LDAPAttributes       // Attribute names, for directories that don't use the defaults
+	Roles      map[string]domo.Role // Optional roles given to members of a group, by group name
+	TLS        *tls.Config          // Optional TLS settings for ldaps:// addresses
+	PageSize   uint32               // Entries asked for at a time, 500 by default
+}
+
+// Users searches the directory for users, a page at a time
+func (l *LDAP) Users(ctx context.Context) (users []User, err error) {
+	attrs := l.attributes()
+	filter := l.Filter
+	if filter == "" {
+		filter = "(&(objectClass=person)(mail=*))"
+	}
+	pageSize := l.PageSize
+	if pageSize == 0 {
+		pageSize = 500
+	}
+
+	conn, err := ldap.DialURL(l.URL, ldap.DialWithTLSConfig(l.TLS))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to %s %s", l.URL, err)
+	}
+	defer conn.Close()
+
+	if l.BindDN != "" {
+		err = conn.Bind(l.BindDN, l.Password)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to bind to %s %s", l.URL, err)
+	}
+
+	request := ldap.NewSearchRequest(l.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, filter,
+		[]string{attrs.Email, attrs.Name, "cn", attrs.Title, attrs.Phone, attrs.Location, attrs.EmployeeNumber, attrs.MemberOf}, nil)
+	result, err := conn.SearchWithPaging(request, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to search %s %s", l.BaseDN, err)
+	}
+
+	for _, entry := range result.Entries {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		users = append(users, l.user(entry, attrs))
+	}
+	return
+}
+
+// user maps a directory entry onto a user
+func (l *LDAP) user(entry *ldap.Entry, attrs LDAPAttributes) User {
+	user := User{
+		Email:    entry.GetAttributeValue(attrs.Email),
+		Name:     entry.GetAttributeValue(attrs.Name),
+		Title:    entry.GetAttributeValue(attrs.Title),
+		Phone:    entry.GetAttributeValue(attrs.Phone),
+		Location: entry.GetAttributeValue(attrs.Location),
+	}
+	if user.Name == "" {
+		user.Name = entry.GetAttributeValue("cn")
+	}
+	user.EmployeeNumber, _ = strconv.Atoi(entry.GetAttributeValue(attrs.EmployeeNumber))
+
+	for _, dn := range entry.GetAttributeValues(attrs.MemberOf) {
+		name := commonName(dn)
+		if name == "" {
+			continue
+		}
+		user.Groups = append(user.Groups, name)
+		if role, ok := l.Roles[name]; ok && rank(role) > rank(user.Role) {
+			user.Role = role
+		}
+	}
+	return user
+}
+
+// attributes the attribute names, with the defaults filled in
+func (l *LDAP) attributes() LDAPAttributes {
+	attrs := l.Attributes
+	fill := func(name *string, value string) {
+		if *name == "" {
+			*name = value
+		}
+	}
+	fill(&attrs.Email, "mail")
+	fill(&attrs.Name, "displayName")
+	fill(&attrs.Title, "title")
+	fill(&attrs.Phone, "telephoneNumber")
+	fill(&attrs.Location, "l")
+	fill(&attrs.EmployeeNumber, "employeeNumber")
+	fill(&attrs.MemberOf, "memberOf")
+	return attrs
+}
+
+// commonName the value of the first CN in a DN, such as Sales in CN=Sales,OU=Groups,DC=example,DC=com
+func commonName(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return ""
+	}
+	for _, rdn := range parsed.RDNs {
+		for _, attr := range rdn.Attributes {
+			if strings.EqualFold(attr.Type, "cn") {
+				return attr.Value
+			}
+		}
+	}
+	return ""
+}
+
+// rank orders the default roles so a member of several role groups gets the most powerful
+func rank(role domo.Role) int {
+	switch role {
+	case domo.RoleAdmin:
+		return 3
+	case domo.RolePrivileged:
+		return 2
+	case domo.RoleParticipant:
+		return 1
+	}
+	return 0
+}
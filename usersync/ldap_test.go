@@ -0,0 +1,45 @@
+//go:build ldap
+// +build ldap
+
+package usersync
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommonName(t *testing.T) {
+	assert.Equal(t, "Sales", commonName("CN=Sales,OU=Groups,DC=example,DC=com"), "Bad CN")
+	assert.Equal(t, "", commonName("OU=Groups,DC=example,DC=com"), "Bad DN without CN")
+	assert.Equal(t, "", commonName("not a dn"), "Bad DN")
+}
+
+// TestLDAP_Users reads from a local directory, such as an OpenLDAP container, named by
+// DOMO_LDAP_URL, DOMO_LDAP_BASE_DN, DOMO_LDAP_BIND_DN and DOMO_LDAP_PASSWORD.
+// The directory must hold at least one person with a mail attribute.
+func TestLDAP_Users(t *testing.T) {
+	url := os.Getenv("DOMO_LDAP_URL")
+	if url == "" {
+		t.Skip("DOMO_LDAP_URL not set")
+	}
+
+	source := &LDAP{
+		URL:      url,
+		BaseDN:   os.Getenv("DOMO_LDAP_BASE_DN"),
+		BindDN:   os.Getenv("DOMO_LDAP_BIND_DN"),
+		Password: os.Getenv("DOMO_LDAP_PASSWORD"),
+		Roles:    map[string]domo.Role{"Domo Admins": domo.RoleAdmin},
+		PageSize: 2,
+	}
+	users, err := source.Users(context.Background())
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.NotEqual(t, 0, len(users), "No users found")
+	for _, user := range users {
+		assert.NotEqual(t, "", user.Email, "User without email")
+		assert.NotEqual(t, "", user.Name, "User without name")
+	}
+}
@@ -0,0 +1,587 @@
+// Package usersync brings the users and groups of a Domo instance in line with a directory such as LDAP
+// or Active Directory.
+//
+// A Source lists the users that should exist and the groups they belong in. Plan compares them with
+// User.ListAll and Group.ListUsers and works out the users to create, update and remove and the group
+// memberships to add and take away. Run checks the plan against the safety threshold and applies it,
+// unless it is a dry run.
+//
+// Users are matched on email, ignoring case. Only the groups the sync manages are touched, so groups
+// maintained by hand are left alone.
+//
+// Import and Export move users and their groups in and out of a Domo instance as CSV, for bulk
+// onboarding where there is no directory to sync from.
+package usersync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	domo "github.com/davecb/domoStreamApi"
+)
+
+// DefaultMaxRemoved the share of Domo users a sync may remove when Options doesn't say
+const DefaultMaxRemoved = 0.05
+
+// User a user as the directory describes them. Empty fields are left as they are in Domo.
+type User struct {
+	Email          string    // Matched against Domo users ignoring case
+	Name           string    // Full name
+	Title          string    // Job title
+	Phone          string    // Primary phone number
+	Location       string    // Office location
+	AlternateEmail string    // Secondary email
+	EmployeeNumber int       // Employee number within the company
+	Role           domo.Role // Role the user should have, Options.DefaultRole for new users when empty
+	Groups         []string  // Names of the groups the user belongs in
+}
+
+// Source lists the users that should exist in Domo
+type Source interface {
+	Users(ctx context.Context) ([]User, error)
+}
+
+// StaticSource a Source holding a fixed list of users
+type StaticSource []User
+
+// Users the list
+func (s StaticSource) Users(ctx context.Context) ([]User, error) {
+	return s, nil
+}
+
+// Removal what happens to Domo users the source no longer lists
+type Removal int
+
+const (
+	// Deactivate takes the user out of every managed group and gives them Options.DeactivatedRoleID,
+	// which it needs, as a user outside the managed groups would otherwise keep their access
+	Deactivate Removal = iota
+	// Delete deletes the user, which can't be undone
+	Delete
+	// Keep leaves the user alone
+	Keep
+)
+
+// Options controls what a sync manages and how far it may go
+type Options struct {
+	Groups            []string  // Groups the sync manages, every group the source names when empty
+	DefaultRole       domo.Role // Role of new users the source gives none, Participant when empty
+	Removal           Removal   // What happens to Domo users missing from the source
+	DeactivatedRoleID int       // Role, usually a custom one without access, given to deactivated users; needed to Deactivate
+	Protected         []string  // Emails of users the sync never touches, such as service accounts
+	MaxRemoved        float64   // Largest share of Domo users that may be removed, DefaultMaxRemoved when 0
+	SendInvite        bool      // Email an invite to new users
+	DryRun            bool      // Plan the changes and report them without making any
+}
+
+// Action the kind of a Change
+type Action string
+
+// Actions in the order they are applied
+const (
+	CreateGroup     Action = "create group"
+	CreateUser      Action = "create"
+	UpdateUser      Action = "update"
+	AddToGroup      Action = "add"
+	RemoveFromGroup Action = "remove"
+	DeactivateUser  Action = "deactivate"
+	DeleteUser      Action = "delete"
+)
+
+// Change one thing a sync does
+type Change struct {
+	Action Action
+	Email  string   // The user changed, empty for CreateGroup
+	UserID int      // The Domo id of the user, 0 until a new user is created
+	Group  string   // The group created, added to or removed from
+	Fields []string // The fields an UpdateUser changes
+	Err    error    // Why the change failed, once it has been applied
+
+	create  domo.UserCreate
+	patch   domo.UserPatch
+	groupID int
+}
+
+// String describes the change, as in "update ann@example.com (title, phone)"
+func (c Change) String() string {
+	switch c.Action {
+	case CreateGroup:
+		return fmt.Sprintf("%s %s", c.Action, c.Group)
+	case AddToGroup:
+		return fmt.Sprintf("%s %s to %s", c.Action, c.Email, c.Group)
+	case RemoveFromGroup:
+		return fmt.Sprintf("%s %s from %s", c.Action, c.Email, c.Group)
+	case UpdateUser:
+		return fmt.Sprintf("%s %s (%s)", c.Action, c.Email, strings.Join(c.Fields, ", "))
+	}
+	return fmt.Sprintf("%s %s", c.Action, c.Email)
+}
+
+// Report what a sync did, or would do on a dry run
+type Report struct {
+	Changes   []Change // Every change, in the order it was applied
+	Total     int      // Domo users the sync could remove, which leaves out protected ones
+	Unchanged int      // Users the source lists that needed no change
+	Failed    int      // Changes that couldn't be made
+	DryRun    bool     // Whether the changes were only planned
+}
+
+// Count the number of changes of a kind
+func (r Report) Count(action Action) (n int) {
+	for _, change := range r.Changes {
+		if change.Action == action {
+			n++
+		}
+	}
+	return
+}
+
+// Removed the number of users deactivated or deleted
+func (r Report) Removed() int {
+	return r.Count(DeactivateUser) + r.Count(DeleteUser)
+}
+
+// Print writes every change, with any failure, and then a summary line
+func (r Report) Print(w io.Writer) {
+	prefix := ""
+	if r.DryRun {
+		prefix = "[dry run] "
+	}
+	for _, change := range r.Changes {
+		if change.Err != nil {
+			fmt.Fprintf(w, "%s%s: %s\n", prefix, change, change.Err)
+		} else {
+			fmt.Fprintf(w, "%s%s\n", prefix, change)
+		}
+	}
+	fmt.Fprintf(w, "%s%d created, %d updated, %d deactivated, %d deleted, %d unchanged, %d groups created, %d added to groups, %d removed from groups, %d failed\n",
+		prefix, r.Count(CreateUser), r.Count(UpdateUser), r.Count(DeactivateUser), r.Count(DeleteUser), r.Unchanged,
+		r.Count(CreateGroup), r.Count(AddToGroup), r.Count(RemoveFromGroup), r.Failed)
+}
+
+// ThresholdError the sync would remove more users than Options.MaxRemoved allows, so nothing was changed
+type ThresholdError struct {
+	Removed int     // Users the plan would remove
+	Total   int     // Domo users the sync could remove
+	Max     float64 // The largest share allowed
+}
+
+func (e *ThresholdError) Error() string {
+	return fmt.Sprintf("Refusing to remove %d of %d users, more than %.1f%%", e.Removed, e.Total, e.Max*100)
+}
+
+// Run plans the sync, refuses it if it would remove too many users and otherwise applies it.
+// On a dry run the plan is returned without being applied.
+func Run(ctx context.Context, client *domo.Client, source Source, opts Options) (report Report, err error) {
+	report, err = Plan(ctx, client, source, opts)
+	if err != nil {
+		return
+	}
+
+	max := opts.MaxRemoved
+	if max <= 0 {
+		max = DefaultMaxRemoved
+	}
+	if removed := report.Removed(); removed > 0 && float64(removed) > max*float64(report.Total) {
+		return report, &ThresholdError{Removed: removed, Total: report.Total, Max: max}
+	}
+
+	if opts.DryRun {
+		return
+	}
+	err = Apply(ctx, client, &report)
+	return
+}
+
+// state what Domo holds, as Plan found it
+type state struct {
+	users   map[string]domo.User // by lower case email
+	groups  map[string]int       // ids by lower case name
+	names   map[string]string    // names as Domo spells them, by lower case name
+	members map[string]map[int]bool
+}
+
+// Plan works out the changes that bring Domo in line with the source, without making any
+func Plan(ctx context.Context, client *domo.Client, source Source, opts Options) (report Report, err error) {
+	report.DryRun = opts.DryRun
+	if opts.Removal == Deactivate && opts.DeactivatedRoleID == 0 {
+		return report, fmt.Errorf("Deactivating users needs a DeactivatedRoleID, or they keep their access")
+	}
+
+	desired, err := source.Users(ctx)
+	if err != nil {
+		return report, fmt.Errorf("Unable to read users from the source %s", err)
+	}
+	wanted := make(map[string]User, len(desired))
+	for _, user := range desired {
+		key := strings.ToLower(strings.TrimSpace(user.Email))
+		if key == "" {
+			return report, fmt.Errorf("The source has a user with no email : %s", user.Name)
+		}
+		if _, ok := wanted[key]; ok {
+			return report, fmt.Errorf("The source lists %s more than once", user.Email)
+		}
+		if user.Role != "" && !user.Role.Valid() {
+			return report, fmt.Errorf("%s has an invalid role %s", user.Email, user.Role)
+		}
+		wanted[key] = user
+	}
+
+	managed := managedGroups(desired, opts.Groups)
+	current, err := load(ctx, client, managed)
+	if err != nil {
+		return
+	}
+
+	protected := make(map[string]bool)
+	for _, email := range opts.Protected {
+		protected[strings.ToLower(email)] = true
+	}
+
+	var creates, updates, adds, removes, removals []Change
+	for _, key := range sortedKeys(managed) {
+		if _, ok := current.groups[key]; !ok {
+			creates = append(creates, Change{Action: CreateGroup, Group: managed[key]})
+		}
+	}
+
+	for _, key := range sortedEmails(wanted) {
+		if protected[key] {
+			continue
+		}
+		user := wanted[key]
+		existing, ok := current.users[key]
+		if !ok {
+			creates = append(creates, Change{Action: CreateUser, Email: user.Email, create: newUser(user, opts)})
+		} else if change, changed := compare(existing, user, opts); changed {
+			updates = append(updates, change)
+		} else {
+			report.Unchanged++
+		}
+
+		in := make(map[string]bool)
+		for _, name := range user.Groups {
+			in[strings.ToLower(name)] = true
+		}
+		for _, group := range sortedKeys(managed) {
+			member := ok && current.members[group][existing.ID]
+			change := Change{Email: user.Email, UserID: existing.ID, Group: current.name(group, managed), groupID: current.groups[group]}
+			if in[group] && !member {
+				change.Action = AddToGroup
+				adds = append(adds, change)
+			} else if !in[group] && member {
+				change.Action = RemoveFromGroup
+				removes = append(removes, change)
+			}
+		}
+	}
+
+	for _, key := range current.emails() {
+		if protected[key] {
+			continue
+		}
+		report.Total++
+		if _, ok := wanted[key]; ok || opts.Removal == Keep {
+			continue
+		}
+		existing := current.users[key]
+		if opts.Removal == Delete {
+			removals = append(removals, Change{Action: DeleteUser, Email: existing.Email, UserID: existing.ID})
+			continue
+		}
+
+		stripped := false
+		for _, group := range sortedKeys(managed) {
+			if current.members[group][existing.ID] {
+				removes = append(removes, Change{Action: RemoveFromGroup, Email: existing.Email, UserID: existing.ID, Group: current.names[group], groupID: current.groups[group]})
+				stripped = true
+			}
+		}
+		demote := existing.RoleID != opts.DeactivatedRoleID
+		if demote || stripped {
+			change := Change{Action: DeactivateUser, Email: existing.Email, UserID: existing.ID}
+			if demote {
+				change.patch.RoleID = domo.Int(opts.DeactivatedRoleID)
+			}
+			removals = append(removals, change)
+		}
+	}
+
+	report.Changes = append(report.Changes, creates...)
+	report.Changes = append(report.Changes, updates...)
+	report.Changes = append(report.Changes, adds...)
+	report.Changes = append(report.Changes, removes...)
+	report.Changes = append(report.Changes, removals...)
+	return
+}
+
+// Apply makes the planned changes in order. A change that fails is recorded in the report and the rest
+// are still tried, apart from the group changes of a user that couldn't be created.
+// Each run of group adds, or of removes, is made with one AddUsers or RemoveUsers per group.
+func Apply(ctx context.Context, client *domo.Client, report *Report) error {
+	report.DryRun = false
+	groups := make(map[string]int)
+	ids := make(map[string]int)
+
+	resolve := func(change *Change) {
+		if change.UserID == 0 {
+			change.UserID = ids[strings.ToLower(change.Email)]
+		}
+		if change.groupID == 0 {
+			change.groupID = groups[strings.ToLower(change.Group)]
+		}
+	}
+
+	for i := 0; i < len(report.Changes); i++ {
+		change := &report.Changes[i]
+		resolve(change)
+
+		switch change.Action {
+		case CreateGroup:
+			var group domo.Group
+			if group, change.Err = client.Group.Create(ctx, domo.GroupCreate{Name: change.Group}); change.Err == nil {
+				groups[strings.ToLower(change.Group)] = group.ID
+			}
+		case CreateUser:
+			var user domo.User
+			if user, change.Err = client.User.Create(ctx, change.create); change.Err == nil {
+				change.UserID = user.ID
+				ids[strings.ToLower(change.Email)] = user.ID
+			}
+		case UpdateUser, DeactivateUser:
+			if change.patch != (domo.UserPatch{}) {
+				_, change.Err = client.User.Update(ctx, change.UserID, change.patch)
+			}
+		case AddToGroup, RemoveFromGroup:
+			end := i + 1
+			for end < len(report.Changes) && report.Changes[end].Action == change.Action {
+				end++
+			}
+			for j := i + 1; j < end; j++ {
+				resolve(&report.Changes[j])
+			}
+			applyMembers(ctx, client, report.Changes[i:end])
+			for j := i + 1; j < end; j++ {
+				if report.Changes[j].Err != nil {
+					report.Failed++
+				}
+			}
+			i = end - 1
+		case DeleteUser:
+			change.Err = client.User.Delete(change.UserID)
+		}
+
+		if change.Err != nil {
+			report.Failed++
+		}
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d changes failed", report.Failed, len(report.Changes))
+	}
+	return nil
+}
+
+// applyMembers makes group changes that all add, or all remove, with one request per group,
+// recording on each change whether its user failed
+func applyMembers(ctx context.Context, client *domo.Client, changes []Change) {
+	users := make(map[int][]int)
+	var order []int
+	for i := range changes {
+		change := &changes[i]
+		switch {
+		case change.UserID == 0:
+			change.Err = errors.New("the user wasn't created")
+		case change.groupID == 0:
+			change.Err = errors.New("the group wasn't created")
+		default:
+			if users[change.groupID] == nil {
+				order = append(order, change.groupID)
+			}
+			users[change.groupID] = append(users[change.groupID], change.UserID)
+		}
+	}
+
+	failed := make(map[int]map[int]error)
+	for _, groupID := range order {
+		var err error
+		if changes[0].Action == AddToGroup {
+			err = client.Group.AddUsers(ctx, groupID, users[groupID])
+		} else {
+			err = client.Group.RemoveUsers(ctx, groupID, users[groupID])
+		}
+		var membership *domo.MembershipError
+		if errors.As(err, &membership) {
+			failed[groupID] = membership.Failed
+		}
+	}
+	for i := range changes {
+		if change := &changes[i]; change.Err == nil && failed[change.groupID] != nil {
+			change.Err = failed[change.groupID][change.UserID]
+		}
+	}
+}
+
+// managedGroups the groups the sync looks after, by lower case name
+func managedGroups(users []User, groups []string) map[string]string {
+	managed := make(map[string]string)
+	if len(groups) > 0 {
+		for _, name := range groups {
+			managed[strings.ToLower(name)] = name
+		}
+		return managed
+	}
+	for _, user := range users {
+		for _, name := range user.Groups {
+			if _, ok := managed[strings.ToLower(name)]; !ok {
+				managed[strings.ToLower(name)] = name
+			}
+		}
+	}
+	return managed
+}
+
+// load reads the users, the managed groups and their members from Domo
+func load(ctx context.Context, client *domo.Client, managed map[string]string) (current state, err error) {
+	current = state{
+		users:   make(map[string]domo.User),
+		groups:  make(map[string]int),
+		names:   make(map[string]string),
+		members: make(map[string]map[int]bool),
+	}
+
+	users, err := client.User.ListAll(ctx)
+	if err != nil {
+		return
+	}
+	for _, user := range users {
+		current.users[strings.ToLower(user.Email)] = user
+	}
+
+	groups, err := client.Group.ListAll(ctx)
+	if err != nil {
+		return current, fmt.Errorf("Unable to list groups %s", err)
+	}
+	// Domo lets groups share a name, but a managed one has to be the only group with it
+	named := make(map[string][]domo.Candidate)
+	for _, group := range groups {
+		key := strings.ToLower(group.Name)
+		named[key] = append(named[key], domo.Candidate{ID: group.ID, Name: group.Name})
+	}
+	for _, group := range groups {
+		key := strings.ToLower(group.Name)
+		if _, ok := managed[key]; !ok {
+			continue
+		}
+		if len(named[key]) > 1 {
+			return current, &domo.AmbiguousError{Kind: "group", Field: "name", Value: group.Name, Candidates: named[key]}
+		}
+		current.groups[key] = group.ID
+		current.names[key] = group.Name
+
+		ids, lerr := client.Group.ListAllUsers(ctx, group.ID)
+		if lerr != nil {
+			return current, fmt.Errorf("Unable to list members of %s %s", group.Name, lerr)
+		}
+		current.members[key] = make(map[int]bool)
+		for _, id := range ids {
+			current.members[key][id] = true
+		}
+	}
+	return
+}
+
+// name the group's name as Domo spells it, or as the source does for a group yet to be created
+func (s state) name(key string, managed map[string]string) string {
+	if name, ok := s.names[key]; ok {
+		return name
+	}
+	return managed[key]
+}
+
+// newUser the request that creates the user
+func newUser(user User, opts Options) domo.UserCreate {
+	role := user.Role
+	if role == "" {
+		role = opts.DefaultRole
+	}
+	if role == "" {
+		role = domo.RoleParticipant
+	}
+	return domo.UserCreate{
+		Name:           user.Name,
+		Email:          user.Email,
+		Role:           role,
+		Title:          user.Title,
+		Phone:          user.Phone,
+		Location:       user.Location,
+		AlternateEmail: user.AlternateEmail,
+		EmployeeNumber: user.EmployeeNumber,
+		SendInvite:     opts.SendInvite,
+	}
+}
+
+// compare works out the update that gives the Domo user the source's values.
+// A deactivated user who is back in the source gets their role back.
+func compare(existing domo.User, user User, opts Options) (change Change, changed bool) {
+	change = Change{Action: UpdateUser, Email: user.Email, UserID: existing.ID}
+	text := func(field string, want string, have string, set **string) {
+		if want != "" && want != have {
+			*set = domo.String(want)
+			change.Fields = append(change.Fields, field)
+		}
+	}
+	text("name", user.Name, existing.Name, &change.patch.Name)
+	text("title", user.Title, existing.Title, &change.patch.Title)
+	text("phone", user.Phone, existing.Phone, &change.patch.Phone)
+	text("location", user.Location, existing.Location, &change.patch.Location)
+	text("alternate email", user.AlternateEmail, existing.AlternateEmail, &change.patch.AlternateEmail)
+	if user.EmployeeNumber != 0 && user.EmployeeNumber != existing.EmployeeNumber {
+		change.patch.EmployeeNumber = domo.Int(user.EmployeeNumber)
+		change.Fields = append(change.Fields, "employee number")
+	}
+
+	role := user.Role
+	if role == "" && opts.DeactivatedRoleID != 0 && existing.RoleID == opts.DeactivatedRoleID {
+		role = newUser(user, opts).Role
+	}
+	if role != "" && role != existing.Role {
+		change.patch.Role = &role
+		change.Fields = append(change.Fields, "role")
+	}
+	return change, len(change.Fields) > 0
+}
+
+// sortedKeys the keys of a map of names, in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedEmails the emails of the source's users, in order
+func sortedEmails(m map[string]User) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// emails the emails of Domo's users, in order
+func (s state) emails() []string {
+	keys := make([]string, 0, len(s.users))
+	for key := range s.users {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
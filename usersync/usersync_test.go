@@ -0,0 +1,178 @@
+package usersync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	domo "github.com/davecb/domoStreamApi"
+	"github.com/davecb/domoStreamApi/domotest"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDirectory a Domo instance with a service account, two people in Sales and one who has left
+func testDirectory() (server *domotest.Server, ann domo.User, bob domo.User, cat domo.User) {
+	server = domotest.NewServer()
+	server.AddUser(domo.User{Name: "Service", Email: "etl@example.com", Role: domo.RoleAdmin})
+	ann = server.AddUser(domo.User{Name: "Ann", Email: "Ann@Example.com", Role: domo.RoleParticipant, Title: "Analyst"})
+	bob = server.AddUser(domo.User{Name: "Bob", Email: "bob@example.com", Role: domo.RoleParticipant})
+	cat = server.AddUser(domo.User{Name: "Cat", Email: "cat@example.com", Role: domo.RoleParticipant})
+	server.AddGroup(domo.Group{Name: "Sales"}, ann.ID, cat.ID)
+	server.AddGroup(domo.Group{Name: "Hand Made"}, cat.ID)
+	return
+}
+
+// testSource Ann promoted and moved to Finance, Bob joining Sales, Dan new and Cat gone
+var testSource = StaticSource{
+	{Email: "ann@example.com", Name: "Ann", Title: "Lead Analyst", Groups: []string{"Finance"}},
+	{Email: "bob@example.com", Name: "Bob", Groups: []string{"Sales"}},
+	{Email: "dan@example.com", Name: "Dan", Role: domo.RolePrivileged, Groups: []string{"Sales", "Finance"}},
+}
+
+func TestRun(t *testing.T) {
+	server, ann, bob, cat := testDirectory()
+	defer server.Close()
+	client := server.Client()
+	deactivated := server.AddRole(domo.RoleDetail{Name: "Deactivated"})
+
+	opts := Options{Protected: []string{"etl@example.com"}, DeactivatedRoleID: deactivated.ID, MaxRemoved: 0.5, DryRun: true}
+	report, err := Run(context.Background(), client, testSource, opts)
+	assert.Equal(t, nil, err, "Bad error code")
+	var changes []string
+	for _, change := range report.Changes {
+		changes = append(changes, change.String())
+	}
+	assert.Equal(t, []string{
+		"create group Finance",
+		"create dan@example.com",
+		"update ann@example.com (title)",
+		"add ann@example.com to Finance",
+		"add bob@example.com to Sales",
+		"add dan@example.com to Finance",
+		"add dan@example.com to Sales",
+		"remove ann@example.com from Sales",
+		"remove cat@example.com from Sales",
+		"deactivate cat@example.com",
+	}, changes, "Bad plan")
+	assert.Equal(t, 3, report.Total, "Bad total")
+	assert.Equal(t, 1, report.Unchanged, "Bad unchanged")
+	assert.Equal(t, 4, len(server.Users()), "Dry run changed users")
+
+	var out bytes.Buffer
+	report.Print(&out)
+	assert.Equal(t, true, strings.HasPrefix(out.String(), "[dry run] create group Finance\n"), "Bad dry run output")
+	assert.Equal(t, true, strings.Contains(out.String(), "1 created, 1 updated, 1 deactivated, 0 deleted, 1 unchanged"), "Bad summary")
+
+	opts.DryRun = false
+	report, err = Run(context.Background(), client, testSource, opts)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 0, report.Failed, "Bad failures")
+
+	finance, _ := client.Group.Find("Finance")
+	sales, _ := client.Group.Find("Sales")
+	handMade, _ := client.Group.Find("Hand Made")
+	dan := report.Changes[1].UserID
+	assert.Equal(t, []int{ann.ID, dan}, server.Members(finance), "Bad Finance")
+	members := server.Members(sales)
+	sort.Ints(members)
+	assert.Equal(t, []int{bob.ID, dan}, members, "Bad Sales")
+	assert.Equal(t, []int{cat.ID}, server.Members(handMade), "Unmanaged group changed")
+
+	user, _ := client.User.Retrieve(cat.ID)
+	assert.Equal(t, deactivated.ID, user.RoleID, "Cat not deactivated")
+	user, _ = client.User.Retrieve(ann.ID)
+	assert.Equal(t, "Lead Analyst", user.Title, "Ann not updated")
+	user, _ = client.User.Retrieve(dan)
+	assert.Equal(t, domo.RolePrivileged, user.Role, "Bad role for Dan")
+
+	report, err = Run(context.Background(), client, testSource, opts)
+	assert.Equal(t, nil, err, "Bad error code")
+	assert.Equal(t, 0, len(report.Changes), "Second run not idempotent")
+
+	back := append(StaticSource{{Email: "cat@example.com", Name: "Cat"}}, testSource...)
+	report, err = Run(context.Background(), client, back, opts)
+	assert.Equal(t, nil, err, "Bad error code")
+	user, _ = client.User.Retrieve(cat.ID)
+	assert.Equal(t, domo.RoleParticipant, user.Role, "Cat not reactivated")
+}
+
+func TestPlan_deactivateOutsideGroups(t *testing.T) {
+	server, _, _, cat := testDirectory()
+	defer server.Close()
+	client := server.Client()
+	deactivated := server.AddRole(domo.RoleDetail{Name: "Deactivated"})
+
+	opts := Options{Groups: []string{"Finance"}, Protected: []string{"etl@example.com"}, DeactivatedRoleID: deactivated.ID, MaxRemoved: 1}
+	report, err := Plan(context.Background(), client, testSource, opts)
+	assert.Equal(t, nil, err, "Bad error code")
+	var removals []string
+	for _, change := range report.Changes {
+		if change.Action == DeactivateUser {
+			removals = append(removals, change.Email)
+		}
+	}
+	assert.Equal(t, []string{cat.Email}, removals, "User outside the managed groups not deactivated")
+}
+
+func TestApply_groupFailure(t *testing.T) {
+	server, _, bob, _ := testDirectory()
+	defer server.Close()
+	client := server.Client()
+	server.Inject(domotest.Fault{Method: "PUT", Path: fmt.Sprintf("/v1/groups/*/users/%d", bob.ID), Status: 403})
+
+	report, err := Run(context.Background(), client, testSource, Options{Removal: Keep})
+	assert.NotEqual(t, nil, err, "Failed add not reported")
+	assert.Equal(t, 1, report.Failed, "Bad failures")
+	for _, change := range report.Changes {
+		failed := change.Action == AddToGroup && change.UserID == bob.ID
+		assert.Equal(t, failed, change.Err != nil, "Bad outcome of "+change.String())
+	}
+	sales, _ := client.Group.Find("Sales")
+	for _, member := range server.Members(sales) {
+		assert.NotEqual(t, bob.ID, member, "Bob added despite the failure")
+	}
+}
+
+func TestRun_ambiguousGroup(t *testing.T) {
+	server, _, _, _ := testDirectory()
+	defer server.Close()
+	server.AddGroup(domo.Group{Name: "sales"})
+
+	_, err := Run(context.Background(), server.Client(), testSource, Options{Removal: Keep})
+	assert.Equal(t, true, errors.Is(err, domo.ErrAmbiguous), "Shared group name not reported")
+}
+
+func TestRun_threshold(t *testing.T) {
+	server, _, _, _ := testDirectory()
+	defer server.Close()
+	client := server.Client()
+
+	report, err := Run(context.Background(), client, StaticSource{}, Options{Removal: Delete})
+	threshold, ok := err.(*ThresholdError)
+	assert.Equal(t, true, ok, "Bad error type")
+	assert.Equal(t, 4, threshold.Removed, "Bad removed count")
+	assert.Equal(t, 4, report.Count(DeleteUser), "Bad plan")
+	assert.Equal(t, 4, len(server.Users()), "Users deleted over the threshold")
+
+	_, err = Run(context.Background(), client, StaticSource{}, Options{Removal: Keep})
+	assert.Equal(t, nil, err, "Keep removed users")
+}
+
+func TestPlan_badSource(t *testing.T) {
+	server, _, _, _ := testDirectory()
+	defer server.Close()
+	client := server.Client()
+
+	_, err := Plan(context.Background(), client, StaticSource{{Email: "a@example.com"}, {Email: "A@example.com"}}, Options{Removal: Keep})
+	assert.NotEqual(t, nil, err, "Duplicate accepted")
+	_, err = Plan(context.Background(), client, StaticSource{{Name: "Nobody"}}, Options{Removal: Keep})
+	assert.NotEqual(t, nil, err, "Missing email accepted")
+	_, err = Plan(context.Background(), client, StaticSource{{Email: "a@example.com", Role: "Boss"}}, Options{Removal: Keep})
+	assert.NotEqual(t, nil, err, "Bad role accepted")
+	_, err = Plan(context.Background(), client, StaticSource{{Email: "a@example.com"}}, Options{})
+	assert.NotEqual(t, nil, err, "Deactivation with no role accepted")
+}